@@ -50,6 +50,7 @@ func main() {
 		sysdig.WithUserAgent("sysdig-go; custom client example"),
 		// sysdig.WithDebug(false), // Enable to see requests/responses.
 		// sysdig.WithLogger(log.Default()), // Uncomment to set the logger when setting debug to true.
+		// sysdig.WithInsecureSkipVerify(true), // Uncomment to test on-premise installations with self-signed certificates.
 		sysdig.WithIBMBaseURL(sysdig.RegionUSSouth, false), // Uncomment to use an IBM Cloud Monitoring instance
 	)
 	if err != nil {
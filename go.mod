@@ -6,4 +6,6 @@ require (
 	github.com/google/go-cmp v0.5.7
 	github.com/google/go-querystring v1.1.0
 	github.com/prometheus/client_golang v1.12.1
+	github.com/prometheus/common v0.32.1
+	golang.org/x/oauth2 v0.0.0-20210514164344-f6687ab2804c
 )
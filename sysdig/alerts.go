@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // AlertService is the Service for communicating with the Sysdig Monitor Alert related API.
@@ -46,6 +48,78 @@ type Alert struct {
 	SeverityLabel  SeverityLabel `json:"severityLabel"`
 	Condition      string        `json:"condition"`
 	CustomerID     int           `json:"customerId"`
+	// AlertNotificationChannelIDs are the IDs of the NotificationChannels the Alert notifies when it fires.
+	AlertNotificationChannelIDs []int `json:"notificationChannelIds,omitempty"`
+}
+
+// AlertCondition builds the threshold Condition and Timespan of a metric Alert, e.g.
+// NewAlertCondition().Metric("cpu.used.percent").Above(80).For(10 * time.Minute).
+type AlertCondition struct {
+	metric           string
+	timeAggregation  string
+	groupAggregation string
+	operator         string
+	threshold        float64
+	duration         time.Duration
+}
+
+// NewAlertCondition creates a new AlertCondition which averages the metric over time and across the group.
+func NewAlertCondition() *AlertCondition {
+	return &AlertCondition{
+		timeAggregation:  "avg",
+		groupAggregation: "avg",
+		operator:         ">",
+	}
+}
+
+// Metric sets the metric the AlertCondition is evaluated on.
+func (c *AlertCondition) Metric(name string) *AlertCondition {
+	c.metric = name
+	return c
+}
+
+// Aggregate sets how the metric is aggregated over time and across the group, e.g. "avg", "max", "min" or "sum".
+func (c *AlertCondition) Aggregate(timeAggregation, groupAggregation string) *AlertCondition {
+	c.timeAggregation = timeAggregation
+	c.groupAggregation = groupAggregation
+	return c
+}
+
+// Above makes the AlertCondition trigger when the metric is greater than threshold.
+func (c *AlertCondition) Above(threshold float64) *AlertCondition {
+	c.operator = ">"
+	c.threshold = threshold
+	return c
+}
+
+// Below makes the AlertCondition trigger when the metric is less than threshold.
+func (c *AlertCondition) Below(threshold float64) *AlertCondition {
+	c.operator = "<"
+	c.threshold = threshold
+	return c
+}
+
+// For sets how long the AlertCondition must hold before the Alert triggers.
+func (c *AlertCondition) For(d time.Duration) *AlertCondition {
+	c.duration = d
+	return c
+}
+
+// String returns the Alert Condition, e.g. "avg(avg(cpu.used.percent)) > 80".
+func (c *AlertCondition) String() string {
+	return fmt.Sprintf("%s(%s(%s)) %s %s",
+		c.timeAggregation, c.groupAggregation, c.metric, c.operator, strconv.FormatFloat(c.threshold, 'f', -1, 64))
+}
+
+// Timespan returns the Alert Timespan for the duration the AlertCondition must hold.
+func (c *AlertCondition) Timespan() MicroDuration {
+	return NewMicroDuration(c.duration)
+}
+
+// SetCondition sets the Condition and Timespan of the Alert from the AlertCondition.
+func (a *Alert) SetCondition(c *AlertCondition) {
+	a.Condition = c.String()
+	a.Timespan = c.Timespan()
 }
 
 // AlertCustomNotification is the structure for a Custom Notification on an Alert.
@@ -54,12 +128,12 @@ type AlertCustomNotification struct {
 	UseNewTemplate bool   `json:"useNewTemplate"`
 }
 
-// AlertCriteria defines the Criteria for an Alert.
+// AlertCriteria defines the Criteria for an Alert. Severity is the list of event Severity levels matched by the Alert.
 // TODO: What are the format of the unknown fields?
 type AlertCriteria struct {
 	Text     string      `json:"text"`
 	Source   interface{} `json:"source"`
-	Severity interface{} `json:"severity"`
+	Severity []Severity  `json:"severity"`
 	Query    interface{} `json:"query"`
 	Scope    interface{} `json:"scope"`
 }
@@ -88,14 +162,70 @@ type ListAlertConfigurationsResponse struct {
 
 // List lists all Alerts.
 func (s *AlertService) List(ctx context.Context) (*ListAlertConfigurationsResponse, *http.Response, error) {
-	u := "api/alerts"
+	return s.ListWithOptions(ctx, ListAlertOptions{})
+}
+
+// ListByGroup lists the Alerts in the Alert group with the given name.
+func (s *AlertService) ListByGroup(ctx context.Context, groupName string) (*ListAlertConfigurationsResponse, *http.Response, error) {
+	if groupName == "" {
+		return nil, nil, fmt.Errorf("group name cannot be blank")
+	}
+	return s.ListWithOptions(ctx, ListAlertOptions{GroupName: groupName})
+}
+
+// ListAlertOptions are the options for AlertService.ListWithOptions.
+type ListAlertOptions struct {
+	// TeamID filters Alerts to the Team with the given ID, if set.
+	TeamID int `url:"teamId,omitempty"`
+	// Enabled filters Alerts to enabled or disabled Alerts, if set.
+	Enabled *bool `url:"enabled,omitempty"`
+	// GroupName filters Alerts to the Alert group with the given name, if set. The Alerts API has no group filter, so
+	// it is only applied client side.
+	GroupName string `url:"-"`
+}
+
+// ListWithOptions lists the Alerts matching the ListAlertOptions.
+// The filters are sent to the API as query parameters, but the Alerts API does not document them, so the decoded
+// Alerts are also filtered client side to guarantee the result.
+func (s *AlertService) ListWithOptions(
+	ctx context.Context,
+	options ListAlertOptions) (*ListAlertConfigurationsResponse, *http.Response, error) {
+	u, err := addOptions("api/alerts", options)
+	if err != nil {
+		return nil, nil, err
+	}
 	req, err := s.client.NewRequest(http.MethodGet, u, nil)
 	if err != nil {
 		return nil, nil, err
 	}
 	c := new(ListAlertConfigurationsResponse)
 	resp, err := s.client.Do(ctx, req, c)
-	return c, resp, err
+	if err != nil {
+		return c, resp, err
+	}
+	c.Alerts = options.filter(c.Alerts)
+	return c, resp, nil
+}
+
+// filter returns the Alerts matching the ListAlertOptions.
+func (o ListAlertOptions) filter(alerts []Alert) []Alert {
+	if o.TeamID == 0 && o.Enabled == nil && o.GroupName == "" {
+		return alerts
+	}
+	filtered := make([]Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		if o.TeamID != 0 && alert.TeamID != o.TeamID {
+			continue
+		}
+		if o.Enabled != nil && alert.Enabled != *o.Enabled {
+			continue
+		}
+		if o.GroupName != "" && alert.GroupName != o.GroupName {
+			continue
+		}
+		filtered = append(filtered, alert)
+	}
+	return filtered
 }
 
 // Delete deletes an Alert.
@@ -108,3 +238,29 @@ func (s *AlertService) Delete(ctx context.Context, alertID int) (*http.Response,
 	resp, err := s.client.Do(ctx, req, nil)
 	return resp, err
 }
+
+// SetNotificationChannels sets the NotificationChannels an Alert notifies when it fires, replacing any existing
+// channels. Calling it without channelIDs removes all channels from the Alert.
+// The Alerts API has no endpoint for the channels alone, so the Alert is retrieved and updated with the channels set.
+func (s *AlertService) SetNotificationChannels(
+	ctx context.Context,
+	alertID int,
+	channelIDs ...int) (*AlertResponse, *http.Response, error) {
+	current, resp, err := s.Get(ctx, alertID)
+	if err != nil {
+		return nil, resp, err
+	}
+	type alertRequest struct {
+		Alert Alert `json:"alert"`
+	}
+	alert := current.Alert
+	alert.AlertNotificationChannelIDs = append(make([]int, 0, len(channelIDs)), channelIDs...)
+	u := fmt.Sprintf("api/alerts/%d", alertID)
+	req, err := s.client.NewRequest(http.MethodPut, u, alertRequest{alert})
+	if err != nil {
+		return nil, nil, err
+	}
+	c := new(AlertResponse)
+	resp, err = s.client.Do(ctx, req, c)
+	return c, resp, err
+}
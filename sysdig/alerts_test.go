@@ -2,9 +2,12 @@ package sysdig
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -56,6 +59,124 @@ func TestAlertsService_List(t *testing.T) {
 	})
 }
 
+func TestAlertsService_ListWithOptions(t *testing.T) {
+	methodName := "ListWithOptions"
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/alerts", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	})
+	defer teardown()
+
+	alerts := `{"alerts":[
+		{"id":1,"teamId":1,"enabled":true},
+		{"id":2,"teamId":1,"enabled":false},
+		{"id":3,"teamId":2,"enabled":true}]}`
+	enabled := true
+	tests := []struct {
+		name       string
+		options    ListAlertOptions
+		wantValues values
+		want       []Alert
+	}{
+		{
+			name:       "no filters",
+			options:    ListAlertOptions{},
+			wantValues: values{},
+			want:       []Alert{{ID: 1, TeamID: 1, Enabled: true}, {ID: 2, TeamID: 1}, {ID: 3, TeamID: 2, Enabled: true}},
+		},
+		{
+			name:       "team",
+			options:    ListAlertOptions{TeamID: 1},
+			wantValues: values{"teamId": "1"},
+			want:       []Alert{{ID: 1, TeamID: 1, Enabled: true}, {ID: 2, TeamID: 1}},
+		},
+		{
+			name:       "team and enabled",
+			options:    ListAlertOptions{TeamID: 1, Enabled: &enabled},
+			wantValues: values{"teamId": "1", "enabled": "true"},
+			want:       []Alert{{ID: 1, TeamID: 1, Enabled: true}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The API response is unfiltered to exercise the client side filtering.
+			h = func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				testFormValues(t, r, test.wantValues)
+				fmt.Fprint(w, alerts)
+			}
+			got, _, err := client.Alerts.ListWithOptions(context.Background(), test.options)
+			if err != nil {
+				t.Errorf("Alerts.ListWithOptions returned error: %v", err)
+			}
+			if !cmp.Equal(got.Alerts, test.want) {
+				t.Errorf("Alerts.ListWithOptions returned %+v, want %+v", got.Alerts, test.want)
+			}
+		})
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		got, resp, ferr := client.Alerts.ListWithOptions(context.Background(), ListAlertOptions{TeamID: 1})
+		if got != nil {
+			t.Errorf("testNewRequestAndDoFailure %v = %#v, want nil", methodName, got)
+		}
+		return resp, ferr
+	})
+}
+
+func TestAlertsService_ListByGroup(t *testing.T) {
+	methodName := "ListByGroup"
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/alerts", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		testFormValues(t, r, values{})
+		fmt.Fprint(w, `{"alerts":[
+			{"id":1,"groupName":"kubernetes"},
+			{"id":2,"groupName":"hosts"},
+			{"id":3},
+			{"id":4,"groupName":"kubernetes"}]}`)
+	})
+	defer teardown()
+
+	tests := []struct {
+		name      string
+		groupName string
+		want      []Alert
+	}{
+		{
+			name:      "group",
+			groupName: "kubernetes",
+			want:      []Alert{{ID: 1, GroupName: "kubernetes"}, {ID: 4, GroupName: "kubernetes"}},
+		},
+		{
+			name:      "no match",
+			groupName: "databases",
+			want:      []Alert{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, _, err := client.Alerts.ListByGroup(context.Background(), test.groupName)
+			if err != nil {
+				t.Fatalf("Alerts.ListByGroup returned error: %v", err)
+			}
+			if !cmp.Equal(got.Alerts, test.want) {
+				t.Errorf("Alerts.ListByGroup returned %+v, want %+v", got.Alerts, test.want)
+			}
+		})
+	}
+
+	if _, _, err := client.Alerts.ListByGroup(context.Background(), ""); err == nil {
+		t.Error("Alerts.ListByGroup with a blank group name expected error")
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Alerts.ListByGroup(context.Background(), "kubernetes")
+		return resp, err
+	})
+}
+
 func TestAlertsService_Delete(t *testing.T) {
 	methodName := "Delete"
 	client, mux, _, teardown := setup(nil)
@@ -136,3 +257,130 @@ func TestAlertsService_Get(t *testing.T) {
 		return resp, err
 	})
 }
+
+func TestAlert_NotificationChannelIDs(t *testing.T) {
+	var alert Alert
+	if err := json.Unmarshal([]byte(`{"id":1,"notificationChannelIds":[2,3]}`), &alert); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	if want := []int{2, 3}; !cmp.Equal(alert.AlertNotificationChannelIDs, want) {
+		t.Errorf("got notification channel IDs: %v, want: %v", alert.AlertNotificationChannelIDs, want)
+	}
+	b, err := json.Marshal(alert)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+	if !strings.Contains(string(b), `"notificationChannelIds":[2,3]`) {
+		t.Errorf("marshaled Alert %s does not contain notificationChannelIds", b)
+	}
+	if b, err = json.Marshal(Alert{}); err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+	if strings.Contains(string(b), "notificationChannelIds") {
+		t.Errorf("marshaled Alert %s contains empty notificationChannelIds", b)
+	}
+}
+
+func TestAlertsService_SetNotificationChannels(t *testing.T) {
+	methodName := "SetNotificationChannels"
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/alerts/1", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, `{"alert":{"id":1,"version":2,"name":"cpu","notificationChannelIds":[9]}}`)
+		case http.MethodPut:
+			var v struct {
+				Alert Alert `json:"alert"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+				t.Errorf("failed to decode request body: %v", err)
+				return
+			}
+			want := Alert{ID: 1, Version: 2, Name: "cpu", AlertNotificationChannelIDs: []int{4, 5}}
+			if !cmp.Equal(v.Alert, want) {
+				t.Errorf("Request body = %+v, want %+v", v.Alert, want)
+			}
+			fmt.Fprint(w, `{"alert":{"id":1,"version":3,"name":"cpu","notificationChannelIds":[4,5]}}`)
+		default:
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+	})
+	defer teardown()
+
+	got, _, err := client.Alerts.SetNotificationChannels(context.Background(), 1, 4, 5)
+	if err != nil {
+		t.Errorf("Alerts.SetNotificationChannels returned error: %v", err)
+	}
+	want := &AlertResponse{Alert: Alert{ID: 1, Version: 3, Name: "cpu", AlertNotificationChannelIDs: []int{4, 5}}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Alerts.SetNotificationChannels returned %+v, want %+v", got, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Alerts.SetNotificationChannels(context.Background(), 1, 4, 5)
+		return resp, err
+	})
+}
+
+func TestAlertCondition(t *testing.T) {
+	tests := []struct {
+		name          string
+		condition     *AlertCondition
+		wantCondition string
+		wantTimespan  MicroDuration
+	}{
+		{
+			name:          "above",
+			condition:     NewAlertCondition().Metric("cpu.used.percent").Above(80).For(10 * time.Minute),
+			wantCondition: "avg(avg(cpu.used.percent)) > 80",
+			wantTimespan:  NewMicroDuration(10 * time.Minute),
+		},
+		{
+			name:          "below",
+			condition:     NewAlertCondition().Metric("memory.bytes.available").Below(0.5).For(time.Minute),
+			wantCondition: "avg(avg(memory.bytes.available)) < 0.5",
+			wantTimespan:  NewMicroDuration(time.Minute),
+		},
+		{
+			name: "aggregated",
+			condition: NewAlertCondition().
+				Metric("net.http.request.count").
+				Aggregate("sum", "max").
+				Above(1000).
+				For(5 * time.Minute),
+			wantCondition: "sum(max(net.http.request.count)) > 1000",
+			wantTimespan:  NewMicroDuration(5 * time.Minute),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var alert Alert
+			alert.SetCondition(test.condition)
+			if alert.Condition != test.wantCondition {
+				t.Errorf("got condition: %q, want: %q", alert.Condition, test.wantCondition)
+			}
+			if alert.Timespan != test.wantTimespan {
+				t.Errorf("got timespan: %v, want: %v", alert.Timespan, test.wantTimespan)
+			}
+		})
+	}
+}
+
+func TestAlertCriteria_Severity(t *testing.T) {
+	const payload = `{"text":"deploy","source":"kubernetes","severity":[0,1,2],"query":null,` +
+		`"scope":"kubernetes.namespace.name = \"prod\""}`
+	var criteria AlertCriteria
+	if err := json.Unmarshal([]byte(payload), &criteria); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	if want := []Severity{SeverityEmergency, SeverityAlert, SeverityCritical}; !cmp.Equal(criteria.Severity, want) {
+		t.Errorf("got severity: %v, want: %v", criteria.Severity, want)
+	}
+	b, err := json.Marshal(criteria)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+	if string(b) != payload {
+		t.Errorf("json.Marshal returned %s, want %s", b, payload)
+	}
+}
@@ -1,6 +1,7 @@
 package authentication
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 )
@@ -26,6 +27,30 @@ type Refreshable interface {
 	Refresh() error
 }
 
+// ContextRefreshable defines an optional interface for Refreshable Authenticators that can be Refreshed with a
+// context. The context of the failed request is used for the Refresh when implemented, so that a Refresh is
+// abandoned when the request is canceled or its deadline is exceeded.
+type ContextRefreshable interface {
+	Refreshable
+	RefreshContext(ctx context.Context) error
+}
+
+// failedAuthorizationContextKey is the context key for the Authorization header of a rejected request.
+type failedAuthorizationContextKey struct{}
+
+// ContextWithFailedAuthorization returns a copy of ctx carrying the Authorization header of a request rejected by the
+// Sysdig API. The Sysdig Client passes it to RefreshContext, so a ContextRefreshable can skip the refresh if the
+// rejected credentials have already been replaced, e.g. by a concurrent refresh.
+func ContextWithFailedAuthorization(ctx context.Context, authorization string) context.Context {
+	return context.WithValue(ctx, failedAuthorizationContextKey{}, authorization)
+}
+
+// FailedAuthorization returns the Authorization header set with ContextWithFailedAuthorization, if any.
+func FailedAuthorization(ctx context.Context) (string, bool) {
+	authorization, ok := ctx.Value(failedAuthorizationContextKey{}).(string)
+	return authorization, ok
+}
+
 // AuthenticatorFunc defines a function that will authenticate the given Request.
 type AuthenticatorFunc func(req *http.Request) error
 
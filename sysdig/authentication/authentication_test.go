@@ -1,6 +1,7 @@
 package authentication
 
 import (
+	"context"
 	"net/http"
 	"testing"
 )
@@ -51,3 +52,17 @@ func TestAuthenticateFunc(t *testing.T) {
 		t.Error("AuthenticatorFunc did not run when authenticating")
 	}
 }
+
+func TestFailedAuthorization(t *testing.T) {
+	if _, ok := FailedAuthorization(context.Background()); ok {
+		t.Error("FailedAuthorization returned a value for a context without one")
+	}
+	ctx := ContextWithFailedAuthorization(context.Background(), AuthorizationHeaderFor("foo"))
+	got, ok := FailedAuthorization(ctx)
+	if !ok {
+		t.Fatal("FailedAuthorization did not return a value")
+	}
+	if want := AuthorizationHeaderFor("foo"); got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
@@ -2,6 +2,7 @@ package ibmiam
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -20,9 +21,11 @@ const (
 
 	// DefaultRefreshBeforeExpirationDuration is the default duration before expected expiration to refresh the IAM token.
 	DefaultRefreshBeforeExpirationDuration = 5 * time.Minute
-	// tokenValidDuration is the default validity period for IBM Cloud IAM tokens.
-	tokenValidDuration   = time.Hour
-	defaultRefreshBefore = tokenValidDuration - DefaultRefreshBeforeExpirationDuration
+	// DefaultRefreshTimeout is the default timeout for a single IAM token refresh request.
+	DefaultRefreshTimeout = 30 * time.Second
+	// tokenValidDuration is the default validity period for IBM Cloud IAM tokens, used when the IAM token response
+	// does not include an expiry.
+	tokenValidDuration = time.Hour
 )
 
 type iamTokenResponse struct {
@@ -31,31 +34,56 @@ type iamTokenResponse struct {
 	UAAAccessToken  string `json:"uaa_token"`
 	UAARefreshToken string `json:"uaa_refresh_token"`
 	TokenType       string `json:"token_type"`
+	// ExpiresIn is the number of seconds until the token expires.
+	ExpiresIn int64 `json:"expires_in"`
+	// Expiration is the Unix time in seconds at which the token expires.
+	Expiration int64 `json:"expiration"`
+}
+
+// validFor returns how long the token is valid for from now, based on the expiry in the token response.
+// tokenValidDuration is returned when the response does not include an expiry.
+func (r iamTokenResponse) validFor(now time.Time) time.Duration {
+	if r.ExpiresIn > 0 {
+		return time.Duration(r.ExpiresIn) * time.Second
+	}
+	if r.Expiration > 0 {
+		if d := time.Unix(r.Expiration, 0).Sub(now); d > 0 {
+			return d
+		}
+	}
+	return tokenValidDuration
 }
 
 type authenticator struct {
-	httpClient    *http.Client
-	iamEndpoint   string
-	apiKey        string
-	ibmInstanceID string
-	sysdigTeamID  string
-	refreshBefore time.Duration
+	httpClient     *http.Client
+	iamEndpoint    string
+	apiKey         string
+	ibmInstanceID  string
+	sysdigTeamID   string
+	refreshBefore  time.Duration // Duration before expiration to refresh the token.
+	refreshTimeout time.Duration
+	clock          func() time.Time
 
-	lock        sync.RWMutex
-	lastRefresh time.Time
-	token       iamTokenResponse
+	lock      sync.RWMutex
+	refreshAt time.Time
+	token     iamTokenResponse
 }
 
 // Authenticate implements authentication.Authenticator using IBM Cloud IAM.
+// A token refresh made while authenticating uses the context of the request.
 func (a *authenticator) Authenticate(req *http.Request) error {
-	if time.Since(a.lastRefresh) > a.refreshBefore {
-		if err := a.Refresh(); err != nil {
-			return err
-		}
-	}
 	a.lock.RLock()
+	stale := a.stale()
 	at := a.token.AccessToken
 	a.lock.RUnlock()
+	if stale {
+		if err := a.refreshIfStale(req.Context()); err != nil {
+			return err
+		}
+		a.lock.RLock()
+		at = a.token.AccessToken
+		a.lock.RUnlock()
+	}
 
 	req.Header.Set(authentication.AuthorizationHeader, authentication.AuthorizationHeaderFor(at))
 	req.Header.Set(authentication.IBMInstanceIDHeader, a.ibmInstanceID)
@@ -67,18 +95,45 @@ func (a *authenticator) Authenticate(req *http.Request) error {
 
 // Refresh implements Refreshable for the Authenticator.
 func (a *authenticator) Refresh() error {
-	return a.refreshAccessToken()
+	return a.RefreshContext(context.Background())
 }
 
-func (a *authenticator) refreshAccessToken() error {
+// RefreshContext implements ContextRefreshable for the Authenticator.
+// The refresh is abandoned when ctx is canceled or its deadline, or the refresh timeout, is exceeded.
+func (a *authenticator) RefreshContext(ctx context.Context) error {
 	a.lock.Lock()
 	defer a.lock.Unlock()
+	return a.refreshAccessToken(ctx)
+}
+
+// stale reports whether the token should be refreshed. a.lock must be held.
+func (a *authenticator) stale() bool {
+	return !a.clock().Before(a.refreshAt)
+}
+
+// refreshIfStale refreshes the token unless another goroutine refreshed it while waiting for the lock.
+func (a *authenticator) refreshIfStale(ctx context.Context) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if !a.stale() {
+		return nil
+	}
+	return a.refreshAccessToken(ctx)
+}
+
+// refreshAccessToken retrieves a new token from the IAM endpoint. a.lock must be held for writing.
+func (a *authenticator) refreshAccessToken(ctx context.Context) error {
 	v := url.Values{
 		"grant_type":    []string{"urn:ibm:params:oauth:grant-type:apikey"},
 		"response_type": []string{"cloud_iam"},
 		"apikey":        []string{a.apiKey},
 	}
-	req, err := http.NewRequest(http.MethodPost, a.iamEndpoint, bytes.NewBufferString(v.Encode()))
+	if a.refreshTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.refreshTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.iamEndpoint, bytes.NewBufferString(v.Encode()))
 	if err != nil {
 		return err
 	}
@@ -94,11 +149,20 @@ func (a *authenticator) refreshAccessToken() error {
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("failed to refresh token: %d: %v", resp.StatusCode, err)
 	}
-	err = json.NewDecoder(resp.Body).Decode(&a.token)
+	var token iamTokenResponse
+	err = json.NewDecoder(resp.Body).Decode(&token)
 	if err != nil {
 		return err
 	}
-	a.lastRefresh = time.Now()
+	a.token = token
+	now := a.clock()
+	validFor := a.token.validFor(now)
+	refreshBefore := a.refreshBefore
+	// Refresh halfway through the token's lifetime if it is too short-lived to refresh refreshBefore expiration.
+	if refreshBefore >= validFor {
+		refreshBefore = validFor / 2
+	}
+	a.refreshAt = now.Add(validFor - refreshBefore)
 	return nil
 }
 
@@ -114,6 +178,7 @@ func WithHTTPClient(c *http.Client) AuthenticatorOption {
 }
 
 // WithRefreshBeforeDuration sets the duration before expiration to trigger a token refresh.
+// Tokens which expire sooner than the duration are refreshed halfway through their lifetime.
 func WithRefreshBeforeDuration(duration time.Duration) AuthenticatorOption {
 	return func(a *authenticator) error {
 		if duration > tokenValidDuration {
@@ -123,7 +188,31 @@ func WithRefreshBeforeDuration(duration time.Duration) AuthenticatorOption {
 				tokenValidDuration,
 			)
 		}
-		a.refreshBefore = tokenValidDuration - duration
+		a.refreshBefore = duration
+		return nil
+	}
+}
+
+// WithRefreshTimeout sets the timeout for a single IAM token refresh request, DefaultRefreshTimeout by default.
+// A timeout of 0 disables the timeout, leaving only any timeout set on the http.Client.
+func WithRefreshTimeout(timeout time.Duration) AuthenticatorOption {
+	return func(a *authenticator) error {
+		if timeout < 0 {
+			return fmt.Errorf("invalid refresh timeout: %s, must not be negative", timeout)
+		}
+		a.refreshTimeout = timeout
+		return nil
+	}
+}
+
+// WithClock sets the clock used to schedule token refreshes, time.Now by default, e.g. to trigger a refresh in tests
+// by advancing a fake clock instead of sleeping.
+func WithClock(clock func() time.Time) AuthenticatorOption {
+	return func(a *authenticator) error {
+		if clock == nil {
+			return fmt.Errorf("clock cannot be nil")
+		}
+		a.clock = clock
 		return nil
 	}
 }
@@ -161,10 +250,12 @@ func Authenticator(apiKey string, options ...AuthenticatorOption) (authenticatio
 		return nil, fmt.Errorf("apikey cannot be blank")
 	}
 	a := &authenticator{
-		httpClient:    http.DefaultClient,
-		iamEndpoint:   DefaultIAMEndpoint,
-		refreshBefore: defaultRefreshBefore,
-		apiKey:        apiKey,
+		httpClient:     http.DefaultClient,
+		iamEndpoint:    DefaultIAMEndpoint,
+		refreshBefore:  DefaultRefreshBeforeExpirationDuration,
+		refreshTimeout: DefaultRefreshTimeout,
+		clock:          time.Now,
+		apiKey:         apiKey,
 	}
 	for _, o := range options {
 		if err := o(a); err != nil {
@@ -1,10 +1,15 @@
 package ibmiam
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -55,7 +60,7 @@ func TestAuthenticator(t *testing.T) {
 	}
 	gotAccessToken := strings.TrimPrefix(req.Header.Get(authentication.AuthorizationHeader), "Bearer ")
 	if gotAccessToken != wantAccessToken.AccessToken {
-		t.Errorf("got access token header: %s, want: %s", gotAccessToken, wantAccessToken)
+		t.Errorf("got access token header: %s, want: %s", gotAccessToken, wantAccessToken.AccessToken)
 	}
 }
 
@@ -72,3 +77,258 @@ func TestAuthenticatorBadOption(t *testing.T) {
 		t.Fatal("did not return an expected error")
 	}
 }
+
+func TestAuthenticatorRefreshTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+	a, err := Authenticator("foo",
+		WithIAMEndpoint(server.URL),
+		WithHTTPClient(server.Client()),
+		WithRefreshTimeout(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- a.(authentication.Refreshable).Refresh()
+	}()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got error: %v, want: %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("refresh did not time out")
+	}
+}
+
+func TestAuthenticatorRefreshContextCanceled(t *testing.T) {
+	tests := []struct {
+		name    string
+		refresh func(ctx context.Context, a authentication.Authenticator) error
+	}{
+		{
+			name: "RefreshContext",
+			refresh: func(ctx context.Context, a authentication.Authenticator) error {
+				return a.(authentication.ContextRefreshable).RefreshContext(ctx)
+			},
+		},
+		{
+			name: "Authenticate",
+			refresh: func(ctx context.Context, a authentication.Authenticator) error {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com", nil)
+				if err != nil {
+					return err
+				}
+				return a.Authenticate(req)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			started := make(chan struct{}, 1)
+			done := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				started <- struct{}{}
+				select {
+				case <-r.Context().Done():
+				case <-done:
+				}
+			}))
+			defer server.Close()
+			defer close(done)
+			a, err := Authenticator("foo",
+				WithIAMEndpoint(server.URL),
+				WithHTTPClient(server.Client()),
+				WithRefreshTimeout(0),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			errc := make(chan error, 1)
+			go func() {
+				errc <- test.refresh(ctx, a)
+			}()
+			<-started
+			cancel()
+			select {
+			case err := <-errc:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("got error: %v, want: %v", err, context.Canceled)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("refresh was not aborted by the canceled context")
+			}
+		})
+	}
+}
+
+func TestAuthenticatorBadRefreshTimeout(t *testing.T) {
+	_, err := Authenticator("foo", WithRefreshTimeout(-time.Second))
+	if err == nil {
+		t.Fatal("did not return an expected error")
+	}
+}
+
+func TestAuthenticatorConcurrentRefresh(t *testing.T) {
+	var refreshes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&refreshes, 1)
+		// Slow down the refresh so concurrent Authenticate calls find the token stale.
+		time.Sleep(50 * time.Millisecond)
+		if _, err := w.Write([]byte(`{"access_token":"bar"}`)); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+	a, err := Authenticator("foo", WithIAMEndpoint(server.URL), WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if aerr := a.Authenticate(req); aerr != nil {
+				t.Error(aerr)
+				return
+			}
+			if got := req.Header.Get(authentication.AuthorizationHeader); got != "Bearer bar" {
+				t.Errorf("got authorization header: %s, want: %s", got, "Bearer bar")
+			}
+		}()
+	}
+	wg.Wait()
+	if got := atomic.LoadInt32(&refreshes); got != 1 {
+		t.Errorf("got %d token refreshes, want 1", got)
+	}
+}
+
+func TestAuthenticatorTokenExpiry(t *testing.T) {
+	tests := []struct {
+		name          string
+		response      string
+		refreshBefore time.Duration
+		wantRefreshIn time.Duration
+	}{
+		{
+			name:          "expires_in",
+			response:      `{"access_token":"bar","expires_in":120}`,
+			refreshBefore: 30 * time.Second,
+			wantRefreshIn: 90 * time.Second,
+		},
+		{
+			name:          "expires_in shorter than refresh before duration",
+			response:      `{"access_token":"bar","expires_in":120}`,
+			refreshBefore: DefaultRefreshBeforeExpirationDuration,
+			wantRefreshIn: 60 * time.Second,
+		},
+		{
+			name:          "expiration",
+			response:      fmt.Sprintf(`{"access_token":"bar","expiration":%d}`, time.Now().Add(10*time.Minute).Unix()),
+			refreshBefore: DefaultRefreshBeforeExpirationDuration,
+			wantRefreshIn: 5 * time.Minute,
+		},
+		{
+			name:          "no expiry",
+			response:      `{"access_token":"bar"}`,
+			refreshBefore: DefaultRefreshBeforeExpirationDuration,
+			wantRefreshIn: tokenValidDuration - DefaultRefreshBeforeExpirationDuration,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := w.Write([]byte(test.response)); err != nil {
+					t.Error(err)
+				}
+			}))
+			defer server.Close()
+			a, err := Authenticator("foo",
+				WithIAMEndpoint(server.URL),
+				WithHTTPClient(server.Client()),
+				WithRefreshBeforeDuration(test.refreshBefore),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			if err := a.(authentication.Refreshable).Refresh(); err != nil {
+				t.Fatal(err)
+			}
+			refreshIn := a.(*authenticator).refreshAt.Sub(start)
+			// Allow for the time taken by the refresh and rounding of the expiration to seconds.
+			if diff := refreshIn - test.wantRefreshIn; diff < -time.Second || diff > time.Second {
+				t.Errorf("token refreshes in %s, want %s", refreshIn, test.wantRefreshIn)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorWithClock(t *testing.T) {
+	var refreshes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&refreshes, 1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600}`, n)
+	}))
+	defer server.Close()
+	now := time.Date(2022, time.March, 4, 5, 6, 7, 0, time.UTC)
+	a, err := Authenticator("foo",
+		WithIAMEndpoint(server.URL),
+		WithHTTPClient(server.Client()),
+		WithRefreshBeforeDuration(DefaultRefreshBeforeExpirationDuration),
+		WithClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticate := func() string {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Authenticate(req); err != nil {
+			t.Fatal(err)
+		}
+		return req.Header.Get(authentication.AuthorizationHeader)
+	}
+
+	steps := []struct {
+		advance time.Duration
+		want    string
+	}{
+		{0, "token-1"},
+		{54 * time.Minute, "token-1"},
+		{time.Minute, "token-2"},
+		{54 * time.Minute, "token-2"},
+		{time.Hour, "token-3"},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		if got, want := authenticate(), authentication.AuthorizationHeaderFor(step.want); got != want {
+			t.Errorf("after advancing %s got authorization: %q, want: %q", step.advance, got, want)
+		}
+	}
+
+	if _, err := Authenticator("foo", WithClock(nil)); err == nil {
+		t.Error("WithClock(nil) expected error")
+	}
+}
@@ -0,0 +1,197 @@
+package login
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trinchan/sysdig-go/sysdig/authentication"
+)
+
+const (
+	// loginPath is the path of the login endpoint, relative to the Sysdig base URL.
+	loginPath = "api/login"
+	// DefaultLoginTimeout is the default timeout for a single login request.
+	DefaultLoginTimeout = 30 * time.Second
+)
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token struct {
+		Key string `json:"key"`
+	} `json:"token"`
+}
+
+type authenticator struct {
+	httpClient   *http.Client
+	loginURL     string
+	username     string
+	password     string
+	sysdigTeamID string
+	loginTimeout time.Duration
+
+	lock  sync.RWMutex
+	token string
+}
+
+// Authenticate implements authentication.Authenticator using the session token from the login request, logging in
+// if no session token has been retrieved yet. A login made while authenticating uses the context of the request.
+func (a *authenticator) Authenticate(req *http.Request) error {
+	a.lock.RLock()
+	token := a.token
+	a.lock.RUnlock()
+	if token == "" {
+		var err error
+		if token, err = a.loginIfNeeded(req.Context()); err != nil {
+			return err
+		}
+	}
+	req.Header.Set(authentication.AuthorizationHeader, authentication.AuthorizationHeaderFor(token))
+	if a.sysdigTeamID != "" {
+		req.Header.Set(authentication.SysdigTeamIDHeader, a.sysdigTeamID)
+	}
+	return nil
+}
+
+// Refresh implements authentication.Refreshable by logging in again, e.g. when the session token has expired.
+func (a *authenticator) Refresh() error {
+	return a.RefreshContext(context.Background())
+}
+
+// RefreshContext implements authentication.ContextRefreshable by logging in again with ctx. If ctx carries the
+// authentication.FailedAuthorization of the rejected request and the session token has since been replaced, e.g. by a
+// concurrent refresh, the login is skipped.
+func (a *authenticator) RefreshContext(ctx context.Context) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if failed, ok := authentication.FailedAuthorization(ctx); ok && a.token != "" &&
+		failed != authentication.AuthorizationHeaderFor(a.token) {
+		return nil
+	}
+	return a.login(ctx)
+}
+
+// loginIfNeeded logs in unless another goroutine logged in while waiting for the lock.
+func (a *authenticator) loginIfNeeded(ctx context.Context) (string, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.token != "" {
+		return a.token, nil
+	}
+	if err := a.login(ctx); err != nil {
+		return "", err
+	}
+	return a.token, nil
+}
+
+// login retrieves a new session token from the login endpoint. a.lock must be held for writing.
+// The login is abandoned when ctx is canceled or its deadline, or the login timeout, is exceeded.
+func (a *authenticator) login(ctx context.Context) error {
+	body, err := json.Marshal(loginRequest{Username: a.username, Password: a.password})
+	if err != nil {
+		return err
+	}
+	if a.loginTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.loginTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.loginURL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to log in: %d", resp.StatusCode)
+	}
+	var login loginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		return err
+	}
+	if login.Token.Key == "" {
+		return fmt.Errorf("failed to log in: response did not include a token")
+	}
+	a.token = login.Token.Key
+	return nil
+}
+
+// AuthenticatorOption defines options for the login authentication.Authenticator.
+type AuthenticatorOption func(*authenticator) error
+
+// WithHTTPClient sets the http.Client to be used for logging in.
+func WithHTTPClient(c *http.Client) AuthenticatorOption {
+	return func(a *authenticator) error {
+		a.httpClient = c
+		return nil
+	}
+}
+
+// WithLoginTimeout sets the timeout for a single login request, DefaultLoginTimeout by default.
+// A timeout of 0 disables the timeout, leaving only any timeout set on the http.Client.
+func WithLoginTimeout(timeout time.Duration) AuthenticatorOption {
+	return func(a *authenticator) error {
+		if timeout < 0 {
+			return fmt.Errorf("invalid login timeout: %s, must not be negative", timeout)
+		}
+		a.loginTimeout = timeout
+		return nil
+	}
+}
+
+// WithSysdigTeamID sets the TeamID to be set for Sysdig requests.
+func WithSysdigTeamID(sysdigTeamID string) AuthenticatorOption {
+	return func(a *authenticator) error {
+		a.sysdigTeamID = sysdigTeamID
+		return nil
+	}
+}
+
+// Authenticator returns an authentication.Authenticator for on-premise Sysdig installations which use username and
+// password login. It logs in at the baseURL's login endpoint on the first request and caches the returned session
+// token, logging in again when the Sysdig API rejects the session token.
+func Authenticator(baseURL, username, password string, options ...AuthenticatorOption) (authentication.Authenticator, error) {
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("username and password cannot be blank")
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: must be absolute", baseURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	a := &authenticator{
+		httpClient:   http.DefaultClient,
+		loginURL:     u.ResolveReference(&url.URL{Path: loginPath}).String(),
+		username:     username,
+		password:     password,
+		loginTimeout: DefaultLoginTimeout,
+	}
+	for _, o := range options {
+		if err := o(a); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
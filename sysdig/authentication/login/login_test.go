@@ -0,0 +1,198 @@
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/trinchan/sysdig-go/sysdig/authentication"
+)
+
+func newLoginServer(t *testing.T, logins *int32) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("got method: %s, want: %s", r.Method, http.MethodPost)
+		}
+		var login loginRequest
+		if err := json.NewDecoder(r.Body).Decode(&login); err != nil {
+			t.Error(err)
+		}
+		if login.Username != "user" || login.Password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := atomic.AddInt32(logins, 1)
+		fmt.Fprintf(w, `{"token":{"key":"token%d"}}`, n)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestAuthenticator(t *testing.T) {
+	var logins int32
+	server := newLoginServer(t, &logins)
+	defer server.Close()
+
+	a, err := Authenticator(server.URL, "user", "pass", WithHTTPClient(server.Client()), WithSysdigTeamID("team"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticate := func(wantToken string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if aerr := a.Authenticate(req); aerr != nil {
+			t.Fatal(aerr)
+		}
+		if got, want := req.Header.Get(authentication.AuthorizationHeader), "Bearer "+wantToken; got != want {
+			t.Errorf("got authorization header: %s, want: %s", got, want)
+		}
+		if got := req.Header.Get(authentication.SysdigTeamIDHeader); got != "team" {
+			t.Errorf("got SysdigTeamID header: %s, want: %s", got, "team")
+		}
+	}
+
+	// The session token is cached for subsequent requests.
+	authenticate("token1")
+	authenticate("token1")
+	if got := atomic.LoadInt32(&logins); got != 1 {
+		t.Errorf("got %d logins, want 1", got)
+	}
+
+	// A refresh, as triggered by a 401 from the Sysdig API, logs in again.
+	if err := a.(authentication.Refreshable).Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	authenticate("token2")
+	if got := atomic.LoadInt32(&logins); got != 2 {
+		t.Errorf("got %d logins, want 2", got)
+	}
+}
+
+func TestAuthenticatorConcurrentRefresh(t *testing.T) {
+	var logins int32
+	server := newLoginServer(t, &logins)
+	defer server.Close()
+
+	a, err := Authenticator(server.URL, "user", "pass", WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aerr := a.Authenticate(req); aerr != nil {
+		t.Fatal(aerr)
+	}
+
+	// Every request authorized with token1 is rejected, but only the first refresh logs in again.
+	ctx := authentication.ContextWithFailedAuthorization(context.Background(), authentication.AuthorizationHeaderFor("token1"))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rerr := a.(authentication.ContextRefreshable).RefreshContext(ctx); rerr != nil {
+				t.Error(rerr)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := atomic.LoadInt32(&logins); got != 2 {
+		t.Errorf("got %d logins, want 2", got)
+	}
+}
+
+func TestAuthenticatorRefreshContextCanceled(t *testing.T) {
+	var logins int32
+	server := newLoginServer(t, &logins)
+	defer server.Close()
+
+	a, err := Authenticator(server.URL, "user", "pass", WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := a.(authentication.ContextRefreshable).RefreshContext(ctx); err == nil {
+		t.Error("RefreshContext did not return an expected error")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Authenticate(req); err == nil {
+		t.Error("Authenticate did not return an expected error")
+	}
+	if got := atomic.LoadInt32(&logins); got != 0 {
+		t.Errorf("got %d logins, want 0", got)
+	}
+}
+
+func TestAuthenticatorLoginFailure(t *testing.T) {
+	var logins int32
+	server := newLoginServer(t, &logins)
+	defer server.Close()
+
+	a, err := Authenticator(server.URL, "user", "wrong", WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Authenticate(req); err == nil {
+		t.Fatal("did not return an expected error")
+	}
+}
+
+func TestAuthenticatorBadOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		username string
+		password string
+		options  []AuthenticatorOption
+	}{
+		{name: "empty username", baseURL: "https://sysdig.example.com", password: "pass"},
+		{name: "empty password", baseURL: "https://sysdig.example.com", username: "user"},
+		{name: "relative base URL", baseURL: "sysdig.example.com", username: "user", password: "pass"},
+		{name: "invalid base URL", baseURL: "://bad", username: "user", password: "pass"},
+		{
+			name:     "negative login timeout",
+			baseURL:  "https://sysdig.example.com",
+			username: "user",
+			password: "pass",
+			options:  []AuthenticatorOption{WithLoginTimeout(-1)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Authenticator(test.baseURL, test.username, test.password, test.options...); err == nil {
+				t.Fatal("did not return an expected error")
+			}
+		})
+	}
+}
+
+func TestAuthenticatorLoginURL(t *testing.T) {
+	for _, baseURL := range []string{"https://sysdig.example.com", "https://sysdig.example.com/"} {
+		a, err := Authenticator(baseURL, "user", "pass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := a.(*authenticator).loginURL, "https://sysdig.example.com/api/login"; got != want {
+			t.Errorf("got login URL: %s, want: %s", got, want)
+		}
+	}
+}
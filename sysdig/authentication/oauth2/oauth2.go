@@ -0,0 +1,87 @@
+package oauth2
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/trinchan/sysdig-go/sysdig/authentication"
+	"golang.org/x/oauth2"
+)
+
+type authenticator struct {
+	base oauth2.TokenSource
+
+	lock sync.Mutex
+	ts   oauth2.TokenSource
+
+	ibmInstanceID string
+	sysdigTeamID  string
+}
+
+// WithIBMInstanceID sets the instance ID to be set for IBM Sysdig requests.
+// See: https://cloud.ibm.com/docs/monitoring?topic=monitoring-mon-curl#mon-curl-headers-iam
+func WithIBMInstanceID(ibmInstanceID string) AuthenticatorOption {
+	return func(a *authenticator) error {
+		a.ibmInstanceID = ibmInstanceID
+		return nil
+	}
+}
+
+// WithSysdigTeamID sets the TeamID to be set for Sysdig requests.
+func WithSysdigTeamID(sysdigTeamID string) AuthenticatorOption {
+	return func(a *authenticator) error {
+		a.sysdigTeamID = sysdigTeamID
+		return nil
+	}
+}
+
+// Authenticate implements the authentication.Authenticator interface using a token from the oauth2.TokenSource.
+func (a *authenticator) Authenticate(req *http.Request) error {
+	a.lock.Lock()
+	ts := a.ts
+	a.lock.Unlock()
+	token, err := ts.Token()
+	if err != nil {
+		return fmt.Errorf("failed to get oauth2 token: %w", err)
+	}
+	req.Header.Set(authentication.AuthorizationHeader, authentication.AuthorizationHeaderFor(token.AccessToken))
+	if a.ibmInstanceID != "" {
+		req.Header.Set(authentication.IBMInstanceIDHeader, a.ibmInstanceID)
+	}
+	if a.sysdigTeamID != "" {
+		req.Header.Set(authentication.SysdigTeamIDHeader, a.sysdigTeamID)
+	}
+	return nil
+}
+
+// Refresh implements authentication.Refreshable by discarding the cached token, so the next request fetches a new
+// token from the oauth2.TokenSource.
+func (a *authenticator) Refresh() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.ts = oauth2.ReuseTokenSource(nil, a.base)
+	return nil
+}
+
+// AuthenticatorOption defines the type for passing options to the Authenticator constructor.
+type AuthenticatorOption func(*authenticator) error
+
+// Authenticator implements the authentication.Authenticator interface using tokens from an oauth2.TokenSource.
+// Tokens are cached until they expire or the Sysdig API rejects them. For a rejected token to be replaced, ts should
+// fetch a new token on each call rather than being a caching oauth2.TokenSource such as oauth2.ReuseTokenSource.
+func Authenticator(ts oauth2.TokenSource, options ...AuthenticatorOption) (authentication.Authenticator, error) {
+	if ts == nil {
+		return nil, fmt.Errorf("token source must be set")
+	}
+	a := &authenticator{
+		base: ts,
+		ts:   oauth2.ReuseTokenSource(nil, ts),
+	}
+	for _, o := range options {
+		if err := o(a); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
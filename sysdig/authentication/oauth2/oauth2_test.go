@@ -0,0 +1,108 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trinchan/sysdig-go/sysdig/authentication"
+	"golang.org/x/oauth2"
+)
+
+// rotatingTokenSource returns a new token on each call.
+type rotatingTokenSource struct {
+	calls int
+}
+
+func (ts *rotatingTokenSource) Token() (*oauth2.Token, error) {
+	ts.calls++
+	return &oauth2.Token{
+		AccessToken: fmt.Sprintf("token%d", ts.calls),
+		Expiry:      time.Now().Add(time.Hour),
+	}, nil
+}
+
+func authenticate(t *testing.T, a authentication.Authenticator) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aerr := a.Authenticate(req); aerr != nil {
+		t.Fatal(aerr)
+	}
+	return req
+}
+
+func TestAuthenticator(t *testing.T) {
+	wantIBMIDHeader := "ibmiam"
+	wantSysdigTeamID := "ibm"
+	ts := &rotatingTokenSource{}
+	a, err := Authenticator(ts, WithIBMInstanceID(wantIBMIDHeader), WithSysdigTeamID(wantSysdigTeamID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := authenticate(t, a)
+	gotIBMIDHeader := req.Header.Get(authentication.IBMInstanceIDHeader)
+	if gotIBMIDHeader != wantIBMIDHeader {
+		t.Errorf("got IBMInstanceID header: %s, want: %s", gotIBMIDHeader, wantIBMIDHeader)
+	}
+	gotSysdigTeamID := req.Header.Get(authentication.SysdigTeamIDHeader)
+	if gotSysdigTeamID != wantSysdigTeamID {
+		t.Errorf("got SysdigTeamID header: %s, want: %s", gotSysdigTeamID, wantSysdigTeamID)
+	}
+
+	for _, want := range []string{"token1", "token1"} {
+		req := authenticate(t, a)
+		if got := strings.TrimPrefix(req.Header.Get(authentication.AuthorizationHeader), "Bearer "); got != want {
+			t.Errorf("got access token header: %s, want: %s", got, want)
+		}
+	}
+	if err := a.(authentication.Refreshable).Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	req = authenticate(t, a)
+	if got, want := strings.TrimPrefix(req.Header.Get(authentication.AuthorizationHeader), "Bearer "), "token2"; got != want {
+		t.Errorf("got access token header after refresh: %s, want: %s", got, want)
+	}
+}
+
+func TestAuthenticatorTokenError(t *testing.T) {
+	wantErr := errors.New("token error")
+	a, err := Authenticator(errorTokenSource{wantErr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Authenticate(req); !errors.Is(err, wantErr) {
+		t.Errorf("got error: %v, want: %v", err, wantErr)
+	}
+}
+
+type errorTokenSource struct {
+	err error
+}
+
+func (ts errorTokenSource) Token() (*oauth2.Token, error) {
+	return nil, ts.err
+}
+
+func TestAuthenticatorEmpty(t *testing.T) {
+	_, err := Authenticator(nil)
+	if err == nil {
+		t.Fatal("did not return an expected error")
+	}
+}
+
+func TestAuthenticatorBadOption(t *testing.T) {
+	_, err := Authenticator(&rotatingTokenSource{}, func(a *authenticator) error { return errors.New("test error") })
+	if err == nil {
+		t.Fatal("did not return an expected error")
+	}
+}
@@ -0,0 +1,272 @@
+package tokenexchange
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/trinchan/sysdig-go/sysdig/authentication"
+)
+
+const (
+	// GrantTypeTokenExchange is the OAuth 2.0 token exchange grant type.
+	// See: https://datatracker.ietf.org/doc/html/rfc8693
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	// SubjectTokenTypeJWT is the subject token type for a JWT bearer assertion.
+	SubjectTokenTypeJWT = "urn:ietf:params:oauth:token-type:jwt"
+	// SubjectTokenTypeSAML2 is the subject token type for a SAML 2.0 assertion.
+	SubjectTokenTypeSAML2 = "urn:ietf:params:oauth:token-type:saml2"
+
+	// DefaultRefreshBeforeExpirationDuration is the default duration before expected expiration to refresh the token.
+	DefaultRefreshBeforeExpirationDuration = time.Minute
+	// DefaultTokenValidDuration is the validity period assumed for session tokens when the exchange response does not
+	// include expires_in.
+	DefaultTokenValidDuration = 15 * time.Minute
+	// DefaultRefreshTimeout is the default timeout for a single token exchange request.
+	DefaultRefreshTimeout = 30 * time.Second
+)
+
+// AssertionSource returns the external assertion to exchange for a Sysdig session token. It is called on each
+// exchange so short-lived assertions can be renewed.
+type AssertionSource func() (string, error)
+
+// StaticAssertion returns an AssertionSource which always returns the given assertion.
+func StaticAssertion(assertion string) AssertionSource {
+	return func() (string, error) {
+		return assertion, nil
+	}
+}
+
+type exchangeResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type authenticator struct {
+	httpClient       *http.Client
+	endpoint         string
+	assertion        AssertionSource
+	subjectTokenType string
+	ibmInstanceID    string
+	sysdigTeamID     string
+	refreshBefore    time.Duration
+	refreshTimeout   time.Duration
+	clock            func() time.Time
+
+	lock      sync.RWMutex
+	token     string
+	expiresAt time.Time
+}
+
+// Authenticate implements authentication.Authenticator using the exchanged Sysdig session token, exchanging a new
+// token when none has been exchanged yet or the current token is about to expire.
+// A token exchange made while authenticating uses the context of the request.
+func (a *authenticator) Authenticate(req *http.Request) error {
+	a.lock.RLock()
+	stale := a.stale()
+	token := a.token
+	a.lock.RUnlock()
+	if stale {
+		if err := a.exchangeIfStale(req.Context()); err != nil {
+			return err
+		}
+		a.lock.RLock()
+		token = a.token
+		a.lock.RUnlock()
+	}
+
+	req.Header.Set(authentication.AuthorizationHeader, authentication.AuthorizationHeaderFor(token))
+	if a.ibmInstanceID != "" {
+		req.Header.Set(authentication.IBMInstanceIDHeader, a.ibmInstanceID)
+	}
+	if a.sysdigTeamID != "" {
+		req.Header.Set(authentication.SysdigTeamIDHeader, a.sysdigTeamID)
+	}
+	return nil
+}
+
+// Refresh implements authentication.Refreshable by exchanging a new assertion for a Sysdig session token.
+func (a *authenticator) Refresh() error {
+	return a.RefreshContext(context.Background())
+}
+
+// RefreshContext implements authentication.ContextRefreshable by exchanging a new assertion for a Sysdig session
+// token. The exchange is abandoned when ctx is canceled or its deadline, or the refresh timeout, is exceeded.
+func (a *authenticator) RefreshContext(ctx context.Context) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.exchange(ctx)
+}
+
+// stale reports whether a token should be exchanged. a.lock must be held.
+func (a *authenticator) stale() bool {
+	return a.token == "" || a.expiresAt.Sub(a.clock()) < a.refreshBefore
+}
+
+// exchangeIfStale exchanges a token unless another goroutine exchanged it while waiting for the lock.
+func (a *authenticator) exchangeIfStale(ctx context.Context) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if !a.stale() {
+		return nil
+	}
+	return a.exchange(ctx)
+}
+
+// exchange exchanges an assertion for a new token. a.lock must be held for writing.
+func (a *authenticator) exchange(ctx context.Context) error {
+	assertion, err := a.assertion()
+	if err != nil {
+		return fmt.Errorf("failed to get assertion: %w", err)
+	}
+	v := url.Values{
+		"grant_type":         []string{GrantTypeTokenExchange},
+		"subject_token":      []string{assertion},
+		"subject_token_type": []string{a.subjectTokenType},
+	}
+	if a.refreshTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.refreshTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint, bytes.NewBufferString(v.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to exchange token: %d", resp.StatusCode)
+	}
+	var token exchangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("failed to exchange token: response did not include an access token")
+	}
+	validFor := DefaultTokenValidDuration
+	if token.ExpiresIn > 0 {
+		validFor = time.Duration(token.ExpiresIn) * time.Second
+	}
+	a.token = token.AccessToken
+	a.expiresAt = a.clock().Add(validFor)
+	return nil
+}
+
+// AuthenticatorOption defines options for the token exchange authentication.Authenticator.
+type AuthenticatorOption func(*authenticator) error
+
+// WithHTTPClient sets the http.Client to be used for token exchange.
+func WithHTTPClient(c *http.Client) AuthenticatorOption {
+	return func(a *authenticator) error {
+		a.httpClient = c
+		return nil
+	}
+}
+
+// WithSubjectTokenType sets the type of the exchanged assertion, SubjectTokenTypeJWT by default.
+func WithSubjectTokenType(subjectTokenType string) AuthenticatorOption {
+	return func(a *authenticator) error {
+		if subjectTokenType == "" {
+			return fmt.Errorf("subject token type cannot be blank")
+		}
+		a.subjectTokenType = subjectTokenType
+		return nil
+	}
+}
+
+// WithRefreshBeforeDuration sets the duration before expiration to trigger a token exchange.
+func WithRefreshBeforeDuration(duration time.Duration) AuthenticatorOption {
+	return func(a *authenticator) error {
+		if duration < 0 {
+			return fmt.Errorf("invalid refresh before duration: %s, must not be negative", duration)
+		}
+		a.refreshBefore = duration
+		return nil
+	}
+}
+
+// WithRefreshTimeout sets the timeout for a single token exchange request, DefaultRefreshTimeout by default.
+// A timeout of 0 disables the timeout, leaving only any timeout set on the http.Client.
+func WithRefreshTimeout(timeout time.Duration) AuthenticatorOption {
+	return func(a *authenticator) error {
+		if timeout < 0 {
+			return fmt.Errorf("invalid refresh timeout: %s, must not be negative", timeout)
+		}
+		a.refreshTimeout = timeout
+		return nil
+	}
+}
+
+// WithClock sets the clock used to schedule token exchanges, time.Now by default, e.g. to trigger an exchange in tests
+// by advancing a fake clock instead of sleeping.
+func WithClock(clock func() time.Time) AuthenticatorOption {
+	return func(a *authenticator) error {
+		if clock == nil {
+			return fmt.Errorf("clock cannot be nil")
+		}
+		a.clock = clock
+		return nil
+	}
+}
+
+// WithIBMInstanceID sets the instance ID to be set for IBM Sysdig requests.
+// See: https://cloud.ibm.com/docs/monitoring?topic=monitoring-mon-curl#mon-curl-headers-iam
+func WithIBMInstanceID(ibmInstanceID string) AuthenticatorOption {
+	return func(a *authenticator) error {
+		a.ibmInstanceID = ibmInstanceID
+		return nil
+	}
+}
+
+// WithSysdigTeamID sets the TeamID to be set for Sysdig requests.
+func WithSysdigTeamID(sysdigTeamID string) AuthenticatorOption {
+	return func(a *authenticator) error {
+		a.sysdigTeamID = sysdigTeamID
+		return nil
+	}
+}
+
+// Authenticator returns an authentication.Authenticator which exchanges assertions from the AssertionSource for
+// Sysdig session tokens at the given exchange endpoint. Tokens are cached and exchanged again shortly before they
+// expire, or when the Sysdig API rejects them.
+func Authenticator(
+	endpoint string,
+	assertion AssertionSource,
+	options ...AuthenticatorOption) (authentication.Authenticator, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("exchange endpoint cannot be blank")
+	}
+	if assertion == nil {
+		return nil, fmt.Errorf("assertion source cannot be nil")
+	}
+	a := &authenticator{
+		httpClient:       http.DefaultClient,
+		endpoint:         endpoint,
+		assertion:        assertion,
+		subjectTokenType: SubjectTokenTypeJWT,
+		refreshBefore:    DefaultRefreshBeforeExpirationDuration,
+		refreshTimeout:   DefaultRefreshTimeout,
+		clock:            time.Now,
+	}
+	for _, o := range options {
+		if err := o(a); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
@@ -0,0 +1,341 @@
+package tokenexchange
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/trinchan/sysdig-go/sysdig/authentication"
+)
+
+func TestAuthenticator(t *testing.T) {
+	var exchanges int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if got := r.PostForm.Get("grant_type"); got != GrantTypeTokenExchange {
+			t.Errorf("got grant_type: %s, want: %s", got, GrantTypeTokenExchange)
+		}
+		if got := r.PostForm.Get("subject_token_type"); got != SubjectTokenTypeSAML2 {
+			t.Errorf("got subject_token_type: %s, want: %s", got, SubjectTokenTypeSAML2)
+		}
+		if got := r.PostForm.Get("subject_token"); got != "assertion" {
+			t.Errorf("got subject_token: %s, want: %s", got, "assertion")
+		}
+		n := atomic.AddInt32(&exchanges, 1)
+		b, err := json.Marshal(exchangeResponse{AccessToken: "token" + string(rune('0'+n)), ExpiresIn: 3600})
+		if err != nil {
+			t.Error(err)
+		}
+		if _, werr := w.Write(b); werr != nil {
+			t.Error(werr)
+		}
+	}))
+	defer server.Close()
+
+	a, err := Authenticator(server.URL, StaticAssertion("assertion"),
+		WithHTTPClient(server.Client()),
+		WithSubjectTokenType(SubjectTokenTypeSAML2),
+		WithIBMInstanceID("instance"),
+		WithSysdigTeamID("team"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticate := func(wantToken string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if aerr := a.Authenticate(req); aerr != nil {
+			t.Fatal(aerr)
+		}
+		want := map[string]string{
+			authentication.AuthorizationHeader: authentication.AuthorizationHeaderFor(wantToken),
+			authentication.IBMInstanceIDHeader: "instance",
+			authentication.SysdigTeamIDHeader:  "team",
+		}
+		for header, value := range want {
+			if got := req.Header.Get(header); got != value {
+				t.Errorf("got %s header: %s, want: %s", header, got, value)
+			}
+		}
+	}
+
+	// The initial exchange is cached for subsequent requests.
+	authenticate("token1")
+	authenticate("token1")
+	if got := atomic.LoadInt32(&exchanges); got != 1 {
+		t.Errorf("got %d exchanges, want 1", got)
+	}
+
+	// A refresh, as triggered by an authentication error, exchanges a new token.
+	if err := a.(authentication.Refreshable).Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	authenticate("token2")
+	if got := atomic.LoadInt32(&exchanges); got != 2 {
+		t.Errorf("got %d exchanges, want 2", got)
+	}
+}
+
+func TestAuthenticatorExpiredToken(t *testing.T) {
+	var exchanges int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&exchanges, 1)
+		if _, err := w.Write([]byte(`{"access_token":"token","expires_in":30}`)); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	a, err := Authenticator(server.URL, StaticAssertion("assertion"),
+		WithHTTPClient(server.Client()),
+		WithRefreshBeforeDuration(time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if aerr := a.Authenticate(req); aerr != nil {
+			t.Fatal(aerr)
+		}
+	}
+	// The token expires within the refresh before duration, so each request exchanges a new token.
+	if got := atomic.LoadInt32(&exchanges); got != 2 {
+		t.Errorf("got %d exchanges, want 2", got)
+	}
+}
+
+func TestAuthenticatorConcurrentExchange(t *testing.T) {
+	var exchanges int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&exchanges, 1)
+		// Slow down the exchange so concurrent Authenticate calls find the token stale.
+		time.Sleep(50 * time.Millisecond)
+		if _, err := w.Write([]byte(`{"access_token":"token"}`)); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+	a, err := Authenticator(server.URL, StaticAssertion("assertion"), WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if aerr := a.Authenticate(req); aerr != nil {
+				t.Error(aerr)
+				return
+			}
+			if got, want := req.Header.Get(authentication.AuthorizationHeader), authentication.AuthorizationHeaderFor("token"); got != want {
+				t.Errorf("got authorization header: %s, want: %s", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := atomic.LoadInt32(&exchanges); got != 1 {
+		t.Errorf("got %d token exchanges, want 1", got)
+	}
+}
+
+func TestAuthenticatorRefreshContextCanceled(t *testing.T) {
+	tests := []struct {
+		name    string
+		refresh func(ctx context.Context, a authentication.Authenticator) error
+	}{
+		{
+			name: "RefreshContext",
+			refresh: func(ctx context.Context, a authentication.Authenticator) error {
+				return a.(authentication.ContextRefreshable).RefreshContext(ctx)
+			},
+		},
+		{
+			name: "Authenticate",
+			refresh: func(ctx context.Context, a authentication.Authenticator) error {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com", nil)
+				if err != nil {
+					return err
+				}
+				return a.Authenticate(req)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			started := make(chan struct{}, 1)
+			done := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				started <- struct{}{}
+				select {
+				case <-r.Context().Done():
+				case <-done:
+				}
+			}))
+			defer server.Close()
+			defer close(done)
+			a, err := Authenticator(server.URL, StaticAssertion("assertion"),
+				WithHTTPClient(server.Client()),
+				WithRefreshTimeout(0),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			errc := make(chan error, 1)
+			go func() {
+				errc <- test.refresh(ctx, a)
+			}()
+			<-started
+			cancel()
+			select {
+			case err := <-errc:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("got error: %v, want: %v", err, context.Canceled)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("exchange was not aborted by the canceled context")
+			}
+		})
+	}
+}
+
+func TestAuthenticatorWithClock(t *testing.T) {
+	var exchanges int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&exchanges, 1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600}`, n)
+	}))
+	defer server.Close()
+	now := time.Date(2022, time.March, 4, 5, 6, 7, 0, time.UTC)
+	a, err := Authenticator(server.URL, StaticAssertion("assertion"),
+		WithHTTPClient(server.Client()),
+		WithRefreshBeforeDuration(DefaultRefreshBeforeExpirationDuration),
+		WithClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticate := func() string {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Authenticate(req); err != nil {
+			t.Fatal(err)
+		}
+		return req.Header.Get(authentication.AuthorizationHeader)
+	}
+
+	steps := []struct {
+		advance time.Duration
+		want    string
+	}{
+		{0, "token-1"},
+		{58 * time.Minute, "token-1"},
+		{2 * time.Minute, "token-2"},
+		{58 * time.Minute, "token-2"},
+		{time.Hour, "token-3"},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		if got, want := authenticate(), authentication.AuthorizationHeaderFor(step.want); got != want {
+			t.Errorf("after advancing %s got authorization: %q, want: %q", step.advance, got, want)
+		}
+	}
+}
+
+func TestAuthenticatorExchangeFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	a, err := Authenticator(server.URL, StaticAssertion("assertion"), WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Authenticate(req); err == nil {
+		t.Fatal("did not return an expected error")
+	}
+
+	wantErr := errors.New("no assertion")
+	a, err = Authenticator(server.URL, func() (string, error) { return "", wantErr })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Authenticate(req); !errors.Is(err, wantErr) {
+		t.Errorf("got error: %v, want: %v", err, wantErr)
+	}
+}
+
+func TestAuthenticatorBadOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoint  string
+		assertion AssertionSource
+		options   []AuthenticatorOption
+	}{
+		{name: "empty endpoint", assertion: StaticAssertion("assertion")},
+		{name: "nil assertion source", endpoint: "https://example.com"},
+		{
+			name:      "empty subject token type",
+			endpoint:  "https://example.com",
+			assertion: StaticAssertion("assertion"),
+			options:   []AuthenticatorOption{WithSubjectTokenType("")},
+		},
+		{
+			name:      "negative refresh before duration",
+			endpoint:  "https://example.com",
+			assertion: StaticAssertion("assertion"),
+			options:   []AuthenticatorOption{WithRefreshBeforeDuration(-time.Second)},
+		},
+		{
+			name:      "negative refresh timeout",
+			endpoint:  "https://example.com",
+			assertion: StaticAssertion("assertion"),
+			options:   []AuthenticatorOption{WithRefreshTimeout(-time.Second)},
+		},
+		{
+			name:      "nil clock",
+			endpoint:  "https://example.com",
+			assertion: StaticAssertion("assertion"),
+			options:   []AuthenticatorOption{WithClock(nil)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Authenticator(test.endpoint, test.assertion, test.options...); err == nil {
+				t.Fatal("did not return an expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package sysdig
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// CaptureService is the Service for communicating with the Sysdig Capture related API.
+// Captures require the Team to have Team.CanUseSysdigCapture enabled.
+// See: https://docs.sysdig.com/en/docs/sysdig-monitor/captures/
+type CaptureService service
+
+// CaptureStatus is the status of a Capture.
+type CaptureStatus string
+
+const (
+	// CaptureStatusRequested is a Capture which has been requested but not yet started by the agent.
+	CaptureStatusRequested CaptureStatus = "requested"
+	// CaptureStatusCapturing is a Capture which is currently being recorded by the agent.
+	CaptureStatusCapturing CaptureStatus = "capturing"
+	// CaptureStatusUploading is a Capture which has been recorded and is being uploaded.
+	CaptureStatusUploading CaptureStatus = "uploading"
+	// CaptureStatusDone is a Capture which has been recorded and uploaded.
+	CaptureStatusDone CaptureStatus = "done"
+	// CaptureStatusError is a Capture which failed.
+	CaptureStatusError CaptureStatus = "error"
+)
+
+// Capture describes a Sysdig capture (scap) file recorded by an agent.
+type Capture struct {
+	ID        int           `json:"id"`
+	Name      string        `json:"name"`
+	HostName  string        `json:"hostName"`
+	AgentID   string        `json:"agentId"`
+	Duration  int           `json:"duration"`
+	Filter    string        `json:"filters"`
+	Status    CaptureStatus `json:"status"`
+	Size      int64         `json:"size"`
+	Error     string        `json:"error"`
+	StartTime MilliTime     `json:"startTime"`
+	CreatedOn MilliTime     `json:"createdOn"`
+}
+
+// CaptureOptions are the parameters that make up a Capture. To be used with CaptureService.Create.
+type CaptureOptions struct {
+	// Name is the name of the capture file.
+	Name string `json:"name"`
+	// HostName is the name of the host whose agent will record the capture.
+	HostName string `json:"hostName"`
+	// Duration is the duration of the capture in seconds.
+	Duration int `json:"duration"`
+	// Filter is an optional sysdig filter to limit the captured events, e.g. "proc.name=nginx".
+	Filter string `json:"filters,omitempty"`
+}
+
+// CaptureResponse is a container for a Capture returned by the CaptureService API.
+type CaptureResponse struct {
+	Capture Capture `json:"capture"`
+}
+
+// Get retrieves a Capture.
+func (s *CaptureService) Get(ctx context.Context, captureID int) (*CaptureResponse, *http.Response, error) {
+	u := fmt.Sprintf("api/sysdig/captures/%d", captureID)
+	req, err := s.client.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := new(CaptureResponse)
+	resp, err := s.client.Do(ctx, req, c)
+	return c, resp, err
+}
+
+// ListCapturesResponse is a container for Captures returned by the CaptureService.List API.
+type ListCapturesResponse struct {
+	Captures []Capture `json:"captures"`
+}
+
+// List lists all Captures.
+func (s *CaptureService) List(ctx context.Context) (*ListCapturesResponse, *http.Response, error) {
+	u := "api/sysdig/captures"
+	req, err := s.client.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := new(ListCapturesResponse)
+	resp, err := s.client.Do(ctx, req, c)
+	return c, resp, err
+}
+
+// Create requests a new Capture.
+func (s *CaptureService) Create(ctx context.Context, capture CaptureOptions) (*CaptureResponse, *http.Response, error) {
+	return s.create(ctx, capture, "")
+}
+
+// CreateWithKey requests a new Capture like Create, sending key in the IdempotencyKeyHeader.
+func (s *CaptureService) CreateWithKey(
+	ctx context.Context,
+	capture CaptureOptions,
+	key string) (*CaptureResponse, *http.Response, error) {
+	if key == "" {
+		return nil, nil, fmt.Errorf("idempotency key cannot be blank")
+	}
+	return s.create(ctx, capture, key)
+}
+
+func (s *CaptureService) create(ctx context.Context, capture CaptureOptions, key string) (*CaptureResponse, *http.Response, error) {
+	type captureRequest struct {
+		Capture CaptureOptions `json:"capture"`
+	}
+	u := "api/sysdig/captures"
+	req, err := s.client.NewRequest(http.MethodPost, u, captureRequest{capture})
+	if err != nil {
+		return nil, nil, err
+	}
+	s.client.setIdempotencyKey(req, key)
+	c := new(CaptureResponse)
+	resp, err := s.client.Do(ctx, req, c)
+	return c, resp, err
+}
+
+// Delete deletes a Capture.
+func (s *CaptureService) Delete(ctx context.Context, captureID int) (*http.Response, error) {
+	u := fmt.Sprintf("api/sysdig/captures/%d", captureID)
+	req, err := s.client.NewRequest(http.MethodDelete, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(ctx, req, nil)
+	return resp, err
+}
@@ -0,0 +1,217 @@
+package sysdig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCaptureService_Create(t *testing.T) {
+	methodName := "Create"
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/sysdig/captures", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	})
+	defer teardown()
+
+	type captureRequest struct {
+		Capture CaptureOptions `json:"capture"`
+	}
+
+	handlerFor := func(options CaptureOptions, output string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, http.MethodPost)
+			var v captureRequest
+			err := json.NewDecoder(r.Body).Decode(&v)
+			if err != nil {
+				t.Errorf("failed to decode test case: %v", err)
+				t.FailNow()
+			}
+			creq := captureRequest{options}
+			if !cmp.Equal(v, creq) {
+				t.Errorf("Request body = %+v, want %+v", v, creq)
+			}
+			fmt.Fprint(w, output)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		options CaptureOptions
+		output  string
+		want    *CaptureResponse
+	}{
+		{
+			name:    "test",
+			options: CaptureOptions{Name: "test.scap", HostName: "host-1", Duration: 30},
+			output:  `{"capture":{"id":1,"name":"test.scap","hostName":"host-1","duration":30,"status":"requested"}}`,
+			want: &CaptureResponse{Capture: Capture{
+				ID:       1,
+				Name:     "test.scap",
+				HostName: "host-1",
+				Duration: 30,
+				Status:   CaptureStatusRequested,
+			}},
+		},
+		{
+			name:    "test w/ filter",
+			options: CaptureOptions{Name: "test.scap", HostName: "host-1", Duration: 30, Filter: "proc.name=nginx"},
+			output:  `{"capture":{"id":1,"filters":"proc.name=nginx"}}`,
+			want:    &CaptureResponse{Capture: Capture{ID: 1, Filter: "proc.name=nginx"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h = handlerFor(test.options, test.output)
+			ctx := context.Background()
+			capture, _, err := client.Captures.Create(ctx, test.options)
+			if err != nil {
+				t.Errorf("Captures.Create returned error: %v", err)
+			}
+			if !cmp.Equal(capture, test.want) {
+				t.Errorf("Captures.Create returned %+v, want %+v", capture, test.want)
+			}
+		})
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		got, resp, err := client.Captures.Create(context.Background(), tests[0].options)
+		if got != nil {
+			t.Errorf("testNewRequestAndDoFailure %v = %#v, want nil", methodName, got)
+		}
+		return resp, err
+	})
+}
+
+func TestCaptureService_List(t *testing.T) {
+	methodName := "List"
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/sysdig/captures", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	})
+	defer teardown()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "test",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				fmt.Fprint(w, `{"captures":[{"id":1,"status":"done","size":1024}]}`)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h = test.handler
+			ctx := context.Background()
+			captures, _, err := client.Captures.List(ctx)
+			if err != nil {
+				t.Errorf("Captures.List returned error: %v", err)
+			}
+			want := &ListCapturesResponse{
+				Captures: []Capture{{ID: 1, Status: CaptureStatusDone, Size: 1024}},
+			}
+			if !cmp.Equal(captures, want) {
+				t.Errorf("Captures.List returned %+v, want %+v", captures, want)
+			}
+		})
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		got, resp, err := client.Captures.List(context.Background())
+		if got != nil {
+			t.Errorf("testNewRequestAndDoFailure %v = %#v, want nil", methodName, got)
+		}
+		return resp, err
+	})
+}
+
+func TestCaptureService_Get(t *testing.T) {
+	methodName := "Get"
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/sysdig/captures/", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	})
+	defer teardown()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "test",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				fmt.Fprint(w, `{"capture":{"id":1}}`)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h = test.handler
+			ctx := context.Background()
+			capture, _, err := client.Captures.Get(ctx, 1)
+			if err != nil {
+				t.Errorf("Captures.Get returned error: %v", err)
+			}
+			want := &CaptureResponse{Capture: Capture{ID: 1}}
+			if !cmp.Equal(capture, want) {
+				t.Errorf("Captures.Get returned %+v, want %+v", capture, want)
+			}
+		})
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Captures.Get(context.Background(), 1)
+		return resp, err
+	})
+}
+
+func TestCaptureService_Delete(t *testing.T) {
+	methodName := "Delete"
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/sysdig/captures/", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	})
+	defer teardown()
+
+	tests := []struct {
+		name    string
+		id      int
+		handler http.HandlerFunc
+	}{
+		{
+			name: "test",
+			id:   1,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodDelete)
+				w.WriteHeader(http.StatusNoContent)
+			},
+		},
+	}
+	for _, test := range tests {
+		h = test.handler
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := client.Captures.Delete(ctx, test.id)
+			if err != nil {
+				t.Errorf("Captures.Delete returned error: %v", err)
+			}
+		})
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		return client.Captures.Delete(context.Background(), tests[0].id)
+	})
+}
@@ -1,9 +1,19 @@
 package sysdig
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/trinchan/sysdig-go/sysdig/scope"
 )
 
 // DashboardService is the Service for communicating with the Sysdig Monitor Dashboard related API.
@@ -12,22 +22,22 @@ type DashboardService service
 // Dashboard is the structure for a Sysdig Dashboard.
 // See: https://docs.sysdig.com/en/docs/sysdig-monitor/dashboards/
 type Dashboard struct {
-	ID                   int                  `json:"id,omitempty"`
-	TeamID               int                  `json:"teamId"`
-	UserID               int                  `json:"userId,omitempty"`
+	ID                   int                  `json:"id,omitempty" sysdig:"server-managed"`
+	TeamID               int                  `json:"teamId" sysdig:"environment"`
+	UserID               int                  `json:"userId,omitempty" sysdig:"environment"`
 	Name                 string               `json:"name"`
 	Panels               []Panel              `json:"panels"`
 	EventDisplaySettings EventDisplaySettings `json:"eventDisplaySettings"`
 	Shared               bool                 `json:"shared"`
 	Public               bool                 `json:"public"`
-	Version              int                  `json:"version,omitempty"`
-	CreatedOn            MilliTime            `json:"createdOn"`
-	ModifiedOn           MilliTime            `json:"modifiedOn"`
+	Version              int                  `json:"version,omitempty" sysdig:"server-managed"`
+	CreatedOn            MilliTime            `json:"createdOn" sysdig:"server-managed"`
+	ModifiedOn           MilliTime            `json:"modifiedOn" sysdig:"server-managed"`
 	Description          string               `json:"description"`
 	Layout               []Layout             `json:"layout"`
 	SharingSettings      []SharingSetting     `json:"sharingSettings"`
 	PublicNotation       bool                 `json:"publicNotation"`
-	PublicToken          string               `json:"publicToken"`
+	PublicToken          string               `json:"publicToken" sysdig:"environment"`
 	Favorite             bool                 `json:"favorite"`
 	Schema               int                  `json:"schema"`
 	Username             string               `json:"username"`
@@ -43,6 +53,110 @@ func NewDashboard(name string) *Dashboard {
 	}
 }
 
+// publicDashboardFragment is the URL fragment of a public Dashboard, formatted with the Dashboard's PublicToken.
+const publicDashboardFragment = "/public/dashboard/%s"
+
+// PublicURL returns the shareable link for a public Dashboard, relative to the given baseURL, usually Client.BaseURL.
+// An error is returned if the Dashboard is not public or has no PublicToken.
+func (d *Dashboard) PublicURL(baseURL *url.URL) (string, error) {
+	if baseURL == nil {
+		return "", errors.New("base URL cannot be nil")
+	}
+	if !d.Public {
+		return "", fmt.Errorf("dashboard %q is not public", d.Name)
+	}
+	if d.PublicToken == "" {
+		return "", fmt.Errorf("dashboard %q has no public token", d.Name)
+	}
+	// Keep the path of baseURL so links work behind a reverse proxy serving Sysdig beneath a path prefix.
+	p := baseURL.Path
+	if !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	u := url.URL{
+		Scheme:   baseURL.Scheme,
+		Host:     baseURL.Host,
+		Path:     p,
+		Fragment: fmt.Sprintf(publicDashboardFragment, url.PathEscape(d.PublicToken)),
+	}
+	return u.String(), nil
+}
+
+// scopeExpressionOperators maps scope.Selector to the operators used in a Dashboard ScopeExpression.
+var scopeExpressionOperators = map[scope.Selector]string{
+	scope.SelectionIs:             "equals",
+	scope.SelectionIsNot:          "notEquals",
+	scope.SelectionIn:             "in",
+	scope.SelectionNotIn:          "notIn",
+	scope.SelectionContains:       "contains",
+	scope.SelectionDoesNotContain: "notContains",
+	scope.SelectionStartsWith:     "startsWith",
+}
+
+// exportExcludedFields are the JSON fields of a Dashboard which are specific to the environment it was retrieved from
+// and are excluded by Dashboard.MarshalExport.
+var exportExcludedFields = taggedJSONFields(reflect.TypeOf(Dashboard{}), serverManagedTag, environmentTag)
+
+// MarshalExport returns the Dashboard as indented JSON suitable for version control, without the fields specific to
+// the environment it was retrieved from: ID, TeamID, UserID, Version, PublicToken, CreatedOn and ModifiedOn.
+// Use ParseDashboard to load the exported Dashboard.
+func (d *Dashboard) MarshalExport() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for _, f := range exportExcludedFields {
+		delete(fields, f)
+	}
+	b, err = json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// ParseDashboard reads a Dashboard exported with Dashboard.MarshalExport. Fields specific to the environment a
+// Dashboard was retrieved from are cleared, so the Dashboard is ready to be used with DashboardService.Create.
+func ParseDashboard(r io.Reader) (*Dashboard, error) {
+	d := new(Dashboard)
+	if err := json.NewDecoder(r).Decode(d); err != nil {
+		return nil, fmt.Errorf("failed to parse dashboard: %w", err)
+	}
+	stripTagged(d, serverManagedTag, environmentTag)
+	return d, nil
+}
+
+// SetScope replaces the Dashboard scope and the scope of every BasicQuery in its Panels with the provided Scope.
+// BasicQueries which extend the Dashboard scope inherit the new Dashboard scope and are left unchanged.
+// A nil Scope removes the scope.
+func (d *Dashboard) SetScope(s *scope.Scope) {
+	selections := s.Selections()
+	d.ScopeExpressionList = nil
+	expressions := make([]string, 0, len(selections))
+	for _, selection := range selections {
+		d.ScopeExpressionList = append(d.ScopeExpressionList, ScopeExpression{
+			Operand:     selection.Label,
+			Operator:    scopeExpressionOperators[selection.Selector],
+			DisplayName: selection.Label,
+			Value:       selection.Values,
+		})
+		expressions = append(expressions, selection.String())
+	}
+	for i := range d.Panels {
+		for j := range d.Panels[i].BasicQueries {
+			queryScope := &d.Panels[i].BasicQueries[j].Scope
+			if queryScope.ExtendsDashboardScope {
+				continue
+			}
+			queryScope.Expressions = append([]string(nil), expressions...)
+		}
+	}
+}
+
 // DashboardResponse is a container for a Dashboard returned by the DashboardService API.
 type DashboardResponse struct {
 	Dashboard Dashboard `json:"dashboard"`
@@ -77,8 +191,113 @@ func (s *DashboardService) List(ctx context.Context) (*ListDashboardsResponse, *
 	return c, resp, err
 }
 
+// ListByTeam lists the Dashboards belonging to the Team with the given ID.
+// The Team is sent to the API as the teamId query parameter, but the Dashboards API does not document it, so the
+// decoded Dashboards are also filtered client side on TeamID to guarantee the result.
+func (s *DashboardService) ListByTeam(ctx context.Context, teamID int) (*ListDashboardsResponse, *http.Response, error) {
+	u := fmt.Sprintf("api/v3/dashboards?teamId=%d", teamID)
+	req, err := s.client.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := new(ListDashboardsResponse)
+	resp, err := s.client.Do(ctx, req, c)
+	if err != nil {
+		return c, resp, err
+	}
+	filtered := make([]Dashboard, 0, len(c.Dashboards))
+	for _, d := range c.Dashboards {
+		if d.TeamID == teamID {
+			filtered = append(filtered, d)
+		}
+	}
+	c.Dashboards = filtered
+	return c, resp, nil
+}
+
+// Search lists the Dashboards whose Name or Description contains query, ignoring case.
+// The Dashboards API has no search parameter, so all Dashboards are listed and filtered client side.
+func (s *DashboardService) Search(ctx context.Context, query string) (*ListDashboardsResponse, *http.Response, error) {
+	dashboards, resp, err := s.List(ctx)
+	if err != nil {
+		return dashboards, resp, err
+	}
+	query = strings.ToLower(query)
+	matches := make([]Dashboard, 0)
+	for _, d := range dashboards.Dashboards {
+		if strings.Contains(strings.ToLower(d.Name), query) || strings.Contains(strings.ToLower(d.Description), query) {
+			matches = append(matches, d)
+		}
+	}
+	dashboards.Dashboards = matches
+	return dashboards, resp, nil
+}
+
+// ListFavorites lists the Dashboards favorited by the current User.
+// The Dashboards API has no favorite filter, so all Dashboards are listed and filtered client side on Favorite.
+func (s *DashboardService) ListFavorites(ctx context.Context) (*ListDashboardsResponse, *http.Response, error) {
+	dashboards, resp, err := s.List(ctx)
+	if err != nil {
+		return dashboards, resp, err
+	}
+	favorites := make([]Dashboard, 0)
+	for _, d := range dashboards.Dashboards {
+		if d.Favorite {
+			favorites = append(favorites, d)
+		}
+	}
+	dashboards.Dashboards = favorites
+	return dashboards, resp, nil
+}
+
+// ListSharedWithTeam lists the Dashboards shared with the Team with the given ID, i.e. those with a SharingSetting
+// whose Member is that Team. The Dashboards API has no sharing filter, so all Dashboards are listed and filtered
+// client side.
+func (s *DashboardService) ListSharedWithTeam(ctx context.Context, teamID int) (*ListDashboardsResponse, *http.Response, error) {
+	dashboards, resp, err := s.List(ctx)
+	if err != nil {
+		return dashboards, resp, err
+	}
+	shared := make([]Dashboard, 0)
+	for _, d := range dashboards.Dashboards {
+		if d.sharedWithTeam(teamID) {
+			shared = append(shared, d)
+		}
+	}
+	dashboards.Dashboards = shared
+	return dashboards, resp, nil
+}
+
+// sharedWithTeam reports whether the Dashboard has a SharingSetting for the Team with the given ID.
+func (d *Dashboard) sharedWithTeam(teamID int) bool {
+	for _, setting := range d.SharingSettings {
+		if setting.Member.Type == SharingMemberTypeTeam && setting.Member.ID == teamID {
+			return true
+		}
+	}
+	return false
+}
+
 // Create creates a new Dashboard.
 func (s *DashboardService) Create(ctx context.Context, dashboard Dashboard) (*DashboardResponse, *http.Response, error) {
+	return s.create(ctx, dashboard, "")
+}
+
+// CreateWithKey creates a new Dashboard like Create, sending key in the IdempotencyKeyHeader.
+func (s *DashboardService) CreateWithKey(
+	ctx context.Context,
+	dashboard Dashboard,
+	key string) (*DashboardResponse, *http.Response, error) {
+	if key == "" {
+		return nil, nil, fmt.Errorf("idempotency key cannot be blank")
+	}
+	return s.create(ctx, dashboard, key)
+}
+
+func (s *DashboardService) create(
+	ctx context.Context,
+	dashboard Dashboard,
+	key string) (*DashboardResponse, *http.Response, error) {
 	type dashboardRequest struct {
 		Dashboard Dashboard `json:"dashboard"`
 	}
@@ -87,18 +306,39 @@ func (s *DashboardService) Create(ctx context.Context, dashboard Dashboard) (*Da
 		dashboard.Panels = make([]Panel, 0)
 		dashboard.Layout = make([]Layout, 0)
 	}
-	dashboard.ID = 0
-	dashboard.Version = 0
+	if err := validateDashboard(dashboard); err != nil {
+		return nil, nil, err
+	}
+	stripServerManaged(&dashboard)
 	dashboard.Schema = 3
 	req, err := s.client.NewRequest(http.MethodPost, u, dashboardRequest{dashboard})
 	if err != nil {
 		return nil, nil, err
 	}
+	s.client.setIdempotencyKey(req, key)
 	c := new(DashboardResponse)
 	resp, err := s.client.Do(ctx, req, c)
 	return c, resp, err
 }
 
+// validateDashboard checks that the Panel IDs of the Dashboard are unique and that every Layout references one of
+// its Panels, which the Sysdig API otherwise rejects without a descriptive error.
+func validateDashboard(d Dashboard) error {
+	panels := make(map[int]bool, len(d.Panels))
+	for _, p := range d.Panels {
+		if panels[p.ID] {
+			return fmt.Errorf("invalid dashboard: duplicate panel ID %d", p.ID)
+		}
+		panels[p.ID] = true
+	}
+	for _, l := range d.Layout {
+		if !panels[l.PanelID] {
+			return fmt.Errorf("invalid dashboard: layout references panel ID %d which does not exist", l.PanelID)
+		}
+	}
+	return nil
+}
+
 // Delete deletes a Dashboard.
 func (s *DashboardService) Delete(ctx context.Context, id int) (*DashboardResponse, *http.Response, error) {
 	u := fmt.Sprintf("api/v3/dashboards/%d", id)
@@ -111,11 +351,47 @@ func (s *DashboardService) Delete(ctx context.Context, id int) (*DashboardRespon
 	return c, resp, err
 }
 
-// Update updates a Dashboard.
+// ErrVersionConflict is matched by a *VersionConflictError with errors.Is.
+var ErrVersionConflict = errors.New("dashboard version conflict")
+
+// VersionConflictError is returned by DashboardService.Update when the Dashboard was modified since the Version being
+// updated was retrieved. Callers can retrieve the Dashboard again, reapply their changes and retry the update.
+type VersionConflictError struct {
+	// DashboardID is the ID of the Dashboard which failed to update.
+	DashboardID int
+	// Version is the Version sent in the update.
+	Version int
+	// CurrentVersion is the current Version of the Dashboard on the server, or 0 if it could not be retrieved.
+	CurrentVersion int
+	// Response is the conflict response from the Sysdig API.
+	Response *ErrorResponse
+}
+
+// Error implements the error interface for VersionConflictError.
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%v: dashboard %d has version %d, update sent version %d",
+		ErrVersionConflict, e.DashboardID, e.CurrentVersion, e.Version)
+}
+
+// Is reports whether target is ErrVersionConflict.
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+// Unwrap returns the conflict response from the Sysdig API.
+func (e *VersionConflictError) Unwrap() error {
+	return e.Response
+}
+
+// Update updates a Dashboard. Dashboard.Version must be the current Version of the Dashboard; if the Dashboard was
+// modified since, a *VersionConflictError carrying the current Version, which is retrieved with Get, is returned.
 func (s *DashboardService) Update(ctx context.Context, dashboard Dashboard) (*DashboardResponse, *http.Response, error) {
 	type dashboardRequest struct {
 		Dashboard Dashboard `json:"dashboard"`
 	}
+	if err := validateDashboard(dashboard); err != nil {
+		return nil, nil, err
+	}
 	u := fmt.Sprintf("api/v3/dashboards/%d", dashboard.ID)
 	req, err := s.client.NewRequest(http.MethodPut, u, dashboardRequest{dashboard})
 	if err != nil {
@@ -123,9 +399,112 @@ func (s *DashboardService) Update(ctx context.Context, dashboard Dashboard) (*Da
 	}
 	c := new(DashboardResponse)
 	resp, err := s.client.Do(ctx, req, c)
+	var errorResponse *ErrorResponse
+	if IsConflict(err) && errors.As(err, &errorResponse) {
+		conflict := &VersionConflictError{
+			DashboardID: dashboard.ID,
+			Version:     dashboard.Version,
+			Response:    errorResponse,
+		}
+		if current, _, gerr := s.Get(ctx, dashboard.ID); gerr == nil {
+			conflict.CurrentVersion = current.Dashboard.Version
+		}
+		return c, resp, conflict
+	}
 	return c, resp, err
 }
 
+// AddPanel adds panel to the Dashboard, placed with layout. The Panel is assigned the next unused Panel ID, which is
+// returned and set as the PanelID of layout.
+func (d *Dashboard) AddPanel(panel Panel, layout Layout) int {
+	id := 1
+	for _, p := range d.Panels {
+		if p.ID >= id {
+			id = p.ID + 1
+		}
+	}
+	panel.ID = id
+	layout.PanelID = id
+	d.Panels = append(d.Panels, panel)
+	d.Layout = append(d.Layout, layout)
+	return id
+}
+
+// RemovePanel removes the Panel with the given ID from the Dashboard along with its Layout.
+// It returns false if the Dashboard has no such Panel.
+func (d *Dashboard) RemovePanel(panelID int) bool {
+	removed := false
+	panels := d.Panels[:0]
+	for _, p := range d.Panels {
+		if p.ID == panelID {
+			removed = true
+			continue
+		}
+		panels = append(panels, p)
+	}
+	d.Panels = panels
+	layout := d.Layout[:0]
+	for _, l := range d.Layout {
+		if l.PanelID != panelID {
+			layout = append(layout, l)
+		}
+	}
+	d.Layout = layout
+	return removed
+}
+
+// Metrics returns the sorted, unique IDs of the metrics queried by the BasicQueries of the Dashboard Panels, e.g. to
+// find the Dashboards affected by renaming a metric.
+func (d *Dashboard) Metrics() []string {
+	seen := make(map[string]bool)
+	var metrics []string
+	for _, p := range d.Panels {
+		for _, q := range p.BasicQueries {
+			for _, m := range q.Metrics {
+				if m.ID == "" || seen[m.ID] {
+					continue
+				}
+				seen[m.ID] = true
+				metrics = append(metrics, m.ID)
+			}
+		}
+	}
+	sort.Strings(metrics)
+	return metrics
+}
+
+// AddPanel adds panel to a Dashboard with Dashboard.AddPanel, without requiring the caller to send the whole
+// Dashboard. The Dashboard is retrieved with Get and saved with Update, so a concurrent modification returns a
+// *VersionConflictError.
+func (s *DashboardService) AddPanel(
+	ctx context.Context,
+	dashboardID int,
+	panel Panel,
+	layout Layout) (*DashboardResponse, *http.Response, error) {
+	current, resp, err := s.Get(ctx, dashboardID)
+	if err != nil {
+		return nil, resp, err
+	}
+	dashboard := current.Dashboard
+	dashboard.AddPanel(panel, layout)
+	return s.Update(ctx, dashboard)
+}
+
+// RemovePanel removes a Panel and its Layout from a Dashboard with Dashboard.RemovePanel. The Dashboard is retrieved
+// with Get and saved with Update, so a concurrent modification returns a *VersionConflictError. An error is returned
+// without updating the Dashboard if it has no such Panel.
+func (s *DashboardService) RemovePanel(ctx context.Context, dashboardID, panelID int) (*DashboardResponse, *http.Response, error) {
+	current, resp, err := s.Get(ctx, dashboardID)
+	if err != nil {
+		return nil, resp, err
+	}
+	dashboard := current.Dashboard
+	if !dashboard.RemovePanel(panelID) {
+		return nil, resp, fmt.Errorf("dashboard %d has no panel with ID %d", dashboardID, panelID)
+	}
+	return s.Update(ctx, dashboard)
+}
+
 // Favorite favorites or unfavorites a Dashboard.
 func (s *DashboardService) Favorite(ctx context.Context, id int, favorite bool) (*DashboardResponse, *http.Response, error) {
 	type favoriteRequest struct {
@@ -141,12 +520,70 @@ func (s *DashboardService) Favorite(ctx context.Context, id int, favorite bool)
 	return c, resp, err
 }
 
-// DashboardTransferResponse is a container for a DashboardTransferResults for the DashboardService.Transfer API.
+// SetPublic enables or disables public sharing of a Dashboard.
+// When enabled, the returned Dashboard contains the PublicToken used to build its PublicURL.
+func (s *DashboardService) SetPublic(ctx context.Context, id int, public bool) (*DashboardResponse, *http.Response, error) {
+	type publicRequest struct {
+		Public bool `json:"public"`
+	}
+	u := fmt.Sprintf("api/v3/dashboards/%d", id)
+	req, err := s.client.NewRequest(http.MethodPatch, u, publicRequest{public})
+	if err != nil {
+		return nil, nil, err
+	}
+	c := new(DashboardResponse)
+	resp, err := s.client.Do(ctx, req, c)
+	return c, resp, err
+}
+
+// DashboardTransferResponse is a container for DashboardTransferResults for the DashboardService.Transfer API.
 type DashboardTransferResponse struct {
-	Results DashboardTransferResults `json:"results"`
+	// Results contains the outcome for each Dashboard in the transfer.
+	Results []DashboardTransferResults `json:"results"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler for DashboardTransferResponse.
+// The Transfer API has been observed to return results as a single object, which decodes to a single element of
+// Results. A list of results decodes to one element each.
+func (r *DashboardTransferResponse) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Results json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	r.Results = nil
+	trimmed := bytes.TrimSpace(raw.Results)
+	switch {
+	case len(trimmed) == 0, bytes.Equal(trimmed, []byte("null")):
+		return nil
+	case trimmed[0] == '{':
+		var result DashboardTransferResults
+		if err := json.Unmarshal(trimmed, &result); err != nil {
+			return err
+		}
+		r.Results = []DashboardTransferResults{result}
+		return nil
+	default:
+		return json.Unmarshal(trimmed, &r.Results)
+	}
+}
+
+// Failed returns the results for Dashboards which failed, or in simulate mode would fail, to transfer.
+func (r *DashboardTransferResponse) Failed() []DashboardTransferResults {
+	var failed []DashboardTransferResults
+	for _, result := range r.Results {
+		if result.Failed() {
+			failed = append(failed, result)
+		}
+	}
+	return failed
 }
 
-// DashboardTransferResults is the response structure for the DashboardService.Transfer API.
+// DashboardTransferResults is the outcome of transferring a single Dashboard with the DashboardService.Transfer API.
+// In simulate mode, Excluded reports the SharingSettings which would be removed by the transfer.
+// The errors of a result are not documented by the Sysdig API, and are decoded in the shape of other API errors if
+// present.
 type DashboardTransferResults struct {
 	ID              int              `json:"id"`
 	Name            string           `json:"name"`
@@ -157,6 +594,12 @@ type DashboardTransferResults struct {
 	Kept            []SharingSetting `json:"sharingSettingsKept"`
 	CurrentTeamID   int              `json:"currentTeamId"`
 	CurrentTeamName string           `json:"currentTeamName"`
+	Errors          []Error          `json:"errors,omitempty"`
+}
+
+// Failed returns whether the Dashboard failed, or in simulate mode would fail, to transfer.
+func (r DashboardTransferResults) Failed() bool {
+	return len(r.Errors) > 0
 }
 
 // Transfer transfers the ownership of a set of dashboards to another user.
@@ -190,12 +633,97 @@ func (s *DashboardService) Transfer(
 	return c, resp, err
 }
 
+// TransferPlan summarizes a simulated Dashboard transfer, returned by DashboardService.SimulateTransfer.
+type TransferPlan struct {
+	// Moved are the results for the Dashboards which would be transferred. DashboardTransferResults.Excluded lists
+	// the SharingSettings each would lose.
+	Moved []DashboardTransferResults
+	// Blocked are the results for the Dashboards which would fail to transfer, with the Errors explaining why.
+	Blocked []DashboardTransferResults
+}
+
+// MovedIDs returns the IDs of the Dashboards which would be transferred.
+func (p *TransferPlan) MovedIDs() []int {
+	ids := make([]int, 0, len(p.Moved))
+	for _, result := range p.Moved {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
+
+// IsBlocked reports whether any Dashboard would fail to transfer.
+func (p *TransferPlan) IsBlocked() bool {
+	return len(p.Blocked) > 0
+}
+
+// SimulateTransfer simulates transferring the ownership of a set of dashboards to another user with Transfer, without
+// transferring them, and returns a TransferPlan of the Dashboards which would be moved and which would be blocked.
+func (s *DashboardService) SimulateTransfer(
+	ctx context.Context,
+	ownerID, targetOwnerID int,
+	dashboardIDs ...int) (*TransferPlan, *http.Response, error) {
+	results, resp, err := s.Transfer(ctx, ownerID, targetOwnerID, true, dashboardIDs...)
+	if err != nil {
+		return nil, resp, err
+	}
+	plan := &TransferPlan{}
+	for _, result := range results.Results {
+		if result.Failed() {
+			plan.Blocked = append(plan.Blocked, result)
+		} else {
+			plan.Moved = append(plan.Moved, result)
+		}
+	}
+	return plan, resp, nil
+}
+
+// LibraryPanelResponse is a container for a LibraryPanel returned by the DashboardService.GetLibraryPanel API.
+type LibraryPanelResponse struct {
+	Panel LibraryPanel `json:"panel"`
+}
+
+// GetLibraryPanel retrieves a LibraryPanel.
+// The api/v3/panels endpoint used by the Sysdig UI for library panels is not documented by the Sysdig API, so its
+// payload is unverified and may change without notice.
+func (s *DashboardService) GetLibraryPanel(ctx context.Context, id int) (*LibraryPanelResponse, *http.Response, error) {
+	u := fmt.Sprintf("api/v3/panels/%d", id)
+	req, err := s.client.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := new(LibraryPanelResponse)
+	resp, err := s.client.Do(ctx, req, c)
+	return c, resp, err
+}
+
+// ListLibraryPanelsResponse is a container for LibraryPanels returned by the DashboardService.ListLibraryPanels API.
+type ListLibraryPanelsResponse struct {
+	Panels []LibraryPanel `json:"panels"`
+}
+
+// ListLibraryPanels lists all LibraryPanels.
+// The api/v3/panels endpoint used by the Sysdig UI for library panels is not documented by the Sysdig API, so its
+// payload is unverified and may change without notice.
+func (s *DashboardService) ListLibraryPanels(ctx context.Context) (*ListLibraryPanelsResponse, *http.Response, error) {
+	u := "api/v3/panels"
+	req, err := s.client.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := new(ListLibraryPanelsResponse)
+	resp, err := s.client.Do(ctx, req, c)
+	return c, resp, err
+}
+
 // SharingSetting defines a sharing setting for a Dashboard.
 type SharingSetting struct {
 	Role   string        `json:"role"`
 	Member SharingMember `json:"member"`
 }
 
+// SharingMemberTypeTeam is the SharingMember Type of a Team.
+const SharingMemberTypeTeam = "TEAM"
+
 // SharingMember defines a sharing member for a Dashboard.
 type SharingMember struct {
 	Type      string `json:"type"`
@@ -215,6 +743,55 @@ type ScopeExpression struct {
 	IsVariable  bool     `json:"isVariable"`
 }
 
+// ScopeExpressionBuilder builds the ScopeExpressions of a Dashboard scope, including template variables.
+type ScopeExpressionBuilder struct {
+	expressions []ScopeExpression
+}
+
+// NewScopeExpressionBuilder initializes a new ScopeExpressionBuilder.
+func NewScopeExpressionBuilder() *ScopeExpressionBuilder {
+	return &ScopeExpressionBuilder{}
+}
+
+// AddEquals adds a ScopeExpression matching the operand against the given values.
+// A single value is matched with "equals" and multiple values with "in".
+func (b *ScopeExpressionBuilder) AddEquals(operand string, values ...string) *ScopeExpressionBuilder {
+	operator := scopeExpressionOperators[scope.SelectionIs]
+	if len(values) > 1 {
+		operator = scopeExpressionOperators[scope.SelectionIn]
+	}
+	b.expressions = append(b.expressions, ScopeExpression{
+		Operand:     operand,
+		Operator:    operator,
+		DisplayName: operand,
+		Value:       append([]string{}, values...),
+	})
+	return b
+}
+
+// AddVariable adds a ScopeExpression which exposes the operand as a Dashboard template variable with the given
+// display name. The variable is unset and matches any value until one is selected in the Dashboard.
+func (b *ScopeExpressionBuilder) AddVariable(operand, displayName string) *ScopeExpressionBuilder {
+	b.expressions = append(b.expressions, ScopeExpression{
+		Operand:     operand,
+		Operator:    scopeExpressionOperators[scope.SelectionIs],
+		DisplayName: displayName,
+		Value:       []string{},
+		Variable:    true,
+		IsVariable:  true,
+	})
+	return b
+}
+
+// Build returns the ScopeExpressions added to the ScopeExpressionBuilder, to be used as a Dashboard
+// ScopeExpressionList.
+func (b *ScopeExpressionBuilder) Build() []ScopeExpression {
+	if len(b.expressions) == 0 {
+		return nil
+	}
+	return append([]ScopeExpression(nil), b.expressions...)
+}
+
 // Layout defines the Layout of Panels a Dashboard.
 type Layout struct {
 	PanelID int `json:"panelId"`
@@ -241,6 +818,33 @@ type Panel struct {
 	TransparentBackground  bool                `json:"transparentBackground,omitempty"`
 	PanelTitleVisible      bool                `json:"panelTitleVisible,omitempty"`
 	TextAutosized          bool                `json:"textAutosized,omitempty"`
+	// LibraryPanelID references a LibraryPanel by ID instead of inlining the full Panel definition.
+	// The libraryPanelId field is not documented by the Sysdig API, so whether the API resolves the reference is unverified.
+	LibraryPanelID int `json:"libraryPanelId,omitempty"`
+}
+
+// LibraryPanel is a saved, reusable Panel which Dashboards can reference by ID.
+// Library panels are not documented by the Sysdig API, see DashboardService.GetLibraryPanel.
+type LibraryPanel struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	TeamID      int       `json:"teamId"`
+	Panel       Panel     `json:"panel"`
+	CreatedOn   MilliTime `json:"createdOn"`
+	ModifiedOn  MilliTime `json:"modifiedOn"`
+}
+
+// Reference returns a Panel with the provided panel ID which references this LibraryPanel.
+// The returned Panel can be added to Dashboard.Panels along with a matching Layout.
+func (p LibraryPanel) Reference(panelID int) Panel {
+	return Panel{
+		ID:             panelID,
+		Type:           p.Panel.Type,
+		Name:           p.Name,
+		Description:    p.Description,
+		LibraryPanelID: p.ID,
+	}
 }
 
 // BasicQuery is a basic query type used in a Dashboard.
@@ -269,11 +873,46 @@ type BasicQueryScope struct {
 
 // BasicQueryMetric is a metric used in a BasicQuery on a Dashboard.
 type BasicQueryMetric struct {
-	ID               string      `json:"id"`
-	TimeAggregation  string      `json:"timeAggregation"`
-	GroupAggregation string      `json:"groupAggregation"`
-	Descriptor       *string     `json:"descriptor,omitempty"`
-	Sorting          interface{} `json:"sorting"`
+	ID               string        `json:"id"`
+	TimeAggregation  string        `json:"timeAggregation"`
+	GroupAggregation string        `json:"groupAggregation"`
+	Descriptor       *string       `json:"descriptor,omitempty"`
+	Sorting          *QuerySorting `json:"sorting"`
+}
+
+// QuerySorting is the sorting of the results of a BasicQueryMetric, e.g. for a top list.
+type QuerySorting struct {
+	// By is what the results are sorted by, e.g. "value".
+	By string `json:"by,omitempty"`
+	// Order is the sort order, "asc" or "desc".
+	Order string `json:"order,omitempty"`
+
+	// orderOnly records that the sorting was decoded from just the order, so it is encoded the same way.
+	orderOnly bool
+}
+
+// UnmarshalJSON implements json.Unmarshaler for QuerySorting.
+// Some payloads set the sorting as just the order, e.g. "desc", which is decoded into Order.
+func (s *QuerySorting) UnmarshalJSON(b []byte) error {
+	var order string
+	if err := json.Unmarshal(b, &order); err == nil {
+		*s = QuerySorting{Order: order, orderOnly: true}
+		return nil
+	}
+	type alias QuerySorting
+	*s = QuerySorting{}
+	return json.Unmarshal(b, (*alias)(s))
+}
+
+// MarshalJSON implements json.Marshaler for QuerySorting.
+// A QuerySorting decoded from just the order is encoded as just the order again unless By has been set, so payloads
+// are unchanged by a round trip.
+func (s QuerySorting) MarshalJSON() ([]byte, error) {
+	if s.orderOnly && s.By == "" {
+		return json.Marshal(s.Order)
+	}
+	type alias QuerySorting
+	return json.Marshal(alias(s))
 }
 
 // BasicQueryDisplayInfo is the display info used in a BasicQuery on a Dashboard.
@@ -1,13 +1,18 @@
 package sysdig
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/trinchan/sysdig-go/sysdig/scope"
 )
 
 func TestDashboardsService_List(t *testing.T) {
@@ -57,6 +62,162 @@ func TestDashboardsService_List(t *testing.T) {
 	})
 }
 
+func TestDashboardsService_Search(t *testing.T) {
+	methodName := "Search"
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/v3/dashboards", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"dashboards":[
+			{"id":1,"name":"Kubernetes Overview","description":"cluster health"},
+			{"id":2,"name":"Hosts","description":"Node CPU and memory for kubernetes nodes"},
+			{"id":3,"name":"Databases","description":"postgres"}]}`)
+	})
+	defer teardown()
+
+	tests := []struct {
+		name  string
+		query string
+		want  *ListDashboardsResponse
+	}{
+		{
+			name:  "match name and description",
+			query: "KUBERNETES",
+			want: &ListDashboardsResponse{Dashboards: []Dashboard{
+				{ID: 1, Name: "Kubernetes Overview", Description: "cluster health"},
+				{ID: 2, Name: "Hosts", Description: "Node CPU and memory for kubernetes nodes"},
+			}},
+		},
+		{
+			name:  "no match",
+			query: "redis",
+			want:  &ListDashboardsResponse{Dashboards: []Dashboard{}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, _, err := client.Dashboards.Search(context.Background(), test.query)
+			if err != nil {
+				t.Errorf("Dashboards.Search returned error: %v", err)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("Dashboards.Search returned %+v, want %+v", got, test.want)
+			}
+		})
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Dashboards.Search(context.Background(), "kubernetes")
+		return resp, err
+	})
+}
+
+func TestDashboardsService_ListFavorites(t *testing.T) {
+	methodName := "ListFavorites"
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/v3/dashboards", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"dashboards":[
+			{"id":1,"name":"Kubernetes Overview","favorite":true},
+			{"id":2,"name":"Hosts","favorite":false},
+			{"id":3,"name":"Databases"},
+			{"id":4,"name":"Network","favorite":true}]}`)
+	})
+	defer teardown()
+
+	got, _, err := client.Dashboards.ListFavorites(context.Background())
+	if err != nil {
+		t.Fatalf("Dashboards.ListFavorites returned error: %v", err)
+	}
+	want := &ListDashboardsResponse{Dashboards: []Dashboard{
+		{ID: 1, Name: "Kubernetes Overview", Favorite: true},
+		{ID: 4, Name: "Network", Favorite: true},
+	}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Dashboards.ListFavorites returned %+v, want %+v", got, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Dashboards.ListFavorites(context.Background())
+		return resp, err
+	})
+}
+
+func TestDashboardsService_ListSharedWithTeam(t *testing.T) {
+	methodName := "ListSharedWithTeam"
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/v3/dashboards", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"dashboards":[
+			{"id":1,"name":"Kubernetes Overview","shared":true,"sharingSettings":[
+				{"role":"ROLE_RESOURCE_READ","member":{"type":"TEAM","id":2}}]},
+			{"id":2,"name":"Hosts","shared":true,"sharingSettings":[
+				{"role":"ROLE_RESOURCE_READ","member":{"type":"USER","id":2}},
+				{"role":"ROLE_RESOURCE_READ","member":{"type":"TEAM","id":3}}]},
+			{"id":3,"name":"Databases"},
+			{"id":4,"name":"Network","shared":true,"sharingSettings":[
+				{"role":"ROLE_RESOURCE_READ","member":{"type":"TEAM","id":3}},
+				{"role":"ROLE_RESOURCE_EDIT","member":{"type":"TEAM","id":2}}]}]}`)
+	})
+	defer teardown()
+
+	got, _, err := client.Dashboards.ListSharedWithTeam(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Dashboards.ListSharedWithTeam returned error: %v", err)
+	}
+	var gotIDs []int
+	for _, d := range got.Dashboards {
+		gotIDs = append(gotIDs, d.ID)
+	}
+	if want := []int{1, 4}; !cmp.Equal(gotIDs, want) {
+		t.Errorf("Dashboards.ListSharedWithTeam returned dashboards %v, want %v", gotIDs, want)
+	}
+
+	got, _, err = client.Dashboards.ListSharedWithTeam(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("Dashboards.ListSharedWithTeam returned error: %v", err)
+	}
+	if want := (&ListDashboardsResponse{Dashboards: []Dashboard{}}); !cmp.Equal(got, want) {
+		t.Errorf("Dashboards.ListSharedWithTeam returned %+v, want %+v", got, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Dashboards.ListSharedWithTeam(context.Background(), 2)
+		return resp, err
+	})
+}
+
+func TestDashboardsService_ListByTeam(t *testing.T) {
+	methodName := "ListByTeam"
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/v3/dashboards", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		testFormValues(t, r, values{"teamId": "10"})
+		// The API may ignore teamId, so the fixture spans multiple teams.
+		fmt.Fprint(w, `{"dashboards":[
+			{"id":1,"name":"Overview","teamId":10},
+			{"id":2,"name":"Hosts","teamId":20},
+			{"id":3,"name":"Databases","teamId":10}]}`)
+	})
+	defer teardown()
+
+	got, _, err := client.Dashboards.ListByTeam(context.Background(), 10)
+	if err != nil {
+		t.Errorf("Dashboards.ListByTeam returned error: %v", err)
+	}
+	want := &ListDashboardsResponse{Dashboards: []Dashboard{
+		{ID: 1, Name: "Overview", TeamID: 10},
+		{ID: 3, Name: "Databases", TeamID: 10},
+	}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Dashboards.ListByTeam returned %+v, want %+v", got, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Dashboards.ListByTeam(context.Background(), 10)
+		return resp, err
+	})
+}
+
 func TestDashboardsService_Delete(t *testing.T) {
 	methodName := "Delete"
 	client, mux, _, teardown := setup(nil)
@@ -278,6 +439,136 @@ func TestDashboardsService_Update(t *testing.T) {
 	})
 }
 
+func TestDashboardsService_Update_VersionConflict(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/v3/dashboards/1", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"errors":[{"reason":"Conflict","message":"Dashboard version is out of date"}]}`)
+		case http.MethodGet:
+			fmt.Fprint(w, `{"dashboard":{"id":1,"version":5}}`)
+		default:
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+	})
+	defer teardown()
+
+	_, _, err := client.Dashboards.Update(context.Background(), Dashboard{ID: 1, Version: 3, Name: "test"})
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("got error: %v, want ErrVersionConflict", err)
+	}
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("got error: %T, want *VersionConflictError", err)
+	}
+	if conflict.DashboardID != 1 || conflict.Version != 3 || conflict.CurrentVersion != 5 {
+		t.Errorf("got conflict: %+v, want dashboard 1 with version 3 and current version 5", conflict)
+	}
+	if !IsConflict(err) {
+		t.Error("IsConflict returned false for a version conflict")
+	}
+	if want := []Error{{Reason: "Conflict", Message: "Dashboard version is out of date"}}; !cmp.Equal(conflict.Response.Errors, want) {
+		t.Errorf("got conflict response errors: %+v, want: %+v", conflict.Response.Errors, want)
+	}
+}
+
+func TestDashboardsService_AddPanel(t *testing.T) {
+	methodName := "AddPanel"
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/v3/dashboards/1", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, `{"dashboard":{"id":1,"version":2,"panels":[{"id":1},{"id":3}],`+
+				`"layout":[{"panelId":1,"w":12,"h":6},{"panelId":3,"y":6,"w":12,"h":6}]}}`)
+		case http.MethodPut:
+			var v struct {
+				Dashboard Dashboard `json:"dashboard"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+				t.Errorf("failed to decode request: %v", err)
+				return
+			}
+			wantPanels := []Panel{{ID: 1}, {ID: 3}, {ID: 4, Type: "basicTimechart", Name: "new"}}
+			if diff := cmp.Diff(wantPanels, v.Dashboard.Panels); diff != "" {
+				t.Errorf("Panels mismatch (-want +got):\n%s", diff)
+			}
+			wantLayout := []Layout{{PanelID: 1, W: 12, H: 6}, {PanelID: 3, Y: 6, W: 12, H: 6}, {PanelID: 4, Y: 12, W: 6, H: 6}}
+			if diff := cmp.Diff(wantLayout, v.Dashboard.Layout); diff != "" {
+				t.Errorf("Layout mismatch (-want +got):\n%s", diff)
+			}
+			if v.Dashboard.Version != 2 {
+				t.Errorf("got version %d, want 2", v.Dashboard.Version)
+			}
+			fmt.Fprint(w, `{"dashboard":{"id":1,"version":3}}`)
+		default:
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+	})
+	defer teardown()
+
+	panel := Panel{ID: 1, Type: "basicTimechart", Name: "new"}
+	got, _, err := client.Dashboards.AddPanel(context.Background(), 1, panel, Layout{PanelID: 1, Y: 12, W: 6, H: 6})
+	if err != nil {
+		t.Fatalf("Dashboards.AddPanel returned error: %v", err)
+	}
+	if want := (&DashboardResponse{Dashboard: Dashboard{ID: 1, Version: 3}}); !cmp.Equal(got, want) {
+		t.Errorf("Dashboards.AddPanel returned %+v, want %+v", got, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Dashboards.AddPanel(context.Background(), 1, panel, Layout{})
+		return resp, err
+	})
+}
+
+func TestDashboardsService_RemovePanel(t *testing.T) {
+	methodName := "RemovePanel"
+	client, mux, _, teardown := setup(nil)
+	updates := 0
+	mux.HandleFunc("/api/v3/dashboards/1", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, `{"dashboard":{"id":1,"version":2,"panels":[{"id":1},{"id":3}],`+
+				`"layout":[{"panelId":1,"w":12,"h":6},{"panelId":3,"y":6,"w":12,"h":6}]}}`)
+		case http.MethodPut:
+			updates++
+			var v struct {
+				Dashboard Dashboard `json:"dashboard"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+				t.Errorf("failed to decode request: %v", err)
+				return
+			}
+			if diff := cmp.Diff([]Panel{{ID: 3}}, v.Dashboard.Panels); diff != "" {
+				t.Errorf("Panels mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff([]Layout{{PanelID: 3, Y: 6, W: 12, H: 6}}, v.Dashboard.Layout); diff != "" {
+				t.Errorf("Layout mismatch (-want +got):\n%s", diff)
+			}
+			fmt.Fprint(w, `{"dashboard":{"id":1,"version":3}}`)
+		default:
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+	})
+	defer teardown()
+
+	if _, _, err := client.Dashboards.RemovePanel(context.Background(), 1, 1); err != nil {
+		t.Fatalf("Dashboards.RemovePanel returned error: %v", err)
+	}
+	if _, _, err := client.Dashboards.RemovePanel(context.Background(), 1, 2); err == nil {
+		t.Error("Dashboards.RemovePanel of a missing panel expected error")
+	}
+	if updates != 1 {
+		t.Errorf("Dashboards.RemovePanel made %d updates, want 1", updates)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Dashboards.RemovePanel(context.Background(), 1, 1)
+		return resp, err
+	})
+}
+
 func TestDashboardsService_Favorite(t *testing.T) {
 	methodName := "Get"
 	client, mux, _, teardown := setup(nil)
@@ -335,6 +626,65 @@ func TestDashboardsService_Favorite(t *testing.T) {
 	})
 }
 
+func TestDashboardsService_SetPublic(t *testing.T) {
+	methodName := "SetPublic"
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/v3/dashboards/", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	})
+	defer teardown()
+
+	tests := []struct {
+		name    string
+		id      int
+		public  bool
+		handler http.HandlerFunc
+		want    *DashboardResponse
+	}{
+		{
+			name: "test enable",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodPatch)
+				testBody(t, r, `{"public":true}`+"\n")
+				fmt.Fprint(w, `{"dashboard":{"id":1,"schema":3,"public":true,"publicToken":"abc123"}}`)
+			},
+			id:     1,
+			public: true,
+			want:   &DashboardResponse{Dashboard: Dashboard{ID: 1, Schema: 3, Public: true, PublicToken: "abc123"}},
+		},
+		{
+			name: "test disable",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodPatch)
+				testBody(t, r, `{"public":false}`+"\n")
+				fmt.Fprint(w, `{"dashboard":{"id":1,"schema":3,"public":false,"publicToken":""}}`)
+			},
+			id:     1,
+			public: false,
+			want:   &DashboardResponse{Dashboard: Dashboard{ID: 1, Schema: 3}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h = test.handler
+			ctx := context.Background()
+			dashboard, _, err := client.Dashboards.SetPublic(ctx, test.id, test.public)
+			if err != nil {
+				t.Errorf("Dashboards.SetPublic returned error: %v", err)
+			}
+			if !cmp.Equal(dashboard, test.want) {
+				t.Errorf("Dashboards.SetPublic returned %+v, want %+v", dashboard, test.want)
+			}
+		})
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Dashboards.SetPublic(context.Background(), tests[0].id, tests[0].public)
+		return resp, err
+	})
+}
+
 func TestDashboardsService_Transfer(t *testing.T) {
 	methodName := "Get"
 	client, mux, _, teardown := setup(nil)
@@ -358,7 +708,30 @@ func TestDashboardsService_Transfer(t *testing.T) {
 				fmt.Fprint(w, `{"results":{"id":1}}`)
 			},
 			ids:     []int{1},
-			want:    &DashboardTransferResponse{DashboardTransferResults{ID: 1}},
+			want:    &DashboardTransferResponse{Results: []DashboardTransferResults{{ID: 1}}},
+			wantErr: false,
+		},
+		{
+			name: "test partial failure",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodPost)
+				fmt.Fprint(w, `{"results":[`+
+					`{"id":1,"sharingSettingsExcluded":[{"role":"ROLE_RESOURCE_READ","member":{"type":"TEAM","id":2}}]},`+
+					`{"id":2,"errors":[{"message":"permission denied","reason":"forbidden"}]}]}`)
+			},
+			ids: []int{1, 2},
+			want: &DashboardTransferResponse{Results: []DashboardTransferResults{
+				{
+					ID: 1,
+					Excluded: []SharingSetting{
+						{Role: "ROLE_RESOURCE_READ", Member: SharingMember{Type: "TEAM", ID: 2}},
+					},
+				},
+				{
+					ID:     2,
+					Errors: []Error{{Message: "permission denied", Reason: "forbidden"}},
+				},
+			}},
 			wantErr: false,
 		},
 		{
@@ -388,3 +761,656 @@ func TestDashboardsService_Transfer(t *testing.T) {
 		return resp, err
 	})
 }
+
+func TestDashboardsService_SimulateTransfer(t *testing.T) {
+	methodName := "SimulateTransfer"
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/v3/dashboards/transfer", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		testBody(t, r, `{"ownerId":1,"targetOwnerId":2,"simulate":true,"dashboardIdsToBeTransferred":[10,11,12]}`+"\n")
+		fmt.Fprint(w, `{"results":[
+			{"id":10,"name":"Hosts","sharingSettingsExcluded":[{"role":"ROLE_RESOURCE_READ","member":{"type":"TEAM","id":3}}]},
+			{"id":11,"name":"Private","privateDashboard":true,"errors":[{"message":"target user is not a member of the team","reason":"forbidden"}]},
+			{"id":12,"name":"Network","sharingSettingsKept":[{"role":"ROLE_RESOURCE_EDIT","member":{"type":"TEAM","id":4}}]}]}`)
+	})
+	defer teardown()
+
+	got, _, err := client.Dashboards.SimulateTransfer(context.Background(), 1, 2, 10, 11, 12)
+	if err != nil {
+		t.Fatalf("Dashboards.SimulateTransfer returned error: %v", err)
+	}
+	want := &TransferPlan{
+		Moved: []DashboardTransferResults{
+			{
+				ID:       10,
+				Name:     "Hosts",
+				Excluded: []SharingSetting{{Role: "ROLE_RESOURCE_READ", Member: SharingMember{Type: "TEAM", ID: 3}}},
+			},
+			{
+				ID:   12,
+				Name: "Network",
+				Kept: []SharingSetting{{Role: "ROLE_RESOURCE_EDIT", Member: SharingMember{Type: "TEAM", ID: 4}}},
+			},
+		},
+		Blocked: []DashboardTransferResults{
+			{
+				ID:      11,
+				Name:    "Private",
+				Private: true,
+				Errors:  []Error{{Message: "target user is not a member of the team", Reason: "forbidden"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Dashboards.SimulateTransfer mismatch (-want +got):\n%s", diff)
+	}
+	if ids := got.MovedIDs(); !cmp.Equal(ids, []int{10, 12}) {
+		t.Errorf("MovedIDs returned %v, want [10 12]", ids)
+	}
+	if !got.IsBlocked() {
+		t.Error("IsBlocked returned false, want true")
+	}
+
+	if _, _, err := client.Dashboards.SimulateTransfer(context.Background(), 1, 2); err == nil {
+		t.Error("Dashboards.SimulateTransfer without dashboard ids expected error")
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Dashboards.SimulateTransfer(context.Background(), 1, 2, 10)
+		return resp, err
+	})
+}
+
+func TestDashboardsService_ListLibraryPanels(t *testing.T) {
+	methodName := "ListLibraryPanels"
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/v3/panels", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	})
+	defer teardown()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "test",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				fmt.Fprint(w, `{"panels":[{"id":1,"name":"cpu","panel":{"type":"advancedTimechart"}}]}`)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h = test.handler
+			ctx := context.Background()
+			panels, _, err := client.Dashboards.ListLibraryPanels(ctx)
+			if err != nil {
+				t.Errorf("Dashboards.ListLibraryPanels returned error: %v", err)
+			}
+			want := &ListLibraryPanelsResponse{
+				Panels: []LibraryPanel{{ID: 1, Name: "cpu", Panel: Panel{Type: "advancedTimechart"}}},
+			}
+			if !cmp.Equal(panels, want) {
+				t.Errorf("Dashboards.ListLibraryPanels returned %+v, want %+v", panels, want)
+			}
+		})
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		got, resp, err := client.Dashboards.ListLibraryPanels(context.Background())
+		if got != nil {
+			t.Errorf("testNewRequestAndDoFailure %v = %#v, want nil", methodName, got)
+		}
+		return resp, err
+	})
+}
+
+func TestDashboardsService_GetLibraryPanel(t *testing.T) {
+	methodName := "GetLibraryPanel"
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/v3/panels/", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	})
+	defer teardown()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "test",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				fmt.Fprint(w, `{"panel":{"id":1}}`)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h = test.handler
+			ctx := context.Background()
+			panel, _, err := client.Dashboards.GetLibraryPanel(ctx, 1)
+			if err != nil {
+				t.Errorf("Dashboards.GetLibraryPanel returned error: %v", err)
+			}
+			want := &LibraryPanelResponse{Panel: LibraryPanel{ID: 1}}
+			if !cmp.Equal(panel, want) {
+				t.Errorf("Dashboards.GetLibraryPanel returned %+v, want %+v", panel, want)
+			}
+		})
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Dashboards.GetLibraryPanel(context.Background(), 1)
+		return resp, err
+	})
+}
+
+func TestLibraryPanel_Reference(t *testing.T) {
+	libraryPanel := LibraryPanel{
+		ID:          7,
+		Name:        "cpu",
+		Description: "CPU usage",
+		Panel:       Panel{ID: 1, Type: "advancedTimechart", Name: "ignored"},
+	}
+	got := libraryPanel.Reference(3)
+	want := Panel{ID: 3, Type: "advancedTimechart", Name: "cpu", Description: "CPU usage", LibraryPanelID: 7}
+	if !cmp.Equal(got, want) {
+		t.Errorf("LibraryPanel.Reference returned %+v, want %+v", got, want)
+	}
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("unexpected json marshal error: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected json unmarshal error: %v", err)
+	}
+	if decoded["libraryPanelId"] != float64(7) {
+		t.Errorf("marshaled libraryPanelId = %v, want 7", decoded["libraryPanelId"])
+	}
+}
+
+func TestDashboard_SetScope(t *testing.T) {
+	d := NewDashboard("test")
+	d.ScopeExpressionList = []ScopeExpression{{Operand: "old", Operator: "equals", Value: []string{"old"}}}
+	d.Panels = []Panel{
+		{
+			ID: 1,
+			BasicQueries: []BasicQuery{
+				{Scope: BasicQueryScope{Expressions: []string{`old = 'old'`}}},
+				{Scope: BasicQueryScope{ExtendsDashboardScope: true}},
+			},
+		},
+		{
+			ID: 2,
+			BasicQueries: []BasicQuery{
+				{Scope: BasicQueryScope{Expressions: []string{`old = 'old'`}}},
+			},
+		},
+	}
+	d.SetScope(scope.New().
+		AddIsSelection("kubernetes.cluster.name", "prod").
+		AddInSelection("kubernetes.namespace.name", "a", "b"))
+
+	wantExpressions := []ScopeExpression{
+		{
+			Operand:     "kubernetes.cluster.name",
+			Operator:    "equals",
+			DisplayName: "kubernetes.cluster.name",
+			Value:       []string{"prod"},
+		},
+		{
+			Operand:     "kubernetes.namespace.name",
+			Operator:    "in",
+			DisplayName: "kubernetes.namespace.name",
+			Value:       []string{"a", "b"},
+		},
+	}
+	if !cmp.Equal(d.ScopeExpressionList, wantExpressions) {
+		t.Errorf("ScopeExpressionList = %+v, want %+v", d.ScopeExpressionList, wantExpressions)
+	}
+	wantQueryScope := BasicQueryScope{
+		Expressions: []string{`kubernetes.cluster.name = 'prod'`, `kubernetes.namespace.name in ('a', 'b')`},
+	}
+	for _, q := range []BasicQuery{d.Panels[0].BasicQueries[0], d.Panels[1].BasicQueries[0]} {
+		if !cmp.Equal(q.Scope, wantQueryScope) {
+			t.Errorf("BasicQuery.Scope = %+v, want %+v", q.Scope, wantQueryScope)
+		}
+	}
+	if got := d.Panels[0].BasicQueries[1].Scope; !cmp.Equal(got, BasicQueryScope{ExtendsDashboardScope: true}) {
+		t.Errorf("BasicQuery extending dashboard scope was modified: %+v", got)
+	}
+
+	d.SetScope(nil)
+	if d.ScopeExpressionList != nil {
+		t.Errorf("ScopeExpressionList = %+v, want nil", d.ScopeExpressionList)
+	}
+}
+
+func TestDashboard_Metrics(t *testing.T) {
+	d := NewDashboard("test")
+	d.Panels = []Panel{
+		{
+			ID: 1,
+			BasicQueries: []BasicQuery{
+				{Metrics: []BasicQueryMetric{{ID: "timestamp"}, {ID: "sysdig_container_cpu_used_percent"}}},
+				{Metrics: []BasicQueryMetric{{ID: "sysdig_container_memory_used_bytes"}}},
+			},
+		},
+		{
+			ID: 2,
+			BasicQueries: []BasicQuery{
+				{Metrics: []BasicQueryMetric{{ID: "sysdig_container_cpu_used_percent"}, {ID: "timestamp"}}},
+			},
+		},
+		{ID: 3},
+	}
+	want := []string{"sysdig_container_cpu_used_percent", "sysdig_container_memory_used_bytes", "timestamp"}
+	if got := d.Metrics(); !cmp.Equal(got, want) {
+		t.Errorf("Metrics returned %v, want %v", got, want)
+	}
+	if got := NewDashboard("empty").Metrics(); got != nil {
+		t.Errorf("Metrics of an empty Dashboard returned %v, want nil", got)
+	}
+}
+
+func TestScopeExpressionBuilder(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *ScopeExpressionBuilder
+		want    string
+	}{
+		{
+			name:    "empty",
+			builder: NewScopeExpressionBuilder(),
+			want:    `null`,
+		},
+		{
+			name:    "equals",
+			builder: NewScopeExpressionBuilder().AddEquals("kubernetes.cluster.name", "prod"),
+			want: `[{"operand":"kubernetes.cluster.name","operator":"equals","displayName":"kubernetes.cluster.name",` +
+				`"value":["prod"],"variable":false,"isVariable":false}]`,
+		},
+		{
+			name:    "in",
+			builder: NewScopeExpressionBuilder().AddEquals("kubernetes.namespace.name", "a", "b"),
+			want: `[{"operand":"kubernetes.namespace.name","operator":"in","displayName":"kubernetes.namespace.name",` +
+				`"value":["a","b"],"variable":false,"isVariable":false}]`,
+		},
+		{
+			name: "variable",
+			builder: NewScopeExpressionBuilder().
+				AddEquals("kubernetes.cluster.name", "prod").
+				AddVariable("kubernetes.namespace.name", "namespace"),
+			want: `[{"operand":"kubernetes.cluster.name","operator":"equals","displayName":"kubernetes.cluster.name",` +
+				`"value":["prod"],"variable":false,"isVariable":false},` +
+				`{"operand":"kubernetes.namespace.name","operator":"equals","displayName":"namespace",` +
+				`"value":[],"variable":true,"isVariable":true}]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := json.Marshal(test.builder.Build())
+			if err != nil {
+				t.Fatalf("unexpected json marshal error: %v", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got: %s, want: %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDashboardTransferResponse_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []DashboardTransferResults
+	}{
+		{
+			name: "single object",
+			data: `{"results":{"id":1,"name":"test","privateDashboard":true,"targetTeamId":2,"currentTeamId":3,` +
+				`"sharingSettingsKept":[{"role":"ROLE_RESOURCE_EDIT","member":{"type":"TEAM","id":2}}]}}`,
+			want: []DashboardTransferResults{{
+				ID:            1,
+				Name:          "test",
+				Private:       true,
+				TargetTeamID:  2,
+				CurrentTeamID: 3,
+				Kept:          []SharingSetting{{Role: "ROLE_RESOURCE_EDIT", Member: SharingMember{Type: "TEAM", ID: 2}}},
+			}},
+		},
+		{
+			name: "list",
+			data: `{"results":[{"id":1},{"id":2}]}`,
+			want: []DashboardTransferResults{{ID: 1}, {ID: 2}},
+		},
+		{
+			name: "missing results",
+			data: `{}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := DashboardTransferResponse{Results: []DashboardTransferResults{{ID: 99}}}
+			if err := json.Unmarshal([]byte(test.data), &r); err != nil {
+				t.Fatalf("unexpected json unmarshal error: %v", err)
+			}
+			if !cmp.Equal(r.Results, test.want) {
+				t.Errorf("got results: %+v, want %+v", r.Results, test.want)
+			}
+		})
+	}
+}
+
+func TestDashboardTransferResponse_Failed(t *testing.T) {
+	var r DashboardTransferResponse
+	err := json.Unmarshal([]byte(`{"results":[{"id":1},{"id":2,"errors":[{"message":"denied"}]},{"id":3}]}`), &r)
+	if err != nil {
+		t.Fatalf("unexpected json unmarshal error: %v", err)
+	}
+	want := []DashboardTransferResults{{ID: 2, Errors: []Error{{Message: "denied"}}}}
+	if got := r.Failed(); !cmp.Equal(got, want) {
+		t.Errorf("Failed returned %+v, want %+v", got, want)
+	}
+
+	if err := json.Unmarshal([]byte(`{"results":null}`), &r); err != nil {
+		t.Fatalf("unexpected json unmarshal error: %v", err)
+	}
+	if r.Results != nil {
+		t.Errorf("got results: %+v, want nil", r.Results)
+	}
+	if err := json.Unmarshal([]byte(`{"results":"bad"}`), &r); err == nil {
+		t.Error("expected json unmarshal error")
+	}
+}
+
+func TestDashboard_PublicURL(t *testing.T) {
+	defaultClient, err := NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ibmClient, err := NewClient(nil, WithIBMBaseURL(RegionUSSouth, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		dashboard Dashboard
+		baseURL   *url.URL
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "default",
+			dashboard: Dashboard{Name: "public", Public: true, PublicToken: "abc123"},
+			baseURL:   defaultClient.BaseURL,
+			want:      "https://app.sysdigcloud.com/#/public/dashboard/abc123",
+		},
+		{
+			name:      "IBM",
+			dashboard: Dashboard{Name: "public", Public: true, PublicToken: "abc123"},
+			baseURL:   ibmClient.BaseURL,
+			want:      "https://us-south.monitoring.cloud.ibm.com/#/public/dashboard/abc123",
+		},
+		{
+			name:      "path prefix",
+			dashboard: Dashboard{Name: "public", Public: true, PublicToken: "abc123"},
+			baseURL:   &url.URL{Scheme: "https", Host: "proxy.example.com", Path: "/monitoring/sysdig/"},
+			want:      "https://proxy.example.com/monitoring/sysdig/#/public/dashboard/abc123",
+		},
+		{
+			name:      "path prefix without trailing slash",
+			dashboard: Dashboard{Name: "public", Public: true, PublicToken: "abc123"},
+			baseURL:   &url.URL{Scheme: "https", Host: "proxy.example.com", Path: "/monitoring/sysdig"},
+			want:      "https://proxy.example.com/monitoring/sysdig/#/public/dashboard/abc123",
+		},
+		{
+			name:      "not public",
+			dashboard: Dashboard{Name: "private", PublicToken: "abc123"},
+			baseURL:   defaultClient.BaseURL,
+			wantErr:   true,
+		},
+		{
+			name:      "empty token",
+			dashboard: Dashboard{Name: "public", Public: true},
+			baseURL:   defaultClient.BaseURL,
+			wantErr:   true,
+		},
+		{
+			name:      "nil base URL",
+			dashboard: Dashboard{Name: "public", Public: true, PublicToken: "abc123"},
+			wantErr:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.dashboard.PublicURL(test.baseURL)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("PublicURL() error = %v, wantErr %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("PublicURL() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+const dashboardFixture = `{
+	"id": 42,
+	"teamId": 7,
+	"userId": 3,
+	"name": "Kubernetes Overview",
+	"description": "Cluster health",
+	"panels": [{"id": 1, "type": "advancedTimechart", "name": "CPU"}],
+	"shared": true,
+	"public": true,
+	"publicToken": "abc123",
+	"version": 5,
+	"createdOn": 1600000000000,
+	"modifiedOn": 1600000001000,
+	"schema": 3,
+	"scopeExpressionList": [{"operand": "kube_cluster_name", "operator": "equals", "value": ["prod"]}]
+}`
+
+func TestDashboard_MarshalExport(t *testing.T) {
+	var dashboard Dashboard
+	if err := json.Unmarshal([]byte(dashboardFixture), &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := dashboard.MarshalExport()
+	if err != nil {
+		t.Fatalf("MarshalExport returned error: %v", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(exported, &fields); err != nil {
+		t.Fatalf("MarshalExport returned invalid JSON: %v", err)
+	}
+	for _, f := range []string{"id", "teamId", "userId", "version", "publicToken", "createdOn", "modifiedOn"} {
+		if _, ok := fields[f]; ok {
+			t.Errorf("MarshalExport included environment specific field %q", f)
+		}
+	}
+	for _, f := range []string{"name", "description", "panels", "scopeExpressionList"} {
+		if _, ok := fields[f]; !ok {
+			t.Errorf("MarshalExport did not include field %q", f)
+		}
+	}
+
+	parsed, err := ParseDashboard(bytes.NewReader(exported))
+	if err != nil {
+		t.Fatalf("ParseDashboard returned error: %v", err)
+	}
+	want := dashboard
+	want.ID, want.TeamID, want.UserID, want.Version, want.PublicToken = 0, 0, 0, 0, ""
+	want.CreatedOn, want.ModifiedOn = MilliTime{}, MilliTime{}
+	if !cmp.Equal(parsed, &want) {
+		t.Errorf("ParseDashboard returned %+v, want %+v", parsed, &want)
+	}
+
+	reexported, err := parsed.MarshalExport()
+	if err != nil {
+		t.Fatalf("MarshalExport returned error: %v", err)
+	}
+	if !bytes.Equal(exported, reexported) {
+		t.Errorf("round trip changed export:\n%s\nwant:\n%s", reexported, exported)
+	}
+}
+
+func TestParseDashboard(t *testing.T) {
+	parsed, err := ParseDashboard(strings.NewReader(dashboardFixture))
+	if err != nil {
+		t.Fatalf("ParseDashboard returned error: %v", err)
+	}
+	if parsed.ID != 0 || parsed.TeamID != 0 || parsed.UserID != 0 || parsed.Version != 0 || parsed.PublicToken != "" ||
+		!parsed.CreatedOn.IsZero() || !parsed.ModifiedOn.IsZero() {
+		t.Errorf("ParseDashboard did not clear environment specific fields: %+v", parsed)
+	}
+	if parsed.Name != "Kubernetes Overview" {
+		t.Errorf("ParseDashboard returned name %q, want %q", parsed.Name, "Kubernetes Overview")
+	}
+	if _, err := ParseDashboard(strings.NewReader("{")); err == nil {
+		t.Error("ParseDashboard did not return an expected error")
+	}
+}
+
+func TestValidateDashboard(t *testing.T) {
+	tests := []struct {
+		name      string
+		dashboard Dashboard
+		wantErr   bool
+	}{
+		{
+			name: "valid",
+			dashboard: Dashboard{
+				Panels: []Panel{{ID: 1}, {ID: 2}},
+				Layout: []Layout{{PanelID: 1}, {PanelID: 2}},
+			},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name: "missing panel",
+			dashboard: Dashboard{
+				Panels: []Panel{{ID: 1}},
+				Layout: []Layout{{PanelID: 1}, {PanelID: 2}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate panel",
+			dashboard: Dashboard{
+				Panels: []Panel{{ID: 1}, {ID: 1}},
+				Layout: []Layout{{PanelID: 1}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateDashboard(test.dashboard)
+			if (err != nil) != test.wantErr {
+				t.Errorf("validateDashboard returned error: %v, want error: %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestDashboardsService_CreateAndUpdate_Invalid(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	unexpected := func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request for invalid dashboard: %s %s", r.Method, r.URL)
+	}
+	mux.HandleFunc("/api/v3/dashboards", unexpected)
+	mux.HandleFunc("/api/v3/dashboards/", unexpected)
+	defer teardown()
+
+	dashboard := Dashboard{ID: 1, Panels: []Panel{{ID: 1}}, Layout: []Layout{{PanelID: 2}}}
+	if _, _, err := client.Dashboards.Create(context.Background(), dashboard); err == nil {
+		t.Error("Dashboards.Create did not return an expected error")
+	}
+	if _, _, err := client.Dashboards.Update(context.Background(), dashboard); err == nil {
+		t.Error("Dashboards.Update did not return an expected error")
+	}
+}
+
+func TestQuerySorting_JSON(t *testing.T) {
+	panel := `{
+  "id": 2,
+  "type": "basicTable",
+  "name": "Top CPU",
+  "basicQueries": [
+    {
+      "enabled": true,
+      "metrics": [
+        {"id": "kubernetes.deployment.name", "timeAggregation": null, "groupAggregation": null, "sorting": null},
+        {"id": "cpu.used.percent", "timeAggregation": "avg", "groupAggregation": "avg", "sorting": {"by": "value", "order": "desc"}},
+        {"id": "memory.used.percent", "timeAggregation": "avg", "groupAggregation": "avg", "sorting": "asc"}
+      ]
+    }
+  ]
+}`
+	var got Panel
+	if err := json.Unmarshal([]byte(panel), &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	want := []*QuerySorting{nil, {By: "value", Order: "desc"}, {Order: "asc", orderOnly: true}}
+	var sortings []*QuerySorting
+	for _, m := range got.BasicQueries[0].Metrics {
+		sortings = append(sortings, m.Sorting)
+	}
+	if diff := cmp.Diff(want, sortings, cmp.AllowUnexported(QuerySorting{})); diff != "" {
+		t.Errorf("Sorting mismatch (-want +got):\n%s", diff)
+	}
+
+	b, err := json.Marshal(got.BasicQueries[0].Metrics)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	var roundTripped []BasicQueryMetric
+	if err := json.Unmarshal(b, &roundTripped); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if diff := cmp.Diff(got.BasicQueries[0].Metrics, roundTripped, cmp.AllowUnexported(QuerySorting{})); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+	for _, fragment := range []string{`"sorting":null`, `"sorting":{"by":"value","order":"desc"}`, `"sorting":"asc"`} {
+		if !strings.Contains(string(b), fragment) {
+			t.Errorf("marshaled metrics %s do not contain %s", b, fragment)
+		}
+	}
+
+	// A Dashboard with the panel is unchanged by a round trip, e.g. from Get to Update.
+	exported, err := (&Dashboard{Name: "test", Panels: []Panel{got}}).MarshalExport()
+	if err != nil {
+		t.Fatalf("MarshalExport returned error: %v", err)
+	}
+	parsed, err := ParseDashboard(bytes.NewReader(exported))
+	if err != nil {
+		t.Fatalf("ParseDashboard returned error: %v", err)
+	}
+	reexported, err := parsed.MarshalExport()
+	if err != nil {
+		t.Fatalf("MarshalExport returned error: %v", err)
+	}
+	if !bytes.Equal(exported, reexported) || !strings.Contains(string(reexported), `"sorting": "asc"`) {
+		t.Errorf("round trip changed dashboard:\n%s\nwant:\n%s", reexported, exported)
+	}
+
+	// Setting By encodes the sorting as an object.
+	sorting := *got.BasicQueries[0].Metrics[2].Sorting
+	sorting.By = "value"
+	if b, err := json.Marshal(sorting); err != nil || string(b) != `{"by":"value","order":"asc"}` {
+		t.Errorf("Marshal returned %s, %v, want %s", b, err, `{"by":"value","order":"asc"}`)
+	}
+
+	if err := json.Unmarshal([]byte(`{"sorting":1}`), &BasicQueryMetric{}); err == nil {
+		t.Error("Unmarshal of a numeric sorting expected error")
+	}
+}
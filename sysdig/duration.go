@@ -1,8 +1,11 @@
 package sysdig
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,17 +20,63 @@ func NewMicroDuration(t time.Duration) MicroDuration {
 	return MicroDuration{t}
 }
 
+// String implements fmt.Stringer for MicroDuration using time.Duration.String.
+func (t MicroDuration) String() string {
+	return t.Duration.String()
+}
+
 // MarshalJSON implements the json.Marshaler interface for MilliTime.
 func (t MicroDuration) MarshalJSON() ([]byte, error) {
 	return json.Marshal(t.Microseconds())
 }
 
+// microDurationUnits are the units accepted in the object form of a MicroDuration.
+var microDurationUnits = map[string]time.Duration{
+	"ns":           time.Nanosecond,
+	"nanoseconds":  time.Nanosecond,
+	"us":           time.Microsecond,
+	"µs":           time.Microsecond,
+	"microseconds": time.Microsecond,
+	"ms":           time.Millisecond,
+	"milliseconds": time.Millisecond,
+	"s":            time.Second,
+	"seconds":      time.Second,
+	"m":            time.Minute,
+	"minutes":      time.Minute,
+	"h":            time.Hour,
+	"hours":        time.Hour,
+}
+
 // UnmarshalJSON implements json.Unmarshaler for MilliTime.
+// Sysdig usually returns a number of microseconds, but some responses return a {"value":..., "unit":...} object,
+// e.g. {"value":10,"unit":"minutes"}, which is also accepted.
 func (t *MicroDuration) UnmarshalJSON(b []byte) error {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		return t.unmarshalObject(trimmed)
+	}
 	u, err := strconv.Atoi(string(b))
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid duration %s: must be a number of microseconds or a value and unit object: %w", b, err)
 	}
 	*t = NewMicroDuration(time.Duration(u) * time.Microsecond)
 	return nil
 }
+
+func (t *MicroDuration) unmarshalObject(b []byte) error {
+	var v struct {
+		Value *float64 `json:"value"`
+		Unit  string   `json:"unit"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Errorf("invalid duration %s: %w", b, err)
+	}
+	if v.Value == nil {
+		return fmt.Errorf("invalid duration %s: missing value", b)
+	}
+	unit, ok := microDurationUnits[strings.ToLower(v.Unit)]
+	if !ok {
+		return fmt.Errorf("invalid duration %s: unknown unit %q", b, v.Unit)
+	}
+	*t = NewMicroDuration(time.Duration(*v.Value * float64(unit)))
+	return nil
+}
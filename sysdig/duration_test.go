@@ -68,6 +68,31 @@ func TestMicroDuration_JSONUnmarshal(t *testing.T) {
 			want:    NewMicroDuration(time.Millisecond),
 			wantErr: false,
 		},
+		{
+			name: "object with long unit",
+			in:   []byte(`{"value":10,"unit":"minutes"}`),
+			want: NewMicroDuration(10 * time.Minute),
+		},
+		{
+			name: "object with short unit",
+			in:   []byte(`{"unit": "s", "value": 1.5}`),
+			want: NewMicroDuration(1500 * time.Millisecond),
+		},
+		{
+			name:    "object with unknown unit",
+			in:      []byte(`{"value":10,"unit":"fortnights"}`),
+			wantErr: true,
+		},
+		{
+			name:    "object without value",
+			in:      []byte(`{"unit":"s"}`),
+			wantErr: true,
+		},
+		{
+			name:    "string",
+			in:      []byte(`"10m"`),
+			wantErr: true,
+		},
 		{
 			name:    "invalid",
 			in:      []byte("not a duration"),
@@ -88,3 +113,17 @@ func TestMicroDuration_JSONUnmarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestMicroDuration_String(t *testing.T) {
+	d := NewMicroDuration(90*time.Second + 500*time.Microsecond)
+	if got, want := d.String(), "1m30.0005s"; got != want {
+		t.Errorf("got: %s, want: %s", got, want)
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "90000500"; got != want {
+		t.Errorf("got JSON: %s, want: %s", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package sysdig
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/trinchan/sysdig-go/sysdig/authentication"
+	"github.com/trinchan/sysdig-go/sysdig/authentication/accesstoken"
+	"github.com/trinchan/sysdig-go/sysdig/authentication/ibmiam"
+)
+
+// Environment variables read by NewClientFromEnv.
+const (
+	// EnvAccessToken is the Sysdig access token used for access token authentication.
+	EnvAccessToken = "SYSDIG_ACCESS_TOKEN"
+	// EnvIBMAPIKey is the IBM Cloud API key used for IBM Cloud IAM authentication.
+	EnvIBMAPIKey = "IBM_API_KEY"
+	// EnvInstanceID is the IBM Cloud Monitoring instance ID.
+	EnvInstanceID = "SYSDIG_INSTANCE_ID"
+	// EnvTeamID is the Sysdig Team ID.
+	EnvTeamID = "SYSDIG_TEAM_ID"
+	// EnvBaseURL is the Sysdig API base URL.
+	EnvBaseURL = "SYSDIG_BASE_URL"
+	// EnvIBMRegion is the IBM Cloud Region of the Sysdig instance.
+	EnvIBMRegion = "SYSDIG_IBM_REGION"
+)
+
+// NewClientFromEnv returns a new Sysdig API client configured from environment variables.
+//
+// If IBM_API_KEY is set, the client authenticates with IBM Cloud IAM and requires SYSDIG_INSTANCE_ID as well as
+// one of SYSDIG_BASE_URL or SYSDIG_IBM_REGION. Otherwise, the client authenticates with SYSDIG_ACCESS_TOKEN.
+// SYSDIG_TEAM_ID, SYSDIG_INSTANCE_ID, SYSDIG_BASE_URL and SYSDIG_IBM_REGION are optional for access token
+// authentication. SYSDIG_BASE_URL takes precedence over SYSDIG_IBM_REGION.
+//
+// The provided options are applied after the environment configuration and may override it.
+func NewClientFromEnv(options ...ClientOption) (*Client, error) {
+	accessToken := os.Getenv(EnvAccessToken)
+	apiKey := os.Getenv(EnvIBMAPIKey)
+	instanceID := os.Getenv(EnvInstanceID)
+	teamID := os.Getenv(EnvTeamID)
+	baseURL := os.Getenv(EnvBaseURL)
+	ibmRegion := os.Getenv(EnvIBMRegion)
+
+	var missing []string
+	if apiKey != "" {
+		if instanceID == "" {
+			missing = append(missing, EnvInstanceID)
+		}
+		if baseURL == "" && ibmRegion == "" {
+			missing = append(missing, EnvBaseURL+" or "+EnvIBMRegion)
+		}
+	} else if accessToken == "" {
+		missing = append(missing, EnvAccessToken+" or "+EnvIBMAPIKey)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required environment variables: %s", strings.Join(missing, ", "))
+	}
+
+	var authenticator authentication.Authenticator
+	var err error
+	if apiKey != "" {
+		authenticator, err = ibmiam.Authenticator(
+			apiKey,
+			ibmiam.WithIBMInstanceID(instanceID),
+			ibmiam.WithSysdigTeamID(teamID),
+		)
+	} else {
+		authenticator, err = accesstoken.Authenticator(
+			accessToken,
+			accesstoken.WithIBMInstanceID(instanceID),
+			accesstoken.WithSysdigTeamID(teamID),
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var envOptions []ClientOption
+	switch {
+	case baseURL != "":
+		envOptions = append(envOptions, WithBaseURL(baseURL))
+	case ibmRegion != "":
+		envOptions = append(envOptions, WithIBMBaseURL(Region(ibmRegion), false))
+	}
+	return NewClient(authenticator, append(envOptions, options...)...)
+}
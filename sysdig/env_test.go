@@ -0,0 +1,118 @@
+//go:build go1.17
+// +build go1.17
+
+package sysdig
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/trinchan/sysdig-go/sysdig/authentication"
+)
+
+func TestNewClientFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		options     []ClientOption
+		wantBaseURL string
+		wantHeaders map[string]string
+		wantErr     []string
+	}{
+		{
+			name:        "access token",
+			env:         map[string]string{EnvAccessToken: "token", EnvTeamID: "1"},
+			wantBaseURL: defaultBaseURL,
+			wantHeaders: map[string]string{
+				authentication.AuthorizationHeader: "Bearer token",
+				authentication.SysdigTeamIDHeader:  "1",
+			},
+		},
+		{
+			name: "access token with IBM region",
+			env: map[string]string{
+				EnvAccessToken: "token",
+				EnvInstanceID:  "instance",
+				EnvIBMRegion:   string(RegionUSSouth),
+			},
+			wantBaseURL: "https://us-south.monitoring.cloud.ibm.com/",
+			wantHeaders: map[string]string{
+				authentication.AuthorizationHeader: "Bearer token",
+				authentication.IBMInstanceIDHeader: "instance",
+			},
+		},
+		{
+			name: "base URL takes precedence over IBM region",
+			env: map[string]string{
+				EnvAccessToken: "token",
+				EnvBaseURL:     "https://sysdig.example.com/",
+				EnvIBMRegion:   string(RegionUSSouth),
+			},
+			wantBaseURL: "https://sysdig.example.com/",
+		},
+		{
+			name:        "options override environment",
+			env:         map[string]string{EnvAccessToken: "token", EnvBaseURL: "https://sysdig.example.com/"},
+			options:     []ClientOption{WithBaseURL("https://override.example.com/")},
+			wantBaseURL: "https://override.example.com/",
+		},
+		{
+			name: "IBM IAM",
+			env: map[string]string{
+				EnvIBMAPIKey:  "key",
+				EnvInstanceID: "instance",
+				EnvIBMRegion:  string(RegionEUDE),
+			},
+			wantBaseURL: "https://eu-de.monitoring.cloud.ibm.com/",
+		},
+		{
+			name:    "IBM IAM missing instance and region",
+			env:     map[string]string{EnvIBMAPIKey: "key"},
+			wantErr: []string{EnvInstanceID, EnvBaseURL + " or " + EnvIBMRegion},
+		},
+		{
+			name:    "no credentials",
+			env:     map[string]string{},
+			wantErr: []string{EnvAccessToken + " or " + EnvIBMAPIKey},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, k := range []string{EnvAccessToken, EnvIBMAPIKey, EnvInstanceID, EnvTeamID, EnvBaseURL, EnvIBMRegion} {
+				t.Setenv(k, test.env[k])
+			}
+			client, err := NewClientFromEnv(test.options...)
+			if len(test.wantErr) > 0 {
+				if err == nil {
+					t.Fatal("did not return an expected error")
+				}
+				for _, want := range test.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not mention %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := client.BaseURL.String(); got != test.wantBaseURL {
+				t.Errorf("BaseURL = %q, want %q", got, test.wantBaseURL)
+			}
+			if len(test.wantHeaders) == 0 {
+				return
+			}
+			req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := client.authenticator.Authenticate(req); err != nil {
+				t.Fatal(err)
+			}
+			for header, want := range test.wantHeaders {
+				testHeader(t, req, header, want)
+			}
+		})
+	}
+}
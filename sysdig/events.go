@@ -2,8 +2,15 @@ package sysdig
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // EventsService is the Service for communicating with the Sysdig Events API.
@@ -62,6 +69,51 @@ const (
 	SeverityNone SeverityLabel = "NONE"
 )
 
+// SeverityUnknown is the Severity of SeverityNone and unrecognized SeverityLabels. It is not a valid Severity for
+// the Sysdig API.
+const SeverityUnknown Severity = -1
+
+// Label returns the SeverityLabel of the Severity, following the Sysdig mapping of syslog severities to labels:
+//   - SeverityEmergency and SeverityAlert (0-1) are SeverityHigh.
+//   - SeverityCritical and SeverityError (2-3) are SeverityMedium.
+//   - SeverityWarning and SeverityNotice (4-5) are SeverityLow.
+//   - SeverityInformational and SeverityDebug (6-7) are SeverityInfo.
+//
+// Severities outside of 0-7 are SeverityNone.
+func (s Severity) Label() SeverityLabel {
+	switch {
+	case s < SeverityEmergency || s > SeverityDebug:
+		return SeverityNone
+	case s <= SeverityAlert:
+		return SeverityHigh
+	case s <= SeverityError:
+		return SeverityMedium
+	case s <= SeverityNotice:
+		return SeverityLow
+	default:
+		return SeverityInfo
+	}
+}
+
+// Severity returns the most severe Severity with the SeverityLabel, see Severity.Label for the mapping, so
+// l.Severity().Label() == l for every known SeverityLabel. SeverityNone and unrecognized SeverityLabels are
+// SeverityUnknown. The mapping is lossy in the other direction, e.g. SeverityError.Label().Severity() is
+// SeverityCritical.
+func (l SeverityLabel) Severity() Severity {
+	switch l {
+	case SeverityHigh:
+		return SeverityEmergency
+	case SeverityMedium:
+		return SeverityCritical
+	case SeverityLow:
+		return SeverityWarning
+	case SeverityInfo:
+		return SeverityInformational
+	default:
+		return SeverityUnknown
+	}
+}
+
 // Direction defines the ordering of a list of events. (?) TODO figure out what this parameter actually does
 type Direction string
 
@@ -92,6 +144,10 @@ const (
 // marshalling into the proper JSON field.
 type Categories []Category
 
+// DefaultEventCategories are the Categories used by EventsService.List when no Categories are provided and the
+// Client was created with WithDefaultEventCategories and no explicit categories.
+var DefaultEventCategories = Categories{CategoryCustom, CategoryAlert}
+
 // Status is an event status. Can be used as a filter in EventsService.List.
 type Status string
 
@@ -126,12 +182,17 @@ type Event struct {
 }
 
 // EventOptions are the parameters that make up an Event. To be used with EventsService.Create.
+//
+// Note: The Sysdig Events API has no client-provided Event ID or deduplication key, and every create request makes a
+// new Event. Repeated runs of a job which must not create duplicate Events should check for an existing Event with
+// EventsService.List first, or use EventsService.CreateWithKey to at least deduplicate retries of the same request
+// by servers which honor idempotency keys.
 type EventOptions struct {
 	// Name is the name of the event.
 	Name string `json:"name"`
 	// Description is a description of the event.
 	Description string `json:"description,omitempty"`
-	// Timestamp is the MilliTime an event occurred.
+	// Timestamp is the MilliTime an event occurred. A zero Timestamp is omitted, leaving it to the API to set.
 	Timestamp MilliTime `json:"timestamp,omitempty"`
 	// Severity is the Severity to the associated with the event.
 	Severity SeverityLabel `json:"severity,omitempty"`
@@ -141,13 +202,93 @@ type EventOptions struct {
 	Tags map[string]string `json:"tags,omitempty"`
 }
 
+// MarshalJSON implements json.Marshaler for EventOptions. MilliTime is a struct, so omitempty has no effect on
+// Timestamp and a zero Timestamp is omitted here instead.
+func (o EventOptions) MarshalJSON() ([]byte, error) {
+	type eventOptions EventOptions
+	v := struct {
+		eventOptions
+		Timestamp *MilliTime `json:"timestamp,omitempty"`
+	}{eventOptions: eventOptions(o)}
+	if !o.Timestamp.IsZero() {
+		v.Timestamp = &o.Timestamp
+	}
+	return json.Marshal(v)
+}
+
+// CaptureIDsTag is the Event tag linking an Event to Captures. The Sysdig Events API has no field referencing
+// Captures, so the IDs of the linked Captures are stored in this tag as a comma separated list, which is returned
+// with the Event.
+const CaptureIDsTag = "captureIds"
+
+// LinkCaptures links the Event to the Captures with the given IDs, e.g. a Capture triggered alongside the Event,
+// by adding them to the CaptureIDsTag. Use Event.CaptureIDs to read them back.
+func (o *EventOptions) LinkCaptures(captureIDs ...int) {
+	if len(captureIDs) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(captureIDs)+1)
+	if linked := o.Tags[CaptureIDsTag]; linked != "" {
+		ids = append(ids, linked)
+	}
+	for _, id := range captureIDs {
+		ids = append(ids, strconv.Itoa(id))
+	}
+	if o.Tags == nil {
+		o.Tags = make(map[string]string)
+	}
+	o.Tags[CaptureIDsTag] = strings.Join(ids, ",")
+}
+
+// CaptureIDs returns the IDs of the Captures linked to the Event with EventOptions.LinkCaptures.
+// An error is returned if the CaptureIDsTag is not a comma separated list of IDs.
+func (e Event) CaptureIDs() ([]int, error) {
+	linked := e.Tags[CaptureIDsTag]
+	if linked == "" {
+		return nil, nil
+	}
+	var ids []int
+	for _, s := range strings.Split(linked, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s tag %q: %w", CaptureIDsTag, linked, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 // EventResponse describes an EventResponse returned from the Sysdig API.
 type EventResponse struct {
 	Event Event `json:"event"`
 }
 
-// Get retrieves an Event.
+// ErrEventNotFound is matched with errors.Is by errors returned by EventsService.Get when the Event does not exist.
+var ErrEventNotFound = errors.New("event not found")
+
+// eventNotFoundError wraps the *ErrorResponse of a missing Event so it matches ErrEventNotFound.
+type eventNotFoundError struct {
+	eventID string
+	*ErrorResponse
+}
+
+func (e *eventNotFoundError) Error() string {
+	return fmt.Sprintf("%v: %q: %v", ErrEventNotFound, e.eventID, e.ErrorResponse)
+}
+
+func (e *eventNotFoundError) Is(target error) bool {
+	return target == ErrEventNotFound
+}
+
+func (e *eventNotFoundError) Unwrap() error {
+	return e.ErrorResponse
+}
+
+// Get retrieves an Event. If the Event does not exist, the returned error matches ErrEventNotFound.
 func (s *EventsService) Get(ctx context.Context, eventID string) (*EventResponse, *http.Response, error) {
+	if eventID == "" {
+		return nil, nil, fmt.Errorf("event id must be set")
+	}
 	u := fmt.Sprintf("api/v2/events/%s", eventID)
 	req, err := s.client.NewRequest(http.MethodGet, u, nil)
 	if err != nil {
@@ -155,6 +296,10 @@ func (s *EventsService) Get(ctx context.Context, eventID string) (*EventResponse
 	}
 	c := new(EventResponse)
 	resp, err := s.client.Do(ctx, req, c)
+	var errorResponse *ErrorResponse
+	if IsNotFound(err) && errors.As(err, &errorResponse) {
+		return nil, resp, &eventNotFoundError{eventID: eventID, ErrorResponse: errorResponse}
+	}
 	return c, resp, err
 }
 
@@ -167,11 +312,13 @@ type ListEventsResponse struct {
 
 // ListEventOptions defines the search parameters for EventsService.List.
 type ListEventOptions struct {
-	// Filter can filter events by name.
+	// Filter can filter events by name. The events API has no separate description filter, and whether Filter also
+	// matches descriptions is not documented by Sysdig.
 	Filter string
 	// AlertStatus filters events to the matching Status.
 	AlertStatus Status
-	// Categories filters events to the matching Categories.
+	// Categories filters events to the matching Categories. If empty, all categories are returned unless the Client
+	// was created with WithDefaultEventCategories.
 	Categories Categories
 	// Direction orders the list of events.
 	Direction Direction
@@ -179,24 +326,73 @@ type ListEventOptions struct {
 	Scope string
 	// Limit limits the number of events to retrieve, default 100.
 	Limit int
-	// Pivot is the Event ID to be used as a pivot.
+	// Pivot is the Event ID to be used as a pivot. Requires a Direction and cannot be combined with a time window.
 	Pivot string
 	// From is the timestamp for the beginning of the events to retrieve.
 	From MilliTime
 	// To is the timestamp for the end of the events to retrieve.
 	To MilliTime
+	// Within retrieves the events in the window of the given duration ending at To, or now if To is unset, e.g. an
+	// hour for events from the last hour. An explicit From takes precedence and Within is ignored if From is set.
+	Within time.Duration
 	// IncludeTotal determines whether the return the total count of events and not just the matched events.
 	IncludeTotal bool
+	// Feed determines whether to list events in feed mode. Set to false to retrieve the non-feed (categorized)
+	// listing. Defaults to true if unset.
+	Feed *bool
+	// IncludePivot determines whether the event used as the Pivot is included in the results. Defaults to true if unset.
+	IncludePivot *bool
+}
+
+// validate returns an error for contradictory ListEventOptions, which the API rejects with unclear messages.
+// The valid combinations are:
+//   - a time window set with From, To and Within, optionally with a Direction, to list the events in the window, or
+//   - a Pivot with a Direction, to list the events before or after the Pivot.
+func (o ListEventOptions) validate() error {
+	switch {
+	case o.Limit < 0:
+		return fmt.Errorf("invalid ListEventOptions: Limit %d must not be negative", o.Limit)
+	case o.Within < 0:
+		return fmt.Errorf("invalid ListEventOptions: Within %s must not be negative", o.Within)
+	case !o.From.IsZero() && !o.To.IsZero() && o.From.After(o.To.Time):
+		return fmt.Errorf("invalid ListEventOptions: From %s is after To %s", o.From, o.To)
+	case o.Pivot != "" && o.Direction == "":
+		return fmt.Errorf("invalid ListEventOptions: Pivot %q requires a Direction to list the events before or after it", o.Pivot)
+	case o.Pivot != "" && (!o.From.IsZero() || !o.To.IsZero() || o.Within > 0):
+		return fmt.Errorf("invalid ListEventOptions: Pivot %q cannot be combined with a time window set by From, To or Within", o.Pivot)
+	}
+	return nil
+}
+
+// window returns the time window of the ListEventOptions, resolving Within relative to To, or now if To is unset.
+func (o ListEventOptions) window(now time.Time) (from, to MilliTime) {
+	from, to = o.From, o.To
+	if o.Within > 0 && from.IsZero() {
+		if to.IsZero() {
+			to = NewMilliTime(now)
+		}
+		from = NewMilliTime(to.Add(-o.Within))
+	}
+	return from, to
 }
 
 // List lists events with the given ListEventOptions.
+// Contradictory options, such as a Pivot combined with a time window, return an error without making a request.
+// See ListEventOptions.Pivot for the valid combinations.
+// If the Client was created WithScopeValidation, an invalid Scope returns a *scope.ParseError without making a request.
 func (s *EventsService) List(ctx context.Context, options ListEventOptions) (*ListEventsResponse, *http.Response, error) {
+	if err := options.validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := s.client.validateScope(options.Scope); err != nil {
+		return nil, nil, err
+	}
 	type listEventOptions struct {
 		Filter      string     `url:"filter,omitempty"`
 		AlertStatus Status     `url:"alertStatus,omitempty"`
 		Categories  Categories `url:"category,comma,omitempty"`
 		Direction   Direction  `url:"dir,omitempty"`
-		Feed        bool       `url:"feed,omitempty"`
+		Feed        bool       `url:"feed"`
 		Limit       int        `url:"limit,omitempty"`
 		Pivot       string     `url:"pivot,omitempty"`
 		From        MilliTime  `url:"from,omitempty"`
@@ -208,20 +404,29 @@ func (s *EventsService) List(ctx context.Context, options ListEventOptions) (*Li
 	}
 
 	u := "api/v2/events"
+	categories := options.Categories
+	if len(categories) == 0 {
+		categories = s.client.defaultEventCategories
+	}
 	o := listEventOptions{
 		Filter:       options.Filter,
 		AlertStatus:  options.AlertStatus,
-		Categories:   options.Categories,
+		Categories:   categories,
 		Direction:    options.Direction,
 		Limit:        options.Limit,
 		Pivot:        options.Pivot,
 		Scope:        options.Scope,
 		IncludeTotal: options.IncludeTotal,
-		From:         options.From,
-		To:           options.To,
 		Feed:         true,
 		IncludePivot: true,
 	}
+	o.From, o.To = options.window(s.client.now())
+	if options.Feed != nil {
+		o.Feed = *options.Feed
+	}
+	if options.IncludePivot != nil {
+		o.IncludePivot = *options.IncludePivot
+	}
 
 	uWithOpt, err := addOptions(u, o)
 	if err != nil {
@@ -236,8 +441,150 @@ func (s *EventsService) List(ctx context.Context, options ListEventOptions) (*Li
 	return c, resp, err
 }
 
+// ListWithTotal lists events like List, but ensures ListEventsResponse.Total is populated so callers can determine
+// whether more events exist. If options.IncludeTotal is false and the response has no total, a lightweight request
+// with IncludeTotal set is issued to reconcile it. The returned http.Response is that of the List request, unless the
+// reconciling request fails.
+func (s *EventsService) ListWithTotal(
+	ctx context.Context,
+	options ListEventOptions) (*ListEventsResponse, *http.Response, error) {
+	events, resp, err := s.List(ctx, options)
+	if err != nil || options.IncludeTotal || events.Total != 0 {
+		return events, resp, err
+	}
+	total, countResp, err := s.Count(ctx, options)
+	if err != nil {
+		return nil, countResp, err
+	}
+	events.Total = total
+	return events, resp, nil
+}
+
+// Count returns the total number of events matching options without fetching the events themselves.
+// options.Limit and options.IncludeTotal are overridden to request a single event along with the total.
+func (s *EventsService) Count(ctx context.Context, options ListEventOptions) (int, *http.Response, error) {
+	options.IncludeTotal = true
+	options.Limit = 1
+	events, resp, err := s.List(ctx, options)
+	if err != nil {
+		return 0, resp, err
+	}
+	return events.Total, resp, nil
+}
+
+// CountByLabel counts the Events between from and to matching filters, grouped by the value of the given scope
+// label. Events without the label are counted under the empty string. The Events API has no server side
+// aggregation, so all matching Events are fetched, filters.Limit at a time, and counted client side.
+// filters.From, filters.To and filters.Pivot are overridden.
+func (s *EventsService) CountByLabel(
+	ctx context.Context,
+	from, to MilliTime,
+	label string,
+	filters ListEventOptions) (map[string]int, *http.Response, error) {
+	filters.From = from
+	filters.To = to
+	filters.Within = 0
+	pages := newEventPages(filters, s.client.now())
+	counts := make(map[string]int)
+	for {
+		page, resp, err := pages.next(ctx, s)
+		if err != nil {
+			return nil, resp, err
+		}
+		for _, event := range page {
+			counts[event.ScopeLabels[label]]++
+		}
+		if pages.done {
+			return counts, resp, nil
+		}
+	}
+}
+
+// eventPages lists all Events matching options a page at a time, using the last Event of each page as the pivot for
+// the next. A Pivot cannot be combined with a time window, so the window of the first page is applied client side to
+// the following pages.
+type eventPages struct {
+	options  ListEventOptions
+	from, to MilliTime
+	total    int
+	seen     int
+	done     bool
+}
+
+// newEventPages creates an eventPages for options, resolving its time window relative to now. options.Pivot is
+// overridden and options.Direction defaults to DirectionBefore.
+func newEventPages(options ListEventOptions, now time.Time) *eventPages {
+	options.IncludeTotal = true
+	options.Pivot = ""
+	if options.Direction == "" {
+		options.Direction = DirectionBefore
+	}
+	p := &eventPages{options: options}
+	p.from, p.to = options.window(now)
+	p.options.From, p.options.To, p.options.Within = p.from, p.to, 0
+	return p
+}
+
+// next fetches the next page of Events. done is set once the last page has been fetched.
+func (p *eventPages) next(ctx context.Context, s *EventsService) ([]Event, *http.Response, error) {
+	events, resp, err := s.List(ctx, p.options)
+	if err != nil {
+		return nil, resp, err
+	}
+	page := events.Events
+	if pivot := p.options.Pivot; pivot == "" {
+		// Only the first page is listed with the time window, so its total is the number of Events to list.
+		p.total = events.Total
+	} else {
+		// The pivot is included in the response, so skip it as it was the last Event of the previous page.
+		if len(page) > 0 && page[0].ID == pivot {
+			page = page[1:]
+		}
+		for i, event := range page {
+			if !p.inWindow(event.Timestamp) {
+				page, p.done = page[:i], true
+				break
+			}
+		}
+	}
+	p.seen += len(page)
+	if len(page) == 0 || p.seen >= p.total {
+		p.done = true
+	}
+	if !p.done {
+		p.options.Pivot = page[len(page)-1].ID
+		p.options.From, p.options.To = MilliTime{}, MilliTime{}
+	}
+	return page, resp, nil
+}
+
+// inWindow returns whether t is within the time window of the first page.
+func (p *eventPages) inWindow(t MilliTime) bool {
+	return (p.from.IsZero() || !t.Before(p.from.Time)) && (p.to.IsZero() || !t.After(p.to.Time))
+}
+
 // Create creates an event.
+// If the Client was created WithScopeValidation, an invalid Scope returns a *scope.ParseError without making a request.
 func (s *EventsService) Create(ctx context.Context, event EventOptions) (*EventResponse, *http.Response, error) {
+	return s.create(ctx, event, "")
+}
+
+// CreateWithKey creates an event like Create, sending key in the IdempotencyKeyHeader.
+// The key is not stored on the Event, see EventOptions for deduplicating Events.
+func (s *EventsService) CreateWithKey(
+	ctx context.Context,
+	event EventOptions,
+	key string) (*EventResponse, *http.Response, error) {
+	if key == "" {
+		return nil, nil, fmt.Errorf("idempotency key cannot be blank")
+	}
+	return s.create(ctx, event, key)
+}
+
+func (s *EventsService) create(ctx context.Context, event EventOptions, key string) (*EventResponse, *http.Response, error) {
+	if err := s.client.validateScope(event.Scope); err != nil {
+		return nil, nil, err
+	}
 	type eventRequest struct {
 		Event EventOptions `json:"event"`
 	}
@@ -246,6 +593,82 @@ func (s *EventsService) Create(ctx context.Context, event EventOptions) (*EventR
 	if err != nil {
 		return nil, nil, err
 	}
+	s.client.setIdempotencyKey(req, key)
+	c := new(EventResponse)
+	resp, err := s.client.Do(ctx, req, c)
+	return c, resp, err
+}
+
+// Update edits the metadata of an event, keeping its ID. Only the non-empty fields of options are sent, so fields left
+// empty are unchanged.
+// If the Client was created WithScopeValidation, an invalid Scope returns a *scope.ParseError without making a request.
+func (s *EventsService) Update(
+	ctx context.Context,
+	eventID string,
+	options EventOptions) (*EventResponse, *http.Response, error) {
+	if strings.TrimSpace(eventID) == "" {
+		return nil, nil, fmt.Errorf("event id must be set")
+	}
+	if err := s.client.validateScope(options.Scope); err != nil {
+		return nil, nil, err
+	}
+	type eventUpdate struct {
+		Name        string            `json:"name,omitempty"`
+		Description string            `json:"description,omitempty"`
+		Timestamp   *MilliTime        `json:"timestamp,omitempty"`
+		Severity    SeverityLabel     `json:"severity,omitempty"`
+		Scope       string            `json:"scope,omitempty"`
+		Tags        map[string]string `json:"tags,omitempty"`
+	}
+	type updateRequest struct {
+		Event eventUpdate `json:"event"`
+	}
+	update := eventUpdate{
+		Name:        options.Name,
+		Description: options.Description,
+		Severity:    options.Severity,
+		Scope:       options.Scope,
+		Tags:        options.Tags,
+	}
+	if !options.Timestamp.IsZero() {
+		update.Timestamp = &options.Timestamp
+	}
+	u := fmt.Sprintf("api/v2/events/%s", eventID)
+	req, err := s.client.NewRequest(http.MethodPatch, u, updateRequest{update})
+	if err != nil {
+		return nil, nil, err
+	}
+	c := new(EventResponse)
+	resp, err := s.client.Do(ctx, req, c)
+	return c, resp, err
+}
+
+// Acknowledge acknowledges an event, transitioning it to StatusAcknowledged.
+func (s *EventsService) Acknowledge(ctx context.Context, eventID string) (*EventResponse, *http.Response, error) {
+	return s.setStatus(ctx, eventID, StatusAcknowledged)
+}
+
+// Resolve resolves an event, transitioning it to StatusResolved.
+func (s *EventsService) Resolve(ctx context.Context, eventID string) (*EventResponse, *http.Response, error) {
+	return s.setStatus(ctx, eventID, StatusResolved)
+}
+
+func (s *EventsService) setStatus(ctx context.Context, eventID string, status Status) (*EventResponse, *http.Response, error) {
+	if strings.TrimSpace(eventID) == "" {
+		return nil, nil, fmt.Errorf("event id must be set")
+	}
+	type statusRequest struct {
+		Event struct {
+			Status Status `json:"status"`
+		} `json:"event"`
+	}
+	var r statusRequest
+	r.Event.Status = status
+	u := fmt.Sprintf("api/v2/events/%s", eventID)
+	req, err := s.client.NewRequest(http.MethodPatch, u, r)
+	if err != nil {
+		return nil, nil, err
+	}
 	c := new(EventResponse)
 	resp, err := s.client.Do(ctx, req, c)
 	return c, resp, err
@@ -261,3 +684,65 @@ func (s *EventsService) Delete(ctx context.Context, eventID string) (*http.Respo
 	resp, err := s.client.Do(ctx, req, nil)
 	return resp, err
 }
+
+// DeleteMatching deletes all Events matching options, returning the number of Events deleted. The matching Events are
+// listed first, following pages like ListAll, and then deleted by up to concurrency concurrent requests.
+// Failed deletes do not stop the others and are returned as a *BatchError indexed by the order the Events were listed.
+// If ctx is canceled, no further deletes are started and ctx.Err() is returned.
+func (s *EventsService) DeleteMatching(ctx context.Context, options ListEventOptions, concurrency int) (int, error) {
+	var events []Event
+	pages := newEventPages(options, s.client.now())
+	for !pages.done {
+		page, _, err := pages.next(ctx, s)
+		if err != nil {
+			return 0, err
+		}
+		events = append(events, page...)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		deleted  int
+		failures []BatchItemError
+	)
+	indexes := make(chan int)
+	for w := 0; w < concurrency && w < len(events); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				_, err := s.Delete(ctx, events[i].ID)
+				mu.Lock()
+				if err != nil {
+					failures = append(failures, BatchItemError{Index: i, Err: fmt.Errorf("event %q: %w", events[i].ID, err)})
+				} else {
+					deleted++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+dispatch:
+	for i := range events {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return deleted, err
+	}
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool { return failures[i].Index < failures[j].Index })
+		return deleted, &BatchError{Total: len(events), Errors: failures}
+	}
+	return deleted, nil
+}
@@ -3,12 +3,18 @@ package sysdig
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/trinchan/sysdig-go/sysdig/scope"
 )
 
 func TestEventsService_Create(t *testing.T) {
@@ -146,14 +152,171 @@ func TestEventsService_List(t *testing.T) {
 
 	testBadOptions(t, methodName, func() (err error) {
 		_, _, err = client.Events.List(context.Background(), ListEventOptions{
-			Filter: "\n",
-			Pivot:  "0\n",
+			Filter:    "\n",
+			Pivot:     "0\n",
+			Direction: DirectionBefore,
 		})
 		return err
 	})
 
 }
 
+func TestEventsService_List_Within(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	var from, to int64
+	mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		var err error
+		if from, err = strconv.ParseInt(r.FormValue("from"), 10, 64); err != nil {
+			t.Errorf("parsing from: %v", err)
+		}
+		if to, err = strconv.ParseInt(r.FormValue("to"), 10, 64); err != nil {
+			t.Errorf("parsing to: %v", err)
+		}
+		fmt.Fprint(w, `{"total":0,"matched":0,"events":[]}`)
+	})
+	defer teardown()
+
+	end := time.Date(2022, time.March, 4, 5, 6, 7, 0, time.UTC)
+	tests := []struct {
+		name     string
+		options  ListEventOptions
+		wantFrom int64
+		wantTo   int64
+	}{
+		{
+			name:     "relative to explicit to",
+			options:  ListEventOptions{Within: time.Hour, To: NewMilliTime(end)},
+			wantFrom: end.Add(-time.Hour).UnixNano() / 1e6,
+			wantTo:   end.UnixNano() / 1e6,
+		},
+		{
+			name:     "explicit from takes precedence",
+			options:  ListEventOptions{Within: time.Hour, From: NewMilliTime(end.Add(-time.Minute)), To: NewMilliTime(end)},
+			wantFrom: end.Add(-time.Minute).UnixNano() / 1e6,
+			wantTo:   end.UnixNano() / 1e6,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := client.Events.List(context.Background(), test.options); err != nil {
+				t.Fatalf("Events.List returned error: %v", err)
+			}
+			if from != test.wantFrom || to != test.wantTo {
+				t.Errorf("got window: [%d, %d], want: [%d, %d]", from, to, test.wantFrom, test.wantTo)
+			}
+		})
+	}
+
+	t.Run("relative to now", func(t *testing.T) {
+		before := time.Now().UnixNano() / 1e6
+		if _, _, err := client.Events.List(context.Background(), ListEventOptions{Within: time.Hour}); err != nil {
+			t.Fatalf("Events.List returned error: %v", err)
+		}
+		after := time.Now().UnixNano() / 1e6
+		if to < before || to > after {
+			t.Errorf("got to: %d, want between %d and %d", to, before, after)
+		}
+		if got, want := to-from, time.Hour.Milliseconds(); got != want {
+			t.Errorf("got window: %dms, want: %dms", got, want)
+		}
+	})
+
+	t.Run("relative to clock", func(t *testing.T) {
+		if err := WithClock(func() time.Time { return end })(client); err != nil {
+			t.Fatal(err)
+		}
+		defer func() { client.clock = time.Now }()
+		if _, _, err := client.Events.List(context.Background(), ListEventOptions{Within: time.Hour}); err != nil {
+			t.Fatalf("Events.List returned error: %v", err)
+		}
+		if wantFrom, wantTo := end.Add(-time.Hour).UnixNano()/1e6, end.UnixNano()/1e6; from != wantFrom || to != wantTo {
+			t.Errorf("got window: [%d, %d], want: [%d, %d]", from, to, wantFrom, wantTo)
+		}
+		if err := WithClock(nil)(client); err == nil {
+			t.Error("WithClock(nil) expected error")
+		}
+	})
+}
+
+func TestListEventOptions_validate(t *testing.T) {
+	from, to := NewMilliTime(time.Unix(100, 0)), NewMilliTime(time.Unix(200, 0))
+	tests := []struct {
+		name    string
+		options ListEventOptions
+		wantErr string
+	}{
+		{
+			name:    "time window",
+			options: ListEventOptions{From: from, To: to, Direction: DirectionBefore, Limit: 10},
+		},
+		{
+			name:    "pivot",
+			options: ListEventOptions{Pivot: "1", Direction: DirectionAfter},
+		},
+		{
+			name:    "negative limit",
+			options: ListEventOptions{Limit: -1},
+			wantErr: "Limit -1 must not be negative",
+		},
+		{
+			name:    "negative within",
+			options: ListEventOptions{Within: -time.Hour},
+			wantErr: "Within -1h0m0s must not be negative",
+		},
+		{
+			name:    "from after to",
+			options: ListEventOptions{From: to, To: from},
+			wantErr: "is after To",
+		},
+		{
+			name:    "pivot without direction",
+			options: ListEventOptions{Pivot: "1"},
+			wantErr: "requires a Direction",
+		},
+		{
+			name:    "pivot with from",
+			options: ListEventOptions{Pivot: "1", Direction: DirectionBefore, From: from},
+			wantErr: "cannot be combined with a time window",
+		},
+		{
+			name:    "pivot with to",
+			options: ListEventOptions{Pivot: "1", Direction: DirectionBefore, To: to},
+			wantErr: "cannot be combined with a time window",
+		},
+		{
+			name:    "pivot with within",
+			options: ListEventOptions{Pivot: "1", Direction: DirectionBefore, Within: time.Hour},
+			wantErr: "cannot be combined with a time window",
+		},
+	}
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"total":0,"matched":0,"events":[]}`)
+	})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.options.validate()
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validate returned error: %v", err)
+				}
+				if _, _, err := client.Events.List(context.Background(), test.options); err != nil {
+					t.Errorf("Events.List returned error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("validate returned error %v, want error containing %q", err, test.wantErr)
+			}
+			if _, resp, err := client.Events.List(context.Background(), test.options); err == nil || resp != nil {
+				t.Errorf("Events.List returned response %v and error %v, want an error without a request", resp, err)
+			}
+		})
+	}
+}
+
 func TestEventsService_Delete(t *testing.T) {
 	methodName := "Delete"
 	client, mux, _, teardown := setup(nil)
@@ -244,3 +407,684 @@ func TestEventsService_Get(t *testing.T) {
 		return err
 	})
 }
+
+func TestEventsService_Get_NotFound(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/v2/events/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		if r.URL.Path != "/api/v2/events/1" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[{"reason":"Not Found","message":"Event not found"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"event":{"id":"1"}}`)
+	})
+	defer teardown()
+
+	event, _, err := client.Events.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Events.Get returned error: %v", err)
+	}
+	if want := (&EventResponse{Event: Event{ID: "1"}}); !cmp.Equal(event, want) {
+		t.Errorf("Events.Get returned %+v, want %+v", event, want)
+	}
+
+	event, resp, err := client.Events.Get(context.Background(), "2")
+	if !errors.Is(err, ErrEventNotFound) {
+		t.Fatalf("got error: %v, want ErrEventNotFound", err)
+	}
+	if !IsNotFound(err) {
+		t.Error("IsNotFound returned false for a missing event")
+	}
+	if event != nil {
+		t.Errorf("Events.Get returned %+v, want nil", event)
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Events.Get returned response %v, want a 404 response", resp)
+	}
+
+	if _, _, err := client.Events.Get(context.Background(), ""); err == nil || errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Events.Get with a blank ID returned error %v, want a validation error", err)
+	}
+}
+
+func TestEventsService_DeleteMatching(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		testFormValues(t, r, values{
+			"filter":        "stale",
+			"dir":           "before",
+			"feed":          "true",
+			"include_pivot": "true",
+			"include_total": "true",
+		})
+		fmt.Fprint(w, `{"total":5,"matched":5,"events":[{"id":"1"},{"id":"2"},{"id":"3"},{"id":"4"},{"id":"5"}]}`)
+	})
+	var (
+		mu                  sync.Mutex
+		deleted             []string
+		inFlight, maxFlight int
+	)
+	mux.HandleFunc("/api/v2/events/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodDelete)
+		id := strings.TrimPrefix(r.URL.Path, "/api/v2/events/")
+		mu.Lock()
+		inFlight++
+		if inFlight > maxFlight {
+			maxFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		deleted = append(deleted, id)
+		mu.Unlock()
+		if id == "4" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	n, err := client.Events.DeleteMatching(context.Background(), ListEventOptions{Filter: "stale"}, 2)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Events.DeleteMatching returned error %v, want a *BatchError", err)
+	}
+	if batchErr.Total != 5 || len(batchErr.Errors) != 1 || batchErr.Errors[0].Index != 3 {
+		t.Errorf("got batch error %v, want the fourth event to fail", batchErr)
+	}
+	if n != 4 {
+		t.Errorf("Events.DeleteMatching deleted %d events, want 4", n)
+	}
+	sort.Strings(deleted)
+	if want := []string{"1", "2", "3", "4", "5"}; !cmp.Equal(deleted, want) {
+		t.Errorf("Events.DeleteMatching sent deletes for %v, want %v", deleted, want)
+	}
+	if maxFlight > 2 {
+		t.Errorf("Events.DeleteMatching sent %d concurrent deletes, want at most 2", maxFlight)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Events.DeleteMatching(ctx, ListEventOptions{Filter: "stale"}, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Events.DeleteMatching with a canceled context returned error %v, want context.Canceled", err)
+	}
+}
+
+func TestEventsService_List_DefaultCategories(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      []ClientOption
+		categories   Categories
+		wantCategory string
+	}{
+		{
+			name:         "no default",
+			wantCategory: "",
+		},
+		{
+			name:         "package default",
+			options:      []ClientOption{WithDefaultEventCategories()},
+			wantCategory: "CUSTOM,ALERT",
+		},
+		{
+			name:         "custom default",
+			options:      []ClientOption{WithDefaultEventCategories(CategoryKubernetes)},
+			wantCategory: "KUBERNETES",
+		},
+		{
+			name:         "explicit categories",
+			options:      []ClientOption{WithDefaultEventCategories()},
+			categories:   Categories{CategoryDocker},
+			wantCategory: "DOCKER",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, mux, _, teardown := setup(nil)
+			defer teardown()
+			for _, o := range test.options {
+				if err := o(client); err != nil {
+					t.Fatal(err)
+				}
+			}
+			mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				if got := r.URL.Query().Get("category"); got != test.wantCategory {
+					t.Errorf("category = %q, want %q", got, test.wantCategory)
+				}
+				fmt.Fprint(w, `{"total":0,"matched":0,"events":[]}`)
+			})
+			_, _, err := client.Events.List(context.Background(), ListEventOptions{Categories: test.categories})
+			if err != nil {
+				t.Errorf("Events.List returned error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEventsService_ScopeValidation(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	if err := WithScopeValidation(true)(client); err != nil {
+		t.Fatal(err)
+	}
+	hits := 0
+	mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Method == http.MethodPost {
+			fmt.Fprint(w, `{"event":{"id":"1"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"total":0,"matched":0,"events":[]}`)
+	})
+
+	tests := []struct {
+		name    string
+		scope   string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			scope: `host.hostName = 'foo' and kubernetes.namespace.name in ('a', 'b')`,
+		},
+		{
+			name:    "invalid",
+			scope:   `host.hostName = foo`,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hits = 0
+			_, _, lerr := client.Events.List(context.Background(), ListEventOptions{Scope: test.scope})
+			_, _, cerr := client.Events.Create(context.Background(), EventOptions{Name: "test", Scope: test.scope})
+			for _, err := range []error{lerr, cerr} {
+				var parseError *scope.ParseError
+				if got := errors.As(err, &parseError); got != test.wantErr {
+					t.Errorf("got err: %v, want *scope.ParseError: %v", err, test.wantErr)
+				}
+			}
+			wantHits := 2
+			if test.wantErr {
+				wantHits = 0
+			}
+			if hits != wantHits {
+				t.Errorf("got %d requests, want %d", hits, wantHits)
+			}
+		})
+	}
+}
+
+func TestEventOptions_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		options EventOptions
+		want    string
+	}{
+		{
+			name:    "zero timestamp",
+			options: EventOptions{Name: "test"},
+			want:    `{"name":"test"}`,
+		},
+		{
+			name:    "timestamp",
+			options: EventOptions{Name: "test", Timestamp: UnixMilli(1)},
+			want:    `{"name":"test","timestamp":1}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := json.Marshal(test.options)
+			if err != nil {
+				t.Fatalf("json.Marshal returned error: %v", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("json.Marshal returned %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEventsService_Count(t *testing.T) {
+	methodName := "Count"
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		testFormValues(t, r, values{
+			"filter":        "deploy",
+			"limit":         "1",
+			"feed":          "true",
+			"include_pivot": "true",
+			"include_total": "true",
+		})
+		fmt.Fprint(w, `{"total":42,"matched":1,"events":[{"id":"1"}]}`)
+	})
+	got, _, err := client.Events.Count(context.Background(), ListEventOptions{Filter: "deploy", Limit: 100})
+	if err != nil {
+		t.Errorf("Events.Count returned error: %v", err)
+	}
+	if want := 42; got != want {
+		t.Errorf("Events.Count returned %d, want %d", got, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Events.Count(context.Background(), ListEventOptions{})
+		return resp, err
+	})
+}
+
+func TestEventsService_ListWithTotal(t *testing.T) {
+	methodName := "ListWithTotal"
+	tests := []struct {
+		name         string
+		options      ListEventOptions
+		wantRequests int
+		want         *ListEventsResponse
+	}{
+		{
+			name:         "known total",
+			options:      ListEventOptions{IncludeTotal: true},
+			wantRequests: 1,
+			want:         &ListEventsResponse{Total: 5, Matched: 1, Events: []Event{{ID: "1"}}},
+		},
+		{
+			name:         "unknown total",
+			options:      ListEventOptions{Limit: 10},
+			wantRequests: 2,
+			want:         &ListEventsResponse{Total: 5, Matched: 1, Events: []Event{{ID: "1"}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, mux, _, teardown := setup(nil)
+			defer teardown()
+			requests := 0
+			mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				requests++
+				if r.URL.Query().Get("include_total") != "true" {
+					fmt.Fprint(w, `{"total":0,"matched":1,"events":[{"id":"1"}]}`)
+					return
+				}
+				if requests > 1 {
+					testFormValues(t, r, values{
+						"limit":         "1",
+						"feed":          "true",
+						"include_pivot": "true",
+						"include_total": "true",
+					})
+				}
+				fmt.Fprint(w, `{"total":5,"matched":1,"events":[{"id":"1"}]}`)
+			})
+			got, resp, err := client.Events.ListWithTotal(context.Background(), test.options)
+			if err != nil {
+				t.Errorf("Events.ListWithTotal returned error: %v", err)
+			}
+			if resp.Request.URL.Query().Get("limit") == "1" {
+				t.Error("Events.ListWithTotal returned the response of the reconciling Count request, want the List response")
+			}
+			if requests != test.wantRequests {
+				t.Errorf("Events.ListWithTotal made %d requests, want %d", requests, test.wantRequests)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("Events.ListWithTotal returned %+v, want %+v", got, test.want)
+			}
+
+			testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+				got, resp, err := client.Events.ListWithTotal(context.Background(), test.options)
+				if got != nil {
+					t.Errorf("testNewRequestAndDoFailure %v = %#v, want nil", methodName, got)
+				}
+				return resp, err
+			})
+		})
+	}
+}
+
+func TestEventsService_List_FeedAndPivot(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name    string
+		options ListEventOptions
+		want    values
+	}{
+		{
+			name:    "default",
+			options: ListEventOptions{},
+			want: values{
+				"feed":          "true",
+				"include_pivot": "true",
+				"include_total": "false",
+			},
+		},
+		{
+			name:    "feed",
+			options: ListEventOptions{Feed: &enabled, IncludePivot: &enabled},
+			want: values{
+				"feed":          "true",
+				"include_pivot": "true",
+				"include_total": "false",
+			},
+		},
+		{
+			name:    "non-feed",
+			options: ListEventOptions{Feed: &disabled, IncludePivot: &disabled},
+			want: values{
+				"feed":          "false",
+				"include_pivot": "false",
+				"include_total": "false",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, mux, _, teardown := setup(nil)
+			defer teardown()
+			mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				testFormValues(t, r, test.want)
+				fmt.Fprint(w, `{"total":0,"matched":0,"events":[]}`)
+			})
+			if _, _, err := client.Events.List(context.Background(), test.options); err != nil {
+				t.Errorf("Events.List returned error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEventsService_List_Filter(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		if got, want := r.URL.RawQuery, "filter=disk+usage+%26+latency"; !strings.Contains(got, want) {
+			t.Errorf("query %q does not contain %q", got, want)
+		}
+		testFormValues(t, r, values{
+			"filter":        "disk usage & latency",
+			"feed":          "true",
+			"include_pivot": "true",
+			"include_total": "false",
+		})
+		fmt.Fprint(w, `{"total":0,"matched":0,"events":[]}`)
+	})
+	if _, _, err := client.Events.List(context.Background(), ListEventOptions{Filter: "disk usage & latency"}); err != nil {
+		t.Errorf("Events.List returned error: %v", err)
+	}
+}
+
+func TestEventsService_CountByLabel(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	from, to := NewMilliTime(time.Unix(100, 0)), NewMilliTime(time.Unix(200, 0))
+	pages := map[string]string{
+		"": `{"total":4,"matched":4,"events":[
+			{"id":"1","scopeLabels":{"kubernetes.namespace.name":"default"}},
+			{"id":"2","scopeLabels":{"kubernetes.namespace.name":"kube-system"}}]}`,
+		// Pages after the first are listed by pivot without the time window, so events outside it are not counted.
+		"2": `{"total":6,"matched":4,"events":[
+			{"id":"2","timestamp":160000,"scopeLabels":{"kubernetes.namespace.name":"kube-system"}},
+			{"id":"3","timestamp":150000,"scopeLabels":{"kubernetes.namespace.name":"default"}},
+			{"id":"4","timestamp":100000,"scopeLabels":{}},
+			{"id":"5","timestamp":99999,"scopeLabels":{"kubernetes.namespace.name":"default"}}]}`,
+	}
+	mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		want := values{
+			"category":      "ALERT",
+			"dir":           "before",
+			"feed":          "true",
+			"limit":         "2",
+			"include_pivot": "true",
+			"include_total": "true",
+		}
+		pivot := r.URL.Query().Get("pivot")
+		if pivot != "" {
+			want["pivot"] = pivot
+		} else {
+			want["from"] = "100000"
+			want["to"] = "200000"
+		}
+		testFormValues(t, r, want)
+		page, ok := pages[pivot]
+		if !ok {
+			t.Fatalf("unexpected pivot: %q", pivot)
+		}
+		fmt.Fprint(w, page)
+	})
+	got, _, err := client.Events.CountByLabel(context.Background(), from, to, "kubernetes.namespace.name",
+		ListEventOptions{Categories: Categories{CategoryAlert}, Limit: 2, Pivot: "ignored"})
+	if err != nil {
+		t.Fatalf("Events.CountByLabel returned error: %v", err)
+	}
+	want := map[string]int{"default": 2, "kube-system": 1, "": 1}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Events.CountByLabel returned %v, want %v", got, want)
+	}
+}
+
+func TestEventsService_SetStatus(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/v2/events/", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	})
+	defer teardown()
+
+	handlerFor := func(status Status) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, http.MethodPatch)
+			var v map[string]map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+				t.Errorf("failed to decode request: %v", err)
+			}
+			if got := v["event"]["status"]; got != string(status) {
+				t.Errorf("Request status = %q, want %q", got, status)
+			}
+			fmt.Fprint(w, `{"event":{"id":"1"}}`)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		status Status
+		f      func(ctx context.Context, eventID string) (*EventResponse, *http.Response, error)
+	}{
+		{
+			name:   "Acknowledge",
+			status: StatusAcknowledged,
+			f:      client.Events.Acknowledge,
+		},
+		{
+			name:   "Resolve",
+			status: StatusResolved,
+			f:      client.Events.Resolve,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h = handlerFor(test.status)
+			event, _, err := test.f(context.Background(), "1")
+			if err != nil {
+				t.Errorf("Events.%s returned error: %v", test.name, err)
+			}
+			want := &EventResponse{Event: Event{ID: "1"}}
+			if !cmp.Equal(event, want) {
+				t.Errorf("Events.%s returned %+v, want %+v", test.name, event, want)
+			}
+
+			testBadOptions(t, test.name, func() (err error) {
+				_, _, err = test.f(context.Background(), "")
+				return err
+			})
+			testBadOptions(t, test.name, func() (err error) {
+				_, _, err = test.f(context.Background(), "\n")
+				return err
+			})
+		})
+	}
+
+	testNewRequestAndDoFailure(t, "Acknowledge", client, func() (*http.Response, error) {
+		got, resp, err := client.Events.Acknowledge(context.Background(), "1")
+		if got != nil {
+			t.Errorf("testNewRequestAndDoFailure %v = %#v, want nil", "Acknowledge", got)
+		}
+		return resp, err
+	})
+}
+
+func TestEventsService_Update(t *testing.T) {
+	methodName := "Update"
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/v2/events/", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	})
+	defer teardown()
+
+	tests := []struct {
+		name     string
+		options  EventOptions
+		wantBody string
+	}{
+		{
+			name:     "name only",
+			options:  EventOptions{Name: "fixed name"},
+			wantBody: `{"event":{"name":"fixed name"}}` + "\n",
+		},
+		{
+			name: "all fields",
+			options: EventOptions{
+				Name:        "deploy",
+				Description: "v2",
+				Timestamp:   UnixMilli(1),
+				Severity:    SeverityHigh,
+				Scope:       `host.hostName = "foo"`,
+				Tags:        map[string]string{"team": "ops"},
+			},
+			wantBody: `{"event":{"name":"deploy","description":"v2","timestamp":1,"severity":"HIGH",` +
+				`"scope":"host.hostName = \"foo\"","tags":{"team":"ops"}}}` + "\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h = func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodPatch)
+				if got, want := r.URL.Path, "/api/v2/events/1"; got != want {
+					t.Errorf("got path: %s, want: %s", got, want)
+				}
+				testBody(t, r, test.wantBody)
+				fmt.Fprintf(w, `{"event":{"id":"1","name":%q}}`, test.options.Name)
+			}
+			got, _, err := client.Events.Update(context.Background(), "1", test.options)
+			if err != nil {
+				t.Errorf("Events.Update returned error: %v", err)
+			}
+			want := &EventResponse{Event: Event{ID: "1", Name: test.options.Name}}
+			if !cmp.Equal(got, want) {
+				t.Errorf("Events.Update returned %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	testBadOptions(t, methodName, func() (err error) {
+		_, _, err = client.Events.Update(context.Background(), "", EventOptions{Name: "test"})
+		return err
+	})
+	testBadOptions(t, methodName, func() (err error) {
+		_, _, err = client.Events.Update(context.Background(), "\n", EventOptions{Name: "test"})
+		return err
+	})
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Events.Update(context.Background(), "1", EventOptions{Name: "test"})
+		return resp, err
+	})
+}
+
+func TestSeverity_Label(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		want     SeverityLabel
+	}{
+		{SeverityUnknown, SeverityNone},
+		{SeverityEmergency, SeverityHigh},
+		{SeverityAlert, SeverityHigh},
+		{SeverityCritical, SeverityMedium},
+		{SeverityError, SeverityMedium},
+		{SeverityWarning, SeverityLow},
+		{SeverityNotice, SeverityLow},
+		{SeverityInformational, SeverityInfo},
+		{SeverityDebug, SeverityInfo},
+		{SeverityDebug + 1, SeverityNone},
+	}
+	for _, test := range tests {
+		if got := test.severity.Label(); got != test.want {
+			t.Errorf("Severity(%d).Label() = %q, want %q", test.severity, got, test.want)
+		}
+	}
+}
+
+func TestSeverityLabel_Severity(t *testing.T) {
+	tests := []struct {
+		label SeverityLabel
+		want  Severity
+	}{
+		{SeverityHigh, SeverityEmergency},
+		{SeverityMedium, SeverityCritical},
+		{SeverityLow, SeverityWarning},
+		{SeverityInfo, SeverityInformational},
+		{SeverityNone, SeverityUnknown},
+		{SeverityLabel("high"), SeverityUnknown},
+	}
+	for _, test := range tests {
+		got := test.label.Severity()
+		if got != test.want {
+			t.Errorf("SeverityLabel(%q).Severity() = %d, want %d", test.label, got, test.want)
+		}
+		if test.want != SeverityUnknown && got.Label() != test.label {
+			t.Errorf("SeverityLabel(%q) did not round-trip, got %q", test.label, got.Label())
+		}
+	}
+}
+
+func TestEventOptions_LinkCaptures(t *testing.T) {
+	options := EventOptions{Name: "test", Tags: map[string]string{"job": "backup"}}
+	options.LinkCaptures()
+	if _, ok := options.Tags[CaptureIDsTag]; ok {
+		t.Errorf("LinkCaptures without IDs set the %s tag", CaptureIDsTag)
+	}
+	options.LinkCaptures(1, 2)
+	options.LinkCaptures(3)
+
+	b, err := json.Marshal(options)
+	if err != nil {
+		t.Fatalf("unexpected json marshal error: %v", err)
+	}
+	if want := `"tags":{"captureIds":"1,2,3","job":"backup"}`; !strings.Contains(string(b), want) {
+		t.Errorf("marshaled EventOptions %s, want it to contain %s", b, want)
+	}
+	var event Event
+	if err := json.Unmarshal(b, &event); err != nil {
+		t.Fatalf("unexpected json unmarshal error: %v", err)
+	}
+	got, err := event.CaptureIDs()
+	if err != nil {
+		t.Fatalf("CaptureIDs returned error: %v", err)
+	}
+	if want := []int{1, 2, 3}; !cmp.Equal(got, want) {
+		t.Errorf("CaptureIDs returned %v, want %v", got, want)
+	}
+
+	if got, err := (Event{}).CaptureIDs(); err != nil || got != nil {
+		t.Errorf("CaptureIDs of an Event without captures returned %v, %v, want nil, nil", got, err)
+	}
+	if _, err := (Event{Tags: map[string]string{CaptureIDsTag: "1,x"}}).CaptureIDs(); err == nil {
+		t.Error("CaptureIDs with an invalid tag expected error")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // NotificationChannelsService is the Service for communicating with the Sysdig Monitor Notification Channel related API.
@@ -36,10 +37,10 @@ type NotificationChannel struct {
 	Enabled bool                       `json:"enabled"`
 	Options NotificationChannelOptions `json:"options"`
 
-	ID         string     `json:"id,omitempty"`
-	Version    int        `json:"version,omitempty"`
-	CreatedOn  *MilliTime `json:"createdOn,omitempty"`
-	ModifiedOn *MilliTime `json:"modifiedOn,omitempty"`
+	ID         string     `json:"id,omitempty" sysdig:"server-managed"`
+	Version    int        `json:"version,omitempty" sysdig:"server-managed"`
+	CreatedOn  *MilliTime `json:"createdOn,omitempty" sysdig:"server-managed"`
+	ModifiedOn *MilliTime `json:"modifiedOn,omitempty" sysdig:"server-managed"`
 }
 
 // NotificationChannelOptions describes the options for a NotificationChannel.
@@ -56,6 +57,58 @@ type NotificationChannelOptions struct {
 	Account         string   `json:"account"`
 	ServiceKey      string   `json:"serviceKey"`
 	ServiceName     string   `json:"serviceName"`
+	SNSTopicARNs    []string `json:"snsTopicARNs,omitempty"`
+}
+
+// NotificationChannelTypeInfo describes a NotificationChannelType for building configuration forms and validation.
+type NotificationChannelTypeInfo struct {
+	// Type is the NotificationChannelType.
+	Type NotificationChannelType
+	// DisplayName is a human readable name for the NotificationChannelType.
+	DisplayName string
+	// RequiredFields are the names of the NotificationChannelOptions fields required by the NotificationChannelType.
+	RequiredFields []string
+}
+
+// NotificationChannelTypes returns information about each known NotificationChannelType.
+func NotificationChannelTypes() []NotificationChannelTypeInfo {
+	return []NotificationChannelTypeInfo{
+		{
+			Type:           NotificationChannelTypeEmail,
+			DisplayName:    "Email",
+			RequiredFields: []string{"EmailRecipients"},
+		},
+		{
+			Type:           NotificationChannelTypeSNS,
+			DisplayName:    "Amazon SNS Topic",
+			RequiredFields: []string{"SNSTopicARNs"},
+		},
+		{
+			Type:           NotificationChannelTypePagerDuty,
+			DisplayName:    "PagerDuty",
+			RequiredFields: []string{"Account", "ServiceKey", "ServiceName"},
+		},
+		{
+			Type:           NotificationChannelTypeSlack,
+			DisplayName:    "Slack",
+			RequiredFields: []string{"URL"},
+		},
+		{
+			Type:           NotificationChannelTypeOpsGenie,
+			DisplayName:    "OpsGenie",
+			RequiredFields: []string{"APIKey"},
+		},
+		{
+			Type:           NotificationChannelTypeVictorOps,
+			DisplayName:    "VictorOps",
+			RequiredFields: []string{"APIKey", "RoutingKey"},
+		},
+		{
+			Type:           NotificationChannelTypeWebhook,
+			DisplayName:    "Webhook",
+			RequiredFields: []string{"URL"},
+		},
+	}
 }
 
 // NotificationChannelResponse describes the response for a NotificationChannel from the NotificationChannelsService API.
@@ -75,12 +128,14 @@ func (s *NotificationChannelsService) Get(ctx context.Context, id string) (*Noti
 	return c, resp, err
 }
 
-// ListNotificationChannelsResponse describes the response for NotificationChannelsService.List.
+// ListNotificationChannelsResponse describes the response for NotificationChannelsService.List and ListAll.
 type ListNotificationChannelsResponse struct {
 	NotificationChannels []NotificationChannel `json:"notificationChannels"`
 }
 
-// List lists all NotificationChannels.
+// List lists the NotificationChannels within the time window from and to. The API does not document how the window
+// applies; it appears to restrict the listing to the NotificationChannels which existed within it, so channels created
+// after to may not be returned. Use ListAll to list every NotificationChannel.
 func (s *NotificationChannelsService) List(
 	ctx context.Context,
 	from, to MilliTime) (*ListNotificationChannelsResponse, *http.Response, error) {
@@ -106,19 +161,87 @@ func (s *NotificationChannelsService) List(
 	return c, resp, err
 }
 
+// notificationChannelsListAllWindow is how far past now the time window of ListAll extends, so that NotificationChannels created while
+// listing or on hosts with clock skew are included.
+const notificationChannelsListAllWindow = 365 * 24 * time.Hour
+
+// ListAll lists every NotificationChannel by listing with a time window from the Unix epoch to a year from now.
+func (s *NotificationChannelsService) ListAll(ctx context.Context) (*ListNotificationChannelsResponse, *http.Response, error) {
+	return s.List(ctx, NewMilliTime(time.Unix(0, 0)), NewMilliTime(s.client.now().Add(notificationChannelsListAllWindow)))
+}
+
 // Create creates a new NotificationChannel.
 func (s *NotificationChannelsService) Create(
 	ctx context.Context,
 	t NotificationChannelType,
 	name string,
 	options NotificationChannelOptions) (*NotificationChannelResponse, *http.Response, error) {
-	u := "api/notificationChannels"
+	return s.create(ctx, t, name, options, "")
+}
+
+// CreateWithKey creates a new NotificationChannel like Create, sending key in the IdempotencyKeyHeader.
+func (s *NotificationChannelsService) CreateWithKey(
+	ctx context.Context,
+	t NotificationChannelType,
+	name string,
+	options NotificationChannelOptions,
+	key string) (*NotificationChannelResponse, *http.Response, error) {
+	if key == "" {
+		return nil, nil, fmt.Errorf("idempotency key cannot be blank")
+	}
+	return s.create(ctx, t, name, options, key)
+}
+
+// CreateBatch creates the NotificationChannels, e.g. one Slack channel per Team. The NotificationChannels API has no
+// batch endpoint, so each NotificationChannel is created with its own request. All NotificationChannels are attempted
+// even if some fail. The returned responses are in the same order as channels, with an empty response for each
+// NotificationChannel which failed to be created, and the *http.Response is that of the last request. If any
+// NotificationChannel failed to be created, a *BatchError reporting each failure by its index in channels is returned.
+// The channels are created as given, including Enabled, ignoring any server managed fields such as ID.
+func (s *NotificationChannelsService) CreateBatch(
+	ctx context.Context,
+	channels []NotificationChannel) ([]NotificationChannelResponse, *http.Response, error) {
+	responses := make([]NotificationChannelResponse, len(channels))
+	var resp *http.Response
+	batchErr := &BatchError{Total: len(channels)}
+	for i, channel := range channels {
+		stripServerManaged(&channel)
+		c, r, err := s.createChannel(ctx, channel, "")
+		if r != nil {
+			resp = r
+		}
+		if err != nil {
+			batchErr.Errors = append(batchErr.Errors, BatchItemError{Index: i, Err: err})
+			continue
+		}
+		responses[i] = *c
+	}
+	if len(batchErr.Errors) > 0 {
+		return responses, resp, batchErr
+	}
+	return responses, resp, nil
+}
+
+func (s *NotificationChannelsService) create(
+	ctx context.Context,
+	t NotificationChannelType,
+	name string,
+	options NotificationChannelOptions,
+	key string) (*NotificationChannelResponse, *http.Response, error) {
 	channel := NotificationChannel{
 		Type:    t,
 		Name:    name,
 		Enabled: true,
 		Options: options,
 	}
+	return s.createChannel(ctx, channel, key)
+}
+
+func (s *NotificationChannelsService) createChannel(
+	ctx context.Context,
+	channel NotificationChannel,
+	key string) (*NotificationChannelResponse, *http.Response, error) {
+	u := "api/notificationChannels"
 	type notificationChannelRequest struct {
 		NotificationChannel NotificationChannel `json:"notificationChannel"`
 	}
@@ -129,6 +252,7 @@ func (s *NotificationChannelsService) Create(
 	if err != nil {
 		return nil, nil, err
 	}
+	s.client.setIdempotencyKey(req, key)
 	c := new(NotificationChannelResponse)
 	resp, err := s.client.Do(ctx, req, c)
 	return c, resp, err
@@ -144,3 +268,17 @@ func (s *NotificationChannelsService) Delete(ctx context.Context, notificationCh
 	resp, err := s.client.Do(ctx, req, nil)
 	return resp, err
 }
+
+// Test sends a test notification to a NotificationChannel, as with "send test notification" in the Sysdig UI.
+func (s *NotificationChannelsService) Test(ctx context.Context, id string) (*http.Response, error) {
+	if id == "" {
+		return nil, fmt.Errorf("notification channel ID cannot be blank")
+	}
+	u := fmt.Sprintf("api/notificationChannels/%s/test", id)
+	req, err := s.client.NewRequest(http.MethodPost, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(ctx, req, nil)
+	return resp, err
+}
@@ -3,8 +3,11 @@ package sysdig
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"strconv"
 	"testing"
 	"time"
 
@@ -157,6 +160,44 @@ func TestNotificationChannelsService_List(t *testing.T) {
 	})
 }
 
+func TestNotificationChannelsService_ListAll(t *testing.T) {
+	methodName := "ListAll"
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+
+	mux.HandleFunc("/api/notificationChannels", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		if got := r.FormValue("from"); got != "0" {
+			t.Errorf("got from: %s, want: 0", got)
+		}
+		to, err := strconv.ParseInt(r.FormValue("to"), 10, 64)
+		if err != nil {
+			t.Errorf("failed to parse to: %v", err)
+		}
+		if to < time.Now().Add(364*24*time.Hour).UnixNano()/int64(time.Millisecond) {
+			t.Errorf("got to: %d, want at least a year from now", to)
+		}
+		fmt.Fprint(w, `{"notificationChannels":[{"id":"1"},{"id":"2"},{"id":"3"}]}`)
+	})
+
+	got, _, err := client.NotificationChannels.ListAll(context.Background())
+	if err != nil {
+		t.Fatalf("NotificationChannels.ListAll returned error: %v", err)
+	}
+	want := &ListNotificationChannelsResponse{NotificationChannels: []NotificationChannel{{ID: "1"}, {ID: "2"}, {ID: "3"}}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("NotificationChannels.ListAll returned %+v, want %+v", got, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		got, resp, ferr := client.NotificationChannels.ListAll(context.Background())
+		if got != nil {
+			t.Errorf("testNewRequestAndDoFailure %v = %#v, want nil", methodName, got)
+		}
+		return resp, ferr
+	})
+}
+
 func TestNotificationChannelsService_Delete(t *testing.T) {
 	methodName := "Delete"
 	client, mux, _, teardown := setup(nil)
@@ -247,3 +288,197 @@ func TestNotificationChannelsService_Get(t *testing.T) {
 		return err
 	})
 }
+
+func TestNotificationChannelTypes(t *testing.T) {
+	want := map[NotificationChannelType][]string{
+		NotificationChannelTypeEmail:     {"EmailRecipients"},
+		NotificationChannelTypeSNS:       {"SNSTopicARNs"},
+		NotificationChannelTypePagerDuty: {"Account", "ServiceKey", "ServiceName"},
+		NotificationChannelTypeSlack:     {"URL"},
+		NotificationChannelTypeOpsGenie:  {"APIKey"},
+		NotificationChannelTypeVictorOps: {"APIKey", "RoutingKey"},
+		NotificationChannelTypeWebhook:   {"URL"},
+	}
+	got := NotificationChannelTypes()
+	if len(got) != len(want) {
+		t.Errorf("got %d notification channel types, want %d", len(got), len(want))
+	}
+	optionsType := reflect.TypeOf(NotificationChannelOptions{})
+	for _, info := range got {
+		wantFields, ok := want[info.Type]
+		if !ok {
+			t.Errorf("unexpected notification channel type: %s", info.Type)
+			continue
+		}
+		if info.DisplayName == "" {
+			t.Errorf("%s has no display name", info.Type)
+		}
+		if !cmp.Equal(info.RequiredFields, wantFields) {
+			t.Errorf("%s required fields = %v, want %v", info.Type, info.RequiredFields, wantFields)
+		}
+		for _, field := range info.RequiredFields {
+			if _, ok := optionsType.FieldByName(field); !ok {
+				t.Errorf("%s required field %s is not a NotificationChannelOptions field", info.Type, field)
+			}
+		}
+	}
+}
+
+func TestNotificationChannelsService_Test(t *testing.T) {
+	methodName := "Test"
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/notificationChannels/", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	})
+	defer teardown()
+
+	tests := []struct {
+		name    string
+		id      string
+		handler http.HandlerFunc
+		wantErr bool
+	}{
+		{
+			name: "test",
+			id:   "1",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodPost)
+				if got, want := r.URL.Path, "/api/notificationChannels/1/test"; got != want {
+					t.Errorf("got path: %s, want: %s", got, want)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			},
+		},
+		{
+			name: "delivery failure",
+			id:   "1",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+				fmt.Fprint(w, `{"message":"failed to send test notification"}`)
+			},
+			wantErr: true,
+		},
+		{
+			name: "blank ID",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				t.Error("unexpected request for blank ID")
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		h = test.handler
+		t.Run(test.name, func(t *testing.T) {
+			_, err := client.NotificationChannels.Test(context.Background(), test.id)
+			if (err != nil) != test.wantErr {
+				t.Errorf("NotificationChannels.Test returned error: %v, want error: %v", err, test.wantErr)
+			}
+		})
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		return client.NotificationChannels.Test(context.Background(), "1")
+	})
+}
+
+func TestNotificationChannelsService_CreateBatch(t *testing.T) {
+	methodName := "CreateBatch"
+	channels := []NotificationChannel{
+		{Type: NotificationChannelTypeSlack, Name: "team-a", Enabled: true, Options: NotificationChannelOptions{Channel: "#a"}},
+		{Type: NotificationChannelTypeSlack, Name: "team-b", Enabled: true, Options: NotificationChannelOptions{Channel: "#b"}},
+		{Type: NotificationChannelTypeSlack, Name: "team-c", Options: NotificationChannelOptions{Channel: "#c"}, ID: "stale"},
+	}
+	tests := []struct {
+		name      string
+		fail      map[string]bool
+		want      []NotificationChannelResponse
+		wantFails []int
+	}{
+		{
+			name: "success",
+			want: []NotificationChannelResponse{
+				{NotificationChannel: NotificationChannel{ID: "team-a", Name: "team-a"}},
+				{NotificationChannel: NotificationChannel{ID: "team-b", Name: "team-b"}},
+				{NotificationChannel: NotificationChannel{ID: "team-c", Name: "team-c"}},
+			},
+		},
+		{
+			name: "partial failure",
+			fail: map[string]bool{"team-a": true, "team-c": true},
+			want: []NotificationChannelResponse{
+				{},
+				{NotificationChannel: NotificationChannel{ID: "team-b", Name: "team-b"}},
+				{},
+			},
+			wantFails: []int{0, 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, mux, _, teardown := setup(nil)
+			defer teardown()
+			var requests int
+			mux.HandleFunc("/api/notificationChannels", func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodPost)
+				var v struct {
+					NotificationChannel NotificationChannel `json:"notificationChannel"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+					t.Errorf("failed to decode request body: %v", err)
+					return
+				}
+				if want := channels[requests]; v.NotificationChannel.Name != want.Name ||
+					v.NotificationChannel.Enabled != want.Enabled || v.NotificationChannel.ID != "" {
+					t.Errorf("Request body = %+v, want %+v without server managed fields", v.NotificationChannel, want)
+				}
+				requests++
+				name := v.NotificationChannel.Name
+				if test.fail[name] {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, `{"message":"invalid channel"}`)
+					return
+				}
+				fmt.Fprintf(w, `{"notificationChannel":{"id":%q,"name":%q}}`, name, name)
+			})
+
+			got, _, err := client.NotificationChannels.CreateBatch(context.Background(), channels)
+			if requests != len(channels) {
+				t.Errorf("got %d requests, want %d", requests, len(channels))
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("NotificationChannels.CreateBatch returned %+v, want %+v", got, test.want)
+			}
+			if len(test.wantFails) == 0 {
+				if err != nil {
+					t.Errorf("NotificationChannels.CreateBatch returned error: %v", err)
+				}
+				return
+			}
+			var batchErr *BatchError
+			if !errors.As(err, &batchErr) {
+				t.Fatalf("got error: %v, want *BatchError", err)
+			}
+			if batchErr.Total != len(channels) {
+				t.Errorf("got total: %d, want: %d", batchErr.Total, len(channels))
+			}
+			var fails []int
+			for _, item := range batchErr.Errors {
+				fails = append(fails, item.Index)
+				if !hasStatusCode(item.Err, http.StatusBadRequest) {
+					t.Errorf("got item error: %v, want a 400 *ErrorResponse", item.Err)
+				}
+			}
+			if !cmp.Equal(fails, test.wantFails) {
+				t.Errorf("got failed indexes: %v, want: %v", fails, test.wantFails)
+			}
+		})
+	}
+
+	client, _, _, teardown := setup(nil)
+	defer teardown()
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.NotificationChannels.CreateBatch(context.Background(), channels[:1])
+		return resp, err
+	})
+}
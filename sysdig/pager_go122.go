@@ -0,0 +1,87 @@
+//go:build go1.22
+// +build go1.22
+
+package sysdig
+
+import (
+	"context"
+)
+
+// Pager walks the pages of a paginated List endpoint. Create one with EventsService.ListAll or
+// TeamsService.ListAllUsers.
+//
+// Pager is only available with Go 1.22 or later. The module declares go 1.16, so generics are only available to files
+// whose build constraint raises the language version, which is supported from Go 1.22.
+type Pager[T any] struct {
+	fetch func(ctx context.Context) (page []T, last bool, err error)
+	done  bool
+}
+
+// Next fetches the next page. It returns false once all pages have been returned, or if an error occurs.
+// If ctx is canceled, ctx.Err() is returned and the Pager may be resumed with a new context.
+func (p *Pager[T]) Next(ctx context.Context) ([]T, bool, error) {
+	if p.done {
+		return nil, false, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	page, last, err := p.fetch(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(page) == 0 {
+		p.done = true
+		return nil, false, nil
+	}
+	p.done = last
+	return page, true, nil
+}
+
+// All fetches all remaining pages and returns their items.
+func (p *Pager[T]) All(ctx context.Context) ([]T, error) {
+	var all []T
+	for {
+		page, ok, err := p.Next(ctx)
+		if err != nil {
+			return all, err
+		}
+		if !ok {
+			return all, nil
+		}
+		all = append(all, page...)
+	}
+}
+
+// ListAll returns a Pager over all Events matching options. Pages are fetched with options.Limit events per page,
+// using the last Event of each page as the pivot for the next, until ListEventsResponse.Total is reached.
+// A pivot cannot be combined with a time window, so the window of options applies to the first page and is enforced
+// client side for the following pages. options.Pivot is overridden and options.Direction defaults to DirectionBefore.
+func (s *EventsService) ListAll(options ListEventOptions) *Pager[Event] {
+	pages := newEventPages(options, s.client.now())
+	return &Pager[Event]{
+		fetch: func(ctx context.Context) ([]Event, bool, error) {
+			page, _, err := pages.next(ctx, s)
+			if err != nil {
+				return nil, false, err
+			}
+			return page, pages.done, nil
+		},
+	}
+}
+
+// ListAllUsers returns a Pager over all Users of the given Team, walking pages by offset until
+// ListUsersResponse.Total is reached.
+func (s *TeamsService) ListAllUsers(teamID int) *Pager[User] {
+	offset := 0
+	return &Pager[User]{
+		fetch: func(ctx context.Context) ([]User, bool, error) {
+			users, _, err := s.listUsers(ctx, teamID, offset)
+			if err != nil {
+				return nil, false, err
+			}
+			offset += len(users.Users)
+			return users.Users, offset >= users.Total, nil
+		},
+	}
+}
@@ -0,0 +1,106 @@
+//go:build go1.22
+// +build go1.22
+
+package sysdig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEventsService_ListAll(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	requests := 0
+	mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		requests++
+		q := r.URL.Query()
+		if q.Get("include_total") != "true" || q.Get("dir") != string(DirectionBefore) {
+			t.Errorf("unexpected query: %v", q)
+		}
+		switch q.Get("pivot") {
+		case "":
+			fmt.Fprint(w, `{"total":3,"matched":2,"events":[{"id":"3"},{"id":"2"}]}`)
+		case "2":
+			fmt.Fprint(w, `{"total":3,"matched":2,"events":[{"id":"2"},{"id":"1"}]}`)
+		default:
+			t.Errorf("unexpected pivot: %q", q.Get("pivot"))
+		}
+	})
+	pager := client.Events.ListAll(ListEventOptions{Limit: 2})
+	var pages [][]Event
+	for {
+		page, ok, err := pager.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Pager.Next returned error: %v", err)
+		}
+		if !ok {
+			break
+		}
+		pages = append(pages, page)
+	}
+	want := [][]Event{{{ID: "3"}, {ID: "2"}}, {{ID: "1"}}}
+	if !cmp.Equal(pages, want) {
+		t.Errorf("Events.ListAll returned %+v, want %+v", pages, want)
+	}
+	if requests != 2 {
+		t.Errorf("Events.ListAll made %d requests, want 2", requests)
+	}
+}
+
+func TestTeamsService_ListAllUsers(t *testing.T) {
+	teamID := 1
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	mux.HandleFunc(fmt.Sprintf("/api/team/%d/users", teamID), func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		switch r.URL.Query().Get("offset") {
+		case "":
+			fmt.Fprint(w, `{"total":3,"offset":0,"users":[{"id":1},{"id":2}]}`)
+		case "2":
+			fmt.Fprint(w, `{"total":3,"offset":2,"users":[{"id":3}]}`)
+		default:
+			t.Errorf("unexpected offset: %q", r.URL.Query().Get("offset"))
+		}
+	})
+	got, err := client.Teams.ListAllUsers(teamID).All(context.Background())
+	if err != nil {
+		t.Fatalf("Pager.All returned error: %v", err)
+	}
+	want := []User{{ID: 1}, {ID: 2}, {ID: 3}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Teams.ListAllUsers returned %+v, want %+v", got, want)
+	}
+}
+
+func TestPager_ContextCanceled(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	mux.HandleFunc("/api/team/1/users", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request made with a canceled context")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, ok, err := client.Teams.ListAllUsers(1).Next(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Pager.Next returned error: %v, want %v", err, context.Canceled)
+	}
+	if ok {
+		t.Error("Pager.Next returned ok for a canceled context")
+	}
+}
+
+func TestPager_Error(t *testing.T) {
+	client, _, _, teardown := setup(nil)
+	defer teardown()
+	client.BaseURL.Path = ""
+	if _, err := client.Teams.ListAllUsers(1).All(context.Background()); err == nil {
+		t.Error("Pager.All returned nil error, want error")
+	}
+}
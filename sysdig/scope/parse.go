@@ -0,0 +1,146 @@
+package scope
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError describes a malformed Sysdig scope string.
+type ParseError struct {
+	// Scope is the full scope string being parsed.
+	Scope string
+	// Clause is the clause of the scope string which failed to parse.
+	Clause string
+	// Reason describes why the clause failed to parse.
+	Reason string
+}
+
+// Error implements the error interface for ParseError.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid scope %q: clause %q: %s", e.Scope, e.Clause, e.Reason)
+}
+
+// Parse parses a Sysdig scope string, as produced by Scope.String, into a Scope.
+// Clauses must be joined with "and" and use one of the supported Selector operators.
+// A *ParseError is returned when the scope string is malformed.
+func Parse(s string) (*Scope, error) {
+	parsed := New()
+	if strings.TrimSpace(s) == "" {
+		return parsed, nil
+	}
+	for _, clause := range splitOutsideQuotes(s, " and ") {
+		selection, reason := parseSelection(clause)
+		if reason != "" {
+			return nil, &ParseError{Scope: s, Clause: strings.TrimSpace(clause), Reason: reason}
+		}
+		parsed.selections = append(parsed.selections, selection)
+	}
+	return parsed, nil
+}
+
+// parseSelection parses a single scope clause. A non-empty reason is returned if the clause is malformed.
+func parseSelection(clause string) (selection scopeSelection, reason string) {
+	rest := strings.TrimSpace(clause)
+	if rest == "" {
+		return selection, "empty clause"
+	}
+	negated := false
+	if strings.HasPrefix(rest, "not ") {
+		negated = true
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, "not "))
+	}
+	i := strings.IndexAny(rest, " =!")
+	if i <= 0 {
+		return selection, "missing label or operator"
+	}
+	selection.label = rest[:i]
+	if strings.ContainsAny(selection.label, `'"(),`) {
+		return selection, fmt.Sprintf("invalid label %q", selection.label)
+	}
+	rest = strings.TrimSpace(rest[i:])
+	switch {
+	case strings.HasPrefix(rest, string(SelectionIsNot)):
+		selection.selector = SelectionIsNot
+	case strings.HasPrefix(rest, string(SelectionIs)):
+		selection.selector = SelectionIs
+	case strings.HasPrefix(rest, string(SelectionIn)+" "), strings.HasPrefix(rest, string(SelectionIn)+"("):
+		selection.selector = SelectionIn
+	case strings.HasPrefix(rest, string(SelectionContains)+" "):
+		selection.selector = SelectionContains
+	case strings.HasPrefix(rest, string(SelectionStartsWith)+" "):
+		selection.selector = SelectionStartsWith
+	default:
+		return selection, "unknown operator"
+	}
+	rest = strings.TrimSpace(rest[len(selection.selector):])
+
+	if selection.selector == SelectionIn {
+		if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+			return selection, "in values must be wrapped in parentheses"
+		}
+		for _, v := range splitOutsideQuotes(rest[1:len(rest)-1], ",") {
+			value, ok := unquote(v)
+			if !ok {
+				return selection, fmt.Sprintf("value %s must be quoted", strings.TrimSpace(v))
+			}
+			selection.values = append(selection.values, value)
+		}
+	} else {
+		value, ok := unquote(rest)
+		if !ok {
+			return selection, fmt.Sprintf("value %s must be quoted", rest)
+		}
+		selection.values = []string{value}
+	}
+
+	if negated {
+		switch selection.selector {
+		case SelectionContains:
+			selection.selector = SelectionDoesNotContain
+		case SelectionIn:
+			selection.selector = SelectionNotIn
+		default:
+			return selection, fmt.Sprintf("operator %q cannot be negated", selection.selector)
+		}
+	}
+	return selection, ""
+}
+
+// unquote strips matching single or double quotes from a value.
+func unquote(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return "", false
+	}
+	q := s[0]
+	if (q != '\'' && q != '"') || s[len(s)-1] != q {
+		return "", false
+	}
+	value := s[1 : len(s)-1]
+	if strings.IndexByte(value, q) >= 0 {
+		return "", false
+	}
+	return value, true
+}
+
+// splitOutsideQuotes splits s around each instance of sep which does not appear within a quoted value.
+func splitOutsideQuotes(s, sep string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,124 @@
+package scope
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "Empty",
+			in:   "",
+			want: "",
+		},
+		{
+			name: "Is",
+			in:   `foo = 'bar'`,
+			want: `foo = 'bar'`,
+		},
+		{
+			name: "IsNoSpaces",
+			in:   `foo='bar'`,
+			want: `foo = 'bar'`,
+		},
+		{
+			name: "IsNot",
+			in:   `foo != "bar"`,
+			want: `foo != 'bar'`,
+		},
+		{
+			name: "Contains",
+			in:   `foo contains 'bar'`,
+			want: `foo contains 'bar'`,
+		},
+		{
+			name: "DoesNotContain",
+			in:   `not foo contains 'bar'`,
+			want: `not foo contains 'bar'`,
+		},
+		{
+			name: "StartsWith",
+			in:   `foo starts with 'bar'`,
+			want: `foo starts with 'bar'`,
+		},
+		{
+			name: "InMultiple",
+			in:   `foo in ('bar', 'baz')`,
+			want: `foo in ('bar', 'baz')`,
+		},
+		{
+			name: "NotIn",
+			in:   `not foo in ('bar')`,
+			want: `not foo in ('bar')`,
+		},
+		{
+			name: "Multiple",
+			in:   `kubernetes.namespace.name = 'a and b' and host.hostName starts with 'ip-'`,
+			want: `kubernetes.namespace.name = 'a and b' and host.hostName starts with 'ip-'`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse(test.in)
+			if err != nil {
+				t.Fatalf("Parse returned error: %v", err)
+			}
+			if got.String() != test.want {
+				t.Errorf("got: %q, want: %q", got.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{
+			name: "MissingOperator",
+			in:   `foo`,
+		},
+		{
+			name: "UnknownOperator",
+			in:   `foo like 'bar'`,
+		},
+		{
+			name: "Unquoted",
+			in:   `foo = bar`,
+		},
+		{
+			name: "UnterminatedQuote",
+			in:   `foo = 'bar`,
+		},
+		{
+			name: "InWithoutParentheses",
+			in:   `foo in 'bar'`,
+		},
+		{
+			name: "NegatedIs",
+			in:   `not foo = 'bar'`,
+		},
+		{
+			name: "DanglingAnd",
+			in:   `foo = 'bar' and `,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.in)
+			var parseError *ParseError
+			if !errors.As(err, &parseError) {
+				t.Fatalf("Parse returned error: %v, want *ParseError", err)
+			}
+			if parseError.Scope != test.in {
+				t.Errorf("ParseError.Scope = %q, want %q", parseError.Scope, test.in)
+			}
+		})
+	}
+}
@@ -139,6 +139,34 @@ func (s *Scope) AddStartsWithSelection(label, value string) *Scope {
 	return s.AddSelection(SelectionStartsWith, label, value)
 }
 
+// Selection is a single filter of a Scope.
+type Selection struct {
+	Label    string
+	Selector Selector
+	Values   []string
+}
+
+// Selections returns the filters which make up the Scope.
+func (s *Scope) Selections() []Selection {
+	if s == nil {
+		return nil
+	}
+	selections := make([]Selection, 0, len(s.selections))
+	for _, selection := range s.selections {
+		selections = append(selections, Selection{
+			Label:    selection.label,
+			Selector: selection.selector,
+			Values:   append([]string(nil), selection.values...),
+		})
+	}
+	return selections
+}
+
+// String converts the Selection to the Sysdig format for a single Scope filter.
+func (s Selection) String() string {
+	return scopeSelection{label: s.Label, values: s.Values, selector: s.Selector}.String()
+}
+
 // String defines fmt.Stringer for Scope. It converts it to the Sysdig format for Scope strings.
 func (s *Scope) String() string {
 	if s == nil {
@@ -149,35 +177,39 @@ func (s *Scope) String() string {
 		if i > 0 {
 			b.WriteString(" and ")
 		}
-		var prefix string
-		selector := selection.selector
-		// First set the "not" prefix in front of the Scope filter and reverse the selection to parse correctly.
-		switch selection.selector {
-		case SelectionDoesNotContain:
-			prefix = "not "
-			selector = SelectionContains
-		case SelectionNotIn:
-			prefix = "not "
-			selector = SelectionIn
-		}
+		b.WriteString(selection.String())
+	}
+	return b.String()
+}
 
-		// If someone misuses the client and sets multiple values for a Selection that isn't SelectionIn or SelectionNotIn,
-		// then it'll join them together and still work. Would probably be better to validate and fail though.
-		var joined strings.Builder
-		for c, v := range selection.values {
-			if c > 0 {
-				joined.WriteString(", ")
-			}
-			joined.WriteRune('\'')
-			joined.WriteString(v)
-			joined.WriteRune('\'')
-		}
-		switch selection.selector {
-		case SelectionIn, SelectionNotIn:
-			b.WriteString(fmt.Sprintf(`%s%s %s (%s)`, prefix, selection.label, selector, joined.String()))
-		default:
-			b.WriteString(fmt.Sprintf(`%s%s %s %s`, prefix, selection.label, selector, joined.String()))
+func (selection scopeSelection) String() string {
+	var prefix string
+	selector := selection.selector
+	// First set the "not" prefix in front of the Scope filter and reverse the selection to parse correctly.
+	switch selection.selector {
+	case SelectionDoesNotContain:
+		prefix = "not "
+		selector = SelectionContains
+	case SelectionNotIn:
+		prefix = "not "
+		selector = SelectionIn
+	}
+
+	// If someone misuses the client and sets multiple values for a Selection that isn't SelectionIn or SelectionNotIn,
+	// then it'll join them together and still work. Would probably be better to validate and fail though.
+	var joined strings.Builder
+	for c, v := range selection.values {
+		if c > 0 {
+			joined.WriteString(", ")
 		}
+		joined.WriteRune('\'')
+		joined.WriteString(v)
+		joined.WriteRune('\'')
+	}
+	switch selection.selector {
+	case SelectionIn, SelectionNotIn:
+		return fmt.Sprintf(`%s%s %s (%s)`, prefix, selection.label, selector, joined.String())
+	default:
+		return fmt.Sprintf(`%s%s %s %s`, prefix, selection.label, selector, joined.String())
 	}
-	return b.String()
 }
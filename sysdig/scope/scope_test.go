@@ -117,3 +117,25 @@ func TestEventScopeString(t *testing.T) {
 		})
 	}
 }
+
+func TestScopeSelections(t *testing.T) {
+	s := New().AddIsSelection("foo", "bar").AddNotInSelection("baz", "a", "b")
+	got := s.Selections()
+	if len(got) != 2 {
+		t.Fatalf("got %d selections, want 2", len(got))
+	}
+	if got[0].Label != "foo" || got[0].Selector != SelectionIs || got[0].String() != `foo = 'bar'` {
+		t.Errorf("got selection: %+v", got[0])
+	}
+	if got[1].Label != "baz" || got[1].Selector != SelectionNotIn || got[1].String() != `not baz in ('a', 'b')` {
+		t.Errorf("got selection: %+v", got[1])
+	}
+	got[1].Values[0] = "changed"
+	if s.String() != `foo = 'bar' and not baz in ('a', 'b')` {
+		t.Errorf("modifying Selections changed the Scope: %s", s.String())
+	}
+	var nilScope *Scope
+	if nilScope.Selections() != nil {
+		t.Error("nil Scope returned non-nil Selections")
+	}
+}
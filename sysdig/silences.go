@@ -0,0 +1,106 @@
+package sysdig
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// SilenceService is the Service for communicating with the Sysdig Monitor silencing rule related API.
+// A silencing rule mutes the notifications of its alerts for a window of time.
+// See: https://docs.sysdig.com/en/docs/sysdig-monitor/alerts/silence-alert-notifications/
+type SilenceService service
+
+// SilencingRule describes a Sysdig silencing rule.
+type SilencingRule struct {
+	ID                     int       `json:"id,omitempty"`
+	Version                int       `json:"version,omitempty"`
+	Name                   string    `json:"name"`
+	Enabled                bool      `json:"enabled"`
+	StartTS                MilliTime `json:"startTs"`
+	DurationInSec          int       `json:"durationInSec"`
+	Scope                  string    `json:"scope,omitempty"`
+	AlertIDs               []int     `json:"alertIds,omitempty"`
+	NotificationChannelIDs []int     `json:"notificationChannelIds,omitempty"`
+}
+
+// Until returns the time the SilencingRule ends.
+func (r SilencingRule) Until() MilliTime {
+	return NewMilliTime(r.StartTS.Add(time.Duration(r.DurationInSec) * time.Second))
+}
+
+// ListSilencingRulesResponse is a container for SilencingRules returned by the SilenceService.List API.
+type ListSilencingRulesResponse struct {
+	SilencingRules []SilencingRule `json:"silencingRules"`
+}
+
+// List lists all SilencingRules.
+func (s *SilenceService) List(ctx context.Context) (*ListSilencingRulesResponse, *http.Response, error) {
+	u := "api/v1/silencingRules"
+	req, err := s.client.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The API responds with a bare array of SilencingRules.
+	c := new(ListSilencingRulesResponse)
+	resp, err := s.client.Do(ctx, req, &c.SilencingRules)
+	return c, resp, err
+}
+
+// Create creates a SilencingRule.
+func (s *SilenceService) Create(ctx context.Context, rule SilencingRule) (*SilencingRule, *http.Response, error) {
+	u := "api/v1/silencingRules"
+	req, err := s.client.NewRequest(http.MethodPost, u, rule)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := new(SilencingRule)
+	resp, err := s.client.Do(ctx, req, c)
+	return c, resp, err
+}
+
+// Delete deletes a SilencingRule.
+func (s *SilenceService) Delete(ctx context.Context, ruleID int) (*http.Response, error) {
+	u := fmt.Sprintf("api/v1/silencingRules/%d", ruleID)
+	req, err := s.client.NewRequest(http.MethodDelete, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(ctx, req, nil)
+	return resp, err
+}
+
+// Silence silences the notifications of an Alert from now until the given time by creating a SilencingRule for it.
+func (s *SilenceService) Silence(ctx context.Context, alertID int, until time.Time) (*SilencingRule, *http.Response, error) {
+	now := NewMilliTime(s.client.now())
+	if !until.After(now.Time) {
+		return nil, nil, fmt.Errorf("silence end %s must be in the future", NewMilliTime(until))
+	}
+	return s.Create(ctx, SilencingRule{
+		Name:          fmt.Sprintf("Silence alert %d", alertID),
+		Enabled:       true,
+		StartTS:       now,
+		DurationInSec: int(math.Ceil(until.Sub(now.Time).Seconds())),
+		AlertIDs:      []int{alertID},
+	})
+}
+
+// Unsilence removes the silences of an Alert by deleting the SilencingRules which only silence that Alert, such as
+// those created by Silence. SilencingRules which also silence other Alerts are left unchanged.
+func (s *SilenceService) Unsilence(ctx context.Context, alertID int) (*http.Response, error) {
+	rules, resp, err := s.List(ctx)
+	if err != nil {
+		return resp, err
+	}
+	for _, rule := range rules.SilencingRules {
+		if len(rule.AlertIDs) != 1 || rule.AlertIDs[0] != alertID {
+			continue
+		}
+		if resp, err = s.Delete(ctx, rule.ID); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,120 @@
+package sysdig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSilenceService_Silence(t *testing.T) {
+	methodName := "Silence"
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+
+	until := time.Now().Add(time.Hour)
+	mux.HandleFunc("/api/v1/silencingRules", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		var rule SilencingRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+			return
+		}
+		if !rule.Enabled || !cmp.Equal(rule.AlertIDs, []int{1}) {
+			t.Errorf("got rule: %+v, want enabled rule for alert 1", rule)
+		}
+		if end := rule.Until(); end.Before(until.Truncate(time.Millisecond)) || end.Sub(until) > time.Second {
+			t.Errorf("got rule until: %v, want: %v", end, NewMilliTime(until))
+		}
+		rule.ID = 2
+		if err := json.NewEncoder(w).Encode(rule); err != nil {
+			t.Error(err)
+		}
+	})
+
+	got, _, err := client.Silences.Silence(context.Background(), 1, until)
+	if err != nil {
+		t.Fatalf("Silences.Silence returned error: %v", err)
+	}
+	if got.ID != 2 || !cmp.Equal(got.AlertIDs, []int{1}) {
+		t.Errorf("Silences.Silence returned %+v, want rule 2 for alert 1", got)
+	}
+
+	if _, _, err := client.Silences.Silence(context.Background(), 1, time.Now().Add(-time.Minute)); err == nil {
+		t.Error("Silences.Silence with an end in the past expected error")
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Silences.Silence(context.Background(), 1, until)
+		return resp, err
+	})
+}
+
+func TestSilenceService_List(t *testing.T) {
+	methodName := "List"
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/silencingRules", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `[{"id":1,"alertIds":[1]},{"id":2,"alertIds":[1,2]}]`)
+	})
+
+	got, _, err := client.Silences.List(context.Background())
+	if err != nil {
+		t.Fatalf("Silences.List returned error: %v", err)
+	}
+	want := &ListSilencingRulesResponse{SilencingRules: []SilencingRule{{ID: 1, AlertIDs: []int{1}}, {ID: 2, AlertIDs: []int{1, 2}}}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Silences.List returned %+v, want %+v", got, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		got, resp, err := client.Silences.List(context.Background())
+		if got != nil {
+			t.Errorf("testNewRequestAndDoFailure %v = %#v, want nil", methodName, got)
+		}
+		return resp, err
+	})
+}
+
+func TestSilenceService_Unsilence(t *testing.T) {
+	methodName := "Unsilence"
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/silencingRules", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `[{"id":1,"alertIds":[1]},{"id":2,"alertIds":[1,2]},{"id":3,"alertIds":[2]},{"id":4,"alertIds":[1]}]`)
+	})
+	var deleted []string
+	mux.HandleFunc("/api/v1/silencingRules/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodDelete)
+		deleted = append(deleted, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if _, err := client.Silences.Unsilence(context.Background(), 1); err != nil {
+		t.Fatalf("Silences.Unsilence returned error: %v", err)
+	}
+	want := []string{"/api/v1/silencingRules/1", "/api/v1/silencingRules/4"}
+	if !cmp.Equal(deleted, want) {
+		t.Errorf("Silences.Unsilence deleted %v, want %v", deleted, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		return client.Silences.Unsilence(context.Background(), 1)
+	})
+}
+
+func TestSilencingRule_Until(t *testing.T) {
+	start := time.Date(2022, time.March, 4, 5, 6, 7, 0, time.UTC)
+	rule := SilencingRule{StartTS: NewMilliTime(start), DurationInSec: 3600}
+	if got, want := rule.Until(), NewMilliTime(start.Add(time.Hour)); !got.Equal(want.Time) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build go1.21
+// +build go1.21
+
+package sysdig
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SlogLogger adapts a *slog.Logger to the Logger interface so structured loggers can be used with WithLogger.
+// Messages are formatted with fmt and emitted at Level.
+type SlogLogger struct {
+	Logger *slog.Logger
+	Level  slog.Level
+}
+
+// NewSlogLogger creates a SlogLogger which logs to the provided *slog.Logger at slog.LevelDebug.
+// If l is nil, slog.Default() is used.
+func NewSlogLogger(l *slog.Logger) *SlogLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogLogger{
+		Logger: l,
+		Level:  slog.LevelDebug,
+	}
+}
+
+// Print implements Logger for SlogLogger.
+func (l *SlogLogger) Print(args ...interface{}) {
+	l.Logger.Log(context.Background(), l.Level, fmt.Sprint(args...))
+}
+
+// Printf implements Logger for SlogLogger.
+func (l *SlogLogger) Printf(format string, args ...interface{}) {
+	l.Logger.Log(context.Background(), l.Level, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,50 @@
+//go:build go1.21
+// +build go1.21
+
+package sysdig
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	client, mux, baseURL, teardown := setup(nil)
+	defer teardown()
+	client.SetLogger(NewSlogLogger(slog.New(handler)))
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/foo", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err := client.BareDo(context.Background(), req)
+	if err != nil {
+		t.Fatalf("failed to baredo: %v", err)
+	}
+	defer resp.Body.Close()
+	got := buf.String()
+	if !strings.Contains(got, "level=DEBUG") {
+		t.Errorf("slog output missing debug level: %q", got)
+	}
+	if !strings.Contains(got, "-> request: GET") {
+		t.Errorf("slog output missing request debug message: %q", got)
+	}
+}
+
+func TestNewSlogLogger_Default(t *testing.T) {
+	l := NewSlogLogger(nil)
+	if l.Logger != slog.Default() {
+		t.Error("NewSlogLogger(nil) did not use slog.Default()")
+	}
+	if l.Level != slog.LevelDebug {
+		t.Errorf("NewSlogLogger level = %v, want %v", l.Level, slog.LevelDebug)
+	}
+}
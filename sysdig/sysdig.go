@@ -4,18 +4,27 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/url"
 	"path"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/trinchan/sysdig-go/sysdig/authentication"
+	"github.com/trinchan/sysdig-go/sysdig/scope"
 
 	"github.com/google/go-querystring/query"
 )
@@ -38,6 +47,20 @@ const (
 	ibmBaseURL     = "monitoring.cloud.ibm.com/"
 
 	userAgent = "sysdig-go"
+
+	// IdempotencyKeyHeader is the header used to send idempotency keys with create requests.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// DefaultDebugBodyLimit is the default maximum number of bytes of a request or response body logged in debug mode.
+	DefaultDebugBodyLimit = 4 << 20
+
+	// DefaultClassifiedRetries is the default maximum number of times a request is retried when the retry classifier
+	// set with WithRetryClassifier reports it as retryable.
+	DefaultClassifiedRetries = 3
+	// DefaultRetryBackoff is the default backoff before the first classified retry, doubled for each following retry.
+	DefaultRetryBackoff = 500 * time.Millisecond
+	// RetryClassifierBodyLimit is the maximum number of bytes of a response body buffered for the retry classifier.
+	RetryClassifierBodyLimit = 1 << 20
 )
 
 // Region is a type for defining available IBM regions for Sysdig.
@@ -77,9 +100,24 @@ type Client struct {
 
 	httpClient             *http.Client // HTTP client used to communicate with the API.
 	logger                 Logger
+	insecureSkipVerify     bool
 	debug                  bool
+	debugBodyLimit         int64
 	shouldCompressResponse bool
+	shouldCompressRequest  bool
 	authenticator          authentication.Authenticator
+	defaultEventCategories Categories
+	validateScopes         bool
+	defaultRequestTimeout  time.Duration
+	requestIDHeader        string
+	requestIDGenerator     func() string
+	idempotencyKeys        bool
+	authRetry              bool
+	strictDecoding         bool
+	retryClassifier        func(resp *http.Response, err error) bool
+	retryMax               int
+	retryBackoff           time.Duration
+	clock                  func() time.Time
 
 	common service // Reuse a single struct instead of allocating one for each service on the heap.
 
@@ -90,6 +128,8 @@ type Client struct {
 	Alerts               *AlertService
 	Dashboards           *DashboardService
 	Teams                *TeamsService
+	Captures             *CaptureService
+	Silences             *SilenceService
 
 	// PrometheusClient implements a Prometheus HTTP API Client using the Sysdig Client as a base.
 	// Delegates to the implementation in Prometheus' client library.
@@ -97,7 +137,11 @@ type Client struct {
 	// Known working:
 	// - Query
 	// - QueryRange
-	// - Alerts.
+	// - Alerts
+	// - LabelNames
+	// - LabelValues
+	// - Series
+	// - Metadata.
 	Prometheus v1.API
 }
 
@@ -107,18 +151,29 @@ type ClientOption func(*Client) error
 // NewClient creates a new Sysdig Client and applies all provided ClientOption.
 func NewClient(authenticator authentication.Authenticator, options ...ClientOption) (*Client, error) {
 	baseURL, _ := url.Parse(defaultBaseURL)
+	// Copy http.DefaultClient so options customizing the client do not modify the process-wide default.
+	httpClient := *http.DefaultClient
 	c := &Client{
 		BaseURL:       baseURL,
 		authenticator: authenticator,
 		UserAgent:     userAgent,
-		httpClient:    http.DefaultClient,
+		httpClient:    &httpClient,
 		logger:        noopLog,
+
+		debugBodyLimit: DefaultDebugBodyLimit,
+		authRetry:      true,
+		retryMax:       DefaultClassifiedRetries,
+		retryBackoff:   DefaultRetryBackoff,
+		clock:          time.Now,
 	}
 	for _, o := range options {
 		if err := o(c); err != nil {
 			return nil, err
 		}
 	}
+	if c.insecureSkipVerify {
+		c.warn("WARNING: TLS certificate verification is disabled, connections to Sysdig are insecure")
+	}
 	c.common.client = c
 	c.Events = (*EventsService)(&c.common)
 	c.Users = (*UsersService)(&c.common)
@@ -126,6 +181,8 @@ func NewClient(authenticator authentication.Authenticator, options ...ClientOpti
 	c.Alerts = (*AlertService)(&c.common)
 	c.Dashboards = (*DashboardService)(&c.common)
 	c.Teams = (*TeamsService)(&c.common)
+	c.Captures = (*CaptureService)(&c.common)
+	c.Silences = (*SilenceService)(&c.common)
 	c.Prometheus = v1.NewAPI(&prometheusClient{client: c})
 	return c, nil
 }
@@ -138,13 +195,111 @@ func WithHTTPClient(client *http.Client) ClientOption {
 	}
 }
 
-// WithBaseURL sets the Client.BaseURL to the provided URL. BaseURLs should have a trailing slash.
+// WithTimeout sets the timeout of the HTTP client used by the Sysdig client.
+// The HTTP client is copied, so a client passed to WithHTTPClient is not modified.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) error {
+		clientCopy := *c.httpClient
+		clientCopy.Timeout = timeout
+		c.httpClient = &clientCopy
+		return nil
+	}
+}
+
+// WithProxy sets the proxy used by the HTTP client used by the Sysdig client.
+// The HTTP client and its transport are copied, so a client passed to WithHTTPClient is not modified.
+// An error is returned if proxyURL is not an absolute URL or the HTTP client's transport is not an *http.Transport.
+func WithProxy(proxyURL string) ClientOption {
+	return func(c *Client) error {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid proxy URL %q: must be absolute", proxyURL)
+		}
+		return c.modifyTransport("proxy", func(transport *http.Transport) {
+			transport.Proxy = http.ProxyURL(u)
+		})
+	}
+}
+
+// WithInsecureSkipVerify sets whether the HTTP client used by the Sysdig client skips verification of the server's
+// TLS certificate, e.g. when testing an on-premise installation with a self-signed certificate. This makes connections
+// vulnerable to interception and should not be used in production. A warning is logged when it is enabled, to the
+// Logger set WithLogger or the standard logger otherwise.
+// The HTTP client and its transport are copied, so a client passed to WithHTTPClient is not modified.
+// An error is returned if the HTTP client's transport is not an *http.Transport.
+func WithInsecureSkipVerify(insecureSkipVerify bool) ClientOption {
+	return func(c *Client) error {
+		return c.modifyTransport("insecure skip verify", func(transport *http.Transport) {
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			transport.TLSClientConfig.InsecureSkipVerify = insecureSkipVerify
+			c.insecureSkipVerify = insecureSkipVerify
+		})
+	}
+}
+
+// WithTransport sets the transport of the HTTP client used by the Sysdig client, e.g. to layer tracing or metrics
+// middleware around http.DefaultTransport. Other settings of the HTTP client, such as its timeout, are preserved.
+// The HTTP client is copied, so a client passed to WithHTTPClient is not modified.
+// WithProxy and WithInsecureSkipVerify require an *http.Transport, so configure the wrapped transport instead when
+// using a custom RoundTripper.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) error {
+		if rt == nil {
+			return errors.New("transport cannot be nil")
+		}
+		clientCopy := *c.httpClient
+		clientCopy.Transport = rt
+		c.httpClient = &clientCopy
+		return nil
+	}
+}
+
+// modifyTransport copies the HTTP client and its transport, falling back to http.DefaultTransport, and applies modify
+// to the copied transport. setting names what is being modified for the error returned when the transport is not an
+// *http.Transport.
+func (c *Client) modifyTransport(setting string, modify func(*http.Transport)) error {
+	var transport *http.Transport
+	switch t := c.httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return fmt.Errorf("cannot set %s on transport of type %T", setting, t)
+	}
+	modify(transport)
+	clientCopy := *c.httpClient
+	clientCopy.Transport = transport
+	c.httpClient = &clientCopy
+	return nil
+}
+
+// warn logs a warning which must not go unnoticed, to the standard logger if the Client has no Logger.
+func (c *Client) warn(v ...interface{}) {
+	if c.logger == noopLog {
+		log.Print(v...)
+		return
+	}
+	c.logger.Print(v...)
+}
+
+// WithBaseURL sets the Client.BaseURL to the provided URL. A trailing slash is added to the path if missing, so API
+// paths resolve beneath a path prefix, e.g. https://sysdig.example.com/monitoring/sysdig/ for an installation behind
+// a reverse proxy.
 func WithBaseURL(baseURL string) ClientOption {
 	return func(c *Client) error {
 		url, err := url.Parse(baseURL)
 		if err != nil {
 			return err
 		}
+		if !strings.HasSuffix(url.Path, "/") {
+			url.Path += "/"
+		}
 		c.BaseURL = url
 		return nil
 	}
@@ -171,6 +326,23 @@ func WithUserAgent(userAgent string) ClientOption {
 	}
 }
 
+// WithUserAgentSuffix appends suffix to the User Agent sent to Sysdig, keeping the default sysdig-go identifier.
+// Options are applied in order: a suffix added after WithUserAgent is appended to the custom User Agent, while
+// WithUserAgent after WithUserAgentSuffix replaces the User Agent including the suffix.
+func WithUserAgentSuffix(suffix string) ClientOption {
+	return func(c *Client) error {
+		if suffix == "" {
+			return nil
+		}
+		if c.UserAgent == "" {
+			c.UserAgent = suffix
+			return nil
+		}
+		c.UserAgent += " " + suffix
+		return nil
+	}
+}
+
 // WithResponseCompression sets whether to set compression headers in requests to Sysdig.
 func WithResponseCompression(shouldCompressResponse bool) ClientOption {
 	return func(c *Client) error {
@@ -179,6 +351,15 @@ func WithResponseCompression(shouldCompressResponse bool) ClientOption {
 	}
 }
 
+// WithRequestCompression sets whether to gzip request bodies sent to Sysdig.
+// Only bodies larger than 1KiB are compressed.
+func WithRequestCompression(shouldCompressRequest bool) ClientOption {
+	return func(c *Client) error {
+		c.shouldCompressRequest = shouldCompressRequest
+		return nil
+	}
+}
+
 // WithLogger sets the default logger for the Client.
 func WithLogger(l Logger) ClientOption {
 	return func(c *Client) error {
@@ -195,6 +376,187 @@ func WithDebug(debug bool) ClientOption {
 	}
 }
 
+// WithDebugBodyLimit sets the maximum number of bytes of a request or response body logged in debug mode,
+// DefaultDebugBodyLimit by default. Only the logged part of a response body is held in memory for logging; the full
+// body is still returned. A limit of 0 logs bodies in full.
+func WithDebugBodyLimit(n int64) ClientOption {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("invalid debug body limit: %d, must not be negative", n)
+		}
+		c.debugBodyLimit = n
+		return nil
+	}
+}
+
+// WithDefaultEventCategories sets the Categories used by EventsService.List when ListEventOptions.Categories is empty.
+// If no categories are provided, DefaultEventCategories is used. Without this option, listing events with no
+// Categories returns events of all categories.
+func WithDefaultEventCategories(categories ...Category) ClientOption {
+	return func(c *Client) error {
+		if len(categories) == 0 {
+			categories = DefaultEventCategories
+		}
+		c.defaultEventCategories = append(Categories(nil), categories...)
+		return nil
+	}
+}
+
+// WithScopeValidation sets whether scope strings passed to the EventsService are validated with scope.Parse before
+// making a request. Disabled by default to avoid rejecting valid scopes the parser does not yet understand.
+func WithScopeValidation(validate bool) ClientOption {
+	return func(c *Client) error {
+		c.validateScopes = validate
+		return nil
+	}
+}
+
+// WithDefaultRequestTimeout sets a timeout for requests whose context has no deadline, so a hung server cannot block
+// a request forever when the http.Client has no timeout. Contexts with a deadline are unaffected. Disabled if d <= 0.
+func WithDefaultRequestTimeout(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		c.defaultRequestTimeout = d
+		return nil
+	}
+}
+
+// WithRequestIDHeader sets the header used to send a request ID with each request, to correlate requests with
+// Sysdig support. A new ID is generated by gen for each request, which defaults to a random UUID if gen is nil.
+// Request IDs are logged with each request in debug mode. Requests which already have the header are unchanged.
+func WithRequestIDHeader(header string, gen func() string) ClientOption {
+	return func(c *Client) error {
+		if header == "" {
+			return errors.New("request ID header cannot be blank")
+		}
+		if gen == nil {
+			gen = newRequestID
+		}
+		c.requestIDHeader = header
+		c.requestIDGenerator = gen
+		return nil
+	}
+}
+
+// WithIdempotencyKeys sets whether to send a random idempotency key in the IdempotencyKeyHeader with each create
+// request, such as EventsService.Create and DashboardService.Create. The key is set on the request, so it is reused
+// when the request is retried, allowing servers which support idempotency keys to deduplicate retried creates.
+// Use the CreateWithKey methods to provide the key for a single create instead.
+// Note: The Sysdig API does not document support for idempotency keys on any endpoint, so the header may be ignored
+// and duplicates may still be created.
+func WithIdempotencyKeys(idempotencyKeys bool) ClientOption {
+	return func(c *Client) error {
+		c.idempotencyKeys = idempotencyKeys
+		return nil
+	}
+}
+
+// WithAuthRetry sets whether a request rejected with a 401 or 403 is retried once after refreshing the credentials,
+// when the authentication.Authenticator is authentication.Refreshable. Enabled by default. Disable it to receive the
+// original authentication error, e.g. so genuine permission errors are not masked by a refresh.
+func WithAuthRetry(authRetry bool) ClientOption {
+	return func(c *Client) error {
+		c.authRetry = authRetry
+		return nil
+	}
+}
+
+// WithRetryClassifier sets a classifier which reports whether a request should be retried given its response and
+// error, e.g. to retry on specific Sysdig error reasons in the response body. The response is nil when the request
+// failed without a response. A request is retried as set WithClassifiedRetries, within any deadline of the request
+// context. Requests with a body which cannot be resent are not retried.
+//
+// Up to RetryClassifierBodyLimit bytes of each response body are buffered in memory so the classifier can read them,
+// and the classifier sees only that part of larger bodies. The full body remains readable by the caller.
+func WithRetryClassifier(classifier func(resp *http.Response, err error) bool) ClientOption {
+	return func(c *Client) error {
+		if classifier == nil {
+			return errors.New("retry classifier cannot be nil")
+		}
+		c.retryClassifier = classifier
+		return nil
+	}
+}
+
+// WithClassifiedRetries sets the maximum number of times a request is retried when the retry classifier set
+// WithRetryClassifier reports it as retryable, DefaultClassifiedRetries by default, and the backoff before the first
+// retry, DefaultRetryBackoff by default, which is doubled for each following retry.
+func WithClassifiedRetries(retries int, backoff time.Duration) ClientOption {
+	return func(c *Client) error {
+		if retries < 0 {
+			return fmt.Errorf("invalid retries: %d, must not be negative", retries)
+		}
+		if backoff < 0 {
+			return fmt.Errorf("invalid retry backoff: %s, must not be negative", backoff)
+		}
+		c.retryMax = retries
+		c.retryBackoff = backoff
+		return nil
+	}
+}
+
+// WithClock sets the clock the Client uses for the current time, time.Now by default, e.g. to make relative time
+// windows such as ListEventOptions.Within deterministic in tests.
+func WithClock(clock func() time.Time) ClientOption {
+	return func(c *Client) error {
+		if clock == nil {
+			return errors.New("clock cannot be nil")
+		}
+		c.clock = clock
+		return nil
+	}
+}
+
+// now returns the current time of the Client clock.
+func (c *Client) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock()
+}
+
+// WithStrictDecoding sets whether Do rejects response fields which are not part of the decoded struct, returning an
+// *UnknownFieldsError listing them, e.g. to find fields missing from the structs when reverse-engineering Sysdig
+// payloads. The response is still decoded into the provided value. Disabled by default, since the Sysdig API adds
+// fields without notice.
+func WithStrictDecoding(strictDecoding bool) ClientOption {
+	return func(c *Client) error {
+		c.strictDecoding = strictDecoding
+		return nil
+	}
+}
+
+// setIdempotencyKey sets key in the IdempotencyKeyHeader of req. If key is empty, a random key is set if the Client
+// was created WithIdempotencyKeys.
+func (c *Client) setIdempotencyKey(req *http.Request, key string) {
+	if key == "" && c.idempotencyKeys {
+		key = newRequestID()
+	}
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+}
+
+// newRequestID returns a random (version 4) UUID.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// teamIDContextKey is the context key for the Team ID set by WithTeamIDContext.
+type teamIDContextKey struct{}
+
+// WithTeamIDContext returns a copy of ctx which targets requests sent with it at the Sysdig Team with the given ID,
+// by setting the authentication.SysdigTeamIDHeader. The Team ID overrides the one of the Client's
+// authentication.Authenticator for those requests only, so a Client can be shared across Teams.
+func WithTeamIDContext(ctx context.Context, teamID string) context.Context {
+	return context.WithValue(ctx, teamIDContextKey{}, teamID)
+}
+
 // Client returns the http.Client used by this Sysdig client.
 func (c *Client) Client() *http.Client {
 	clientCopy := *c.httpClient
@@ -206,28 +568,113 @@ func (c *Client) SetLogger(l Logger) {
 	c.logger = l
 }
 
+// ErrAuthentication is matched with errors.Is by errors returned by Client.Ping when the Sysdig API rejects the
+// credentials of the Client.
+var ErrAuthentication = errors.New("authentication failed")
+
+// authenticationError wraps the *ErrorResponse of a rejected authentication so it matches ErrAuthentication.
+type authenticationError struct {
+	*ErrorResponse
+}
+
+func (e *authenticationError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrAuthentication, e.ErrorResponse)
+}
+
+func (e *authenticationError) Is(target error) bool {
+	return target == ErrAuthentication
+}
+
+func (e *authenticationError) Unwrap() error {
+	return e.ErrorResponse
+}
+
+// Ping checks that the Sysdig API is reachable and accepts the credentials of the Client with a cheap authenticated
+// request, for liveness checks. If the credentials are rejected, the returned error matches ErrAuthentication.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := c.NewRequest(http.MethodGet, "api/token", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(ctx, req, nil)
+	var errorResponse *ErrorResponse
+	if isAuthenticationError(resp) && errors.As(err, &errorResponse) {
+		return &authenticationError{errorResponse}
+	}
+	return err
+}
+
+// ErrServerVersionUnknown is returned by Client.ServerVersion when the backend does not report its version, as is the
+// case for Sysdig SaaS.
+var ErrServerVersionUnknown = errors.New("server version unknown")
+
+// ServerVersion returns the version of the Sysdig backend, as reported by TeamsService.Infrastructure.
+// Useful for gating features on the backend version of on-premise installations. Only on-premise installations report
+// a version, so ErrServerVersionUnknown is returned otherwise, e.g. for Sysdig SaaS.
+func (c *Client) ServerVersion(ctx context.Context) (string, error) {
+	infra, _, err := c.Teams.Infrastructure(ctx)
+	if err != nil {
+		return "", err
+	}
+	version := infra.Infrastructure.OnPremOverview.CustomerVersion
+	if version == "" {
+		return "", ErrServerVersionUnknown
+	}
+	return version, nil
+}
+
+// Raw sends a request to an arbitrary Sysdig API endpoint which is not wrapped by a Service, e.g. api/policies.
+// path is resolved relative to BaseURL like NewRequest, body, if not nil, is JSON encoded as the request body and the
+// response is decoded into out like Do.
+func (c *Client) Raw(ctx context.Context, method, path string, body, out interface{}) (*http.Response, error) {
+	req, err := c.NewRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(ctx, req, out)
+}
+
+// validateScope validates the scope string with scope.Parse if scope validation is enabled.
+func (c *Client) validateScope(s string) error {
+	if !c.validateScopes || s == "" {
+		return nil
+	}
+	_, err := scope.Parse(s)
+	return err
+}
+
 // NewRequest creates an API request. A relative URL can be provided in urlStr,
 // in which case it is resolved relative to the BaseURL of the Client.
-// Relative URLs should always be specified without a preceding slash. If
-// specified, the value pointed to by body is JSON encoded and included as the
-// request body.
+// A preceding slash in urlStr is ignored, so the path of the BaseURL is kept,
+// and the BaseURL must have a trailing slash. If specified, the value pointed
+// to by body is JSON encoded and included as the request body.
 func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Request, error) {
 	if !strings.HasSuffix(c.BaseURL.Path, "/") {
-		return nil, fmt.Errorf("BaseURL must have a trailing slash, but %q does not", c.BaseURL)
+		return nil, fmt.Errorf("BaseURL must have a trailing slash so paths are resolved beneath it, but %q does not", c.BaseURL)
 	}
-	u, err := c.BaseURL.Parse(urlStr)
+	// Resolve paths with a leading slash beneath the BaseURL path rather than the host root.
+	u, err := c.BaseURL.Parse(strings.TrimLeft(urlStr, "/"))
 	if err != nil {
 		return nil, err
 	}
 	var buf io.ReadWriter
+	compressed := false
 	if body != nil {
-		buf = &bytes.Buffer{}
-		enc := json.NewEncoder(buf)
+		b := &bytes.Buffer{}
+		enc := json.NewEncoder(b)
 		enc.SetEscapeHTML(false)
 		eerr := enc.Encode(body)
 		if eerr != nil {
 			return nil, eerr
 		}
+		if c.shouldCompressRequest && b.Len() > requestCompressionThreshold {
+			b, eerr = gzipBody(b)
+			if eerr != nil {
+				return nil, eerr
+			}
+			compressed = true
+		}
+		buf = b
 	}
 	req, err := http.NewRequest(method, u.String(), buf)
 	if err != nil {
@@ -236,6 +683,9 @@ func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Requ
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	req.Header.Set("Accept", "application/json")
 	if c.UserAgent != "" {
 		req.Header.Set("User-Agent", c.UserAgent)
@@ -246,6 +696,23 @@ func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Requ
 	return req, nil
 }
 
+// requestCompressionThreshold is the size in bytes above which request bodies are compressed when
+// WithRequestCompression is enabled.
+const requestCompressionThreshold = 1024
+
+// gzipBody returns a gzip compressed copy of b.
+func gzipBody(b *bytes.Buffer) (*bytes.Buffer, error) {
+	compressed := &bytes.Buffer{}
+	w := gzip.NewWriter(compressed)
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return compressed, nil
+}
+
 // BareDo sends an API request and lets you handle the api response. If an error
 // or API Error occurs, the error will contain more information. Otherwise, you
 // are supposed to read and close the response's Body.
@@ -256,21 +723,45 @@ func (c *Client) BareDo(ctx context.Context, req *http.Request) (*http.Response,
 	return c.bareDo(ctx, req)
 }
 
+// retryingContextKey marks the context of a request being retried with the retry classifier, so the retries of
+// nested bareDo calls are not multiplied.
+type retryingContextKey struct{}
+
+// authRetriedContextKey marks the context of a request retried after refreshing the authenticator, so it is retried
+// at most once.
+type authRetriedContextKey struct{}
+
 func (c *Client) bareDo(ctx context.Context, req *http.Request) (*http.Response, error) {
 	if ctx == nil {
 		return nil, fmt.Errorf("cannot pass a nil-context")
 	}
+	if c.defaultRequestTimeout > 0 {
+		if _, ok := ctx.Deadline(); !ok {
+			return c.bareDoWithTimeout(ctx, req)
+		}
+	}
+	if c.retryClassifier != nil && ctx.Value(retryingContextKey{}) == nil {
+		return c.bareDoWithRetries(ctx, req)
+	}
 	if c.authenticator != nil {
 		if c.debug {
 			c.logger.Printf("authenticating with %T", c.authenticator)
 		}
-		if err := c.authenticator.Authenticate(req); err != nil {
+		// Authenticate with ctx, as req may have been created without it, so that a token refresh made while
+		// authenticating is canceled along with the request.
+		if err := c.authenticator.Authenticate(req.WithContext(ctx)); err != nil {
 			return nil, err
 		}
 		if c.debug {
 			c.logger.Print("authentication succeeded")
 		}
 	}
+	if teamID, ok := ctx.Value(teamIDContextKey{}).(string); ok && teamID != "" && req != nil {
+		req.Header.Set(authentication.SysdigTeamIDHeader, teamID)
+	}
+	if c.requestIDHeader != "" && req != nil && req.Header.Get(c.requestIDHeader) == "" {
+		req.Header.Set(c.requestIDHeader, c.requestIDGenerator())
+	}
 	if c.debug {
 		if req != nil {
 			var data []byte
@@ -283,8 +774,18 @@ func (c *Client) bareDo(ctx context.Context, req *http.Request) (*http.Response,
 					req.Body = ioutil.NopCloser(bytes.NewBuffer(data))
 				}
 			}
+			if gzipped(req.Header) {
+				data = []byte(fmt.Sprintf("<%d bytes gzip compressed>", len(data)))
+			}
 			if req.URL != nil {
-				c.logger.Printf("-> request: %s %s\n%s", req.Method, req.URL.String(), string(data))
+				requestID := ""
+				if c.requestIDHeader != "" {
+					requestID = fmt.Sprintf(" [%s: %s]", c.requestIDHeader, req.Header.Get(c.requestIDHeader))
+				}
+				c.logger.Printf("-> request: %s %s%s\n%s", req.Method, req.URL.String(), requestID, c.debugBody(data))
+				for k, v := range req.Header {
+					c.logger.Printf("%s: %s", k, redactHeader(k, v))
+				}
 			}
 		}
 	}
@@ -298,42 +799,212 @@ func (c *Client) bareDo(ctx context.Context, req *http.Request) (*http.Response,
 		}
 		return nil, err
 	}
-	if c.authenticator != nil && isAuthenticationError(resp) {
+	if c.authRetry && c.authenticator != nil && isAuthenticationError(resp) && ctx.Value(authRetriedContextKey{}) == nil {
 		if refreshableAuthenticator, ok := c.authenticator.(authentication.Refreshable); ok {
-			if rerr := refreshableAuthenticator.Refresh(); rerr != nil {
+			failed := authentication.ContextWithFailedAuthorization(ctx, req.Header.Get(authentication.AuthorizationHeader))
+			if rerr := refresh(failed, refreshableAuthenticator); rerr != nil {
 				c.logger.Printf("error refreshing authenticator: %v", rerr)
 				return nil, rerr
 			}
-			// Retry one time after a successful refresh
-			return c.bareDo(ctx, req)
+			// Retry one time after a successful refresh, resending the request body consumed by the first attempt.
+			resp.Body.Close()
+			if req.GetBody != nil {
+				body, berr := req.GetBody()
+				if berr != nil {
+					return nil, berr
+				}
+				req.Body = body
+			}
+			return c.bareDo(context.WithValue(ctx, authRetriedContextKey{}, true), req)
 		}
 	}
-	if gzipped(resp) {
-		var gerr error
-		resp.Body, gerr = gzip.NewReader(resp.Body)
+	if gzipped(resp.Header) {
+		gr, gerr := gzip.NewReader(resp.Body)
 		if gerr != nil {
 			c.logger.Printf("failed to inflate gzipped response: %v", gerr)
+			resp.Body.Close()
 			return nil, gerr
 		}
+		resp.Body = &gzipReadCloser{Reader: gr, body: resp.Body}
 	}
 	if c.debug {
-		data, rerr := io.ReadAll(resp.Body)
-		if err != nil {
+		var body io.Reader = resp.Body
+		if c.debugBodyLimit > 0 {
+			// Read one byte past the limit to tell whether the logged body is truncated.
+			body = io.LimitReader(resp.Body, c.debugBodyLimit+1)
+		}
+		data, rerr := io.ReadAll(body)
+		if rerr != nil {
 			c.logger.Printf("failed to read response body for debugging: %v", rerr)
 		} else {
-			c.logger.Printf("<- response: %d\n%s", resp.StatusCode, string(data))
+			c.logger.Printf("<- response: %d\n%s", resp.StatusCode, c.debugBody(data))
 			for k, v := range resp.Header {
-				c.logger.Printf("%s: %s", k, strings.Join(v, ","))
+				c.logger.Printf("%s: %s", k, redactHeader(k, v))
 			}
-			resp.Body = ioutil.NopCloser(bytes.NewBuffer(data))
+			resp.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(data), resp.Body), Closer: resp.Body}
 		}
 	}
 	err = c.CheckResponse(resp)
 	return resp, err
 }
 
-func gzipped(resp *http.Response) bool {
-	return strings.Contains(resp.Header.Get("Content-Encoding"), "gzip")
+const redacted = "***"
+
+// redactedHeaders are the canonical header keys whose values are redacted in debug logging.
+var redactedHeaders = map[string]bool{
+	http.CanonicalHeaderKey(authentication.AuthorizationHeader): true,
+	http.CanonicalHeaderKey(authentication.IBMInstanceIDHeader): true,
+	http.CanonicalHeaderKey("Cookie"):                           true,
+	http.CanonicalHeaderKey("Set-Cookie"):                       true,
+}
+
+// redactedBodyFieldNames matches the names of JSON fields whose values are redacted in debug logging.
+const redactedBodyFieldNames = `"(?:apiKey|serviceKey|routingKey|accessKey|access_token|refresh_token|token|key|password)"`
+
+// redactedBodyFields matches JSON fields in request and response bodies whose values are redacted in debug logging.
+var redactedBodyFields = regexp.MustCompile(`(` + redactedBodyFieldNames + `\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// truncatedBodyField matches a sensitive JSON field at the end of a truncated body whose value was cut off.
+var truncatedBodyField = regexp.MustCompile(`(` + redactedBodyFieldNames + `\s*:\s*)"(?:[^"\\]|\\.)*\\?$`)
+
+// debugBody returns the body for debug logging, truncated to the debugBodyLimit and with sensitive JSON fields
+// redacted.
+func (c *Client) debugBody(data []byte) string {
+	if c.debugBodyLimit <= 0 || int64(len(data)) <= c.debugBodyLimit {
+		return redactBody(data)
+	}
+	body := truncatedBodyField.ReplaceAllString(redactBody(data[:c.debugBodyLimit]), `$1"`+redacted)
+	return fmt.Sprintf("%s\n<body truncated after %d bytes>", body, c.debugBodyLimit)
+}
+
+// readCloser combines an io.Reader with the io.Closer of the underlying body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// redactHeader returns the joined header values for debug logging, redacting the values of sensitive headers.
+func redactHeader(key string, values []string) string {
+	if redactedHeaders[http.CanonicalHeaderKey(key)] {
+		return redacted
+	}
+	return strings.Join(values, ",")
+}
+
+// redactBody returns the body for debug logging, redacting the values of sensitive JSON fields.
+func redactBody(data []byte) string {
+	return redactedBodyFields.ReplaceAllString(string(data), `$1"`+redacted+`"`)
+}
+
+// bareDoWithTimeout sends the request with a context derived from ctx which times out after the
+// defaultRequestTimeout. The derived context is canceled once the response body is closed.
+func (c *Client) bareDoWithTimeout(ctx context.Context, req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.defaultRequestTimeout)
+	resp, err := c.bareDo(ctx, req)
+	// Error response bodies have already been read by CheckResponse, so it is safe to cancel.
+	if err != nil || resp == nil || resp.Body == nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// bareDoWithRetries sends the request with bareDo, retrying it while the retry classifier reports it as retryable.
+func (c *Client) bareDoWithRetries(ctx context.Context, req *http.Request) (*http.Response, error) {
+	ctx = context.WithValue(ctx, retryingContextKey{}, true)
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.bareDo(ctx, req)
+		retry, berr := c.classifyRetry(resp, err)
+		if berr != nil {
+			return resp, berr
+		}
+		if !retry || attempt >= c.retryMax || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if req.GetBody != nil {
+			body, gerr := req.GetBody()
+			if gerr != nil {
+				return nil, gerr
+			}
+			req.Body = body
+		}
+		if c.debug {
+			c.logger.Printf("retrying request after %s (retry %d of %d)", backoff, attempt+1, c.retryMax)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// classifyRetry reports whether the retry classifier considers the request retryable. Up to RetryClassifierBodyLimit
+// bytes of the response body are buffered before calling the classifier and put back in front of the rest of the body
+// afterwards, so both the classifier and the caller can read it.
+func (c *Client) classifyRetry(resp *http.Response, err error) (bool, error) {
+	if resp == nil || resp.Body == nil {
+		return c.retryClassifier(resp, err), nil
+	}
+	body := resp.Body
+	data, rerr := io.ReadAll(io.LimitReader(body, RetryClassifierBodyLimit))
+	if rerr != nil {
+		body.Close()
+		return false, rerr
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	retry := c.retryClassifier(resp, err)
+	resp.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(data), body), Closer: body}
+	return retry, nil
+}
+
+// cancelOnCloseBody is a response body which cancels the request context when closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the request context.
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// gzipReadCloser is a response body which inflates a gzipped body and closes both the gzip reader and the
+// underlying body when closed.
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+// Close closes the gzip reader and the underlying body.
+func (g *gzipReadCloser) Close() error {
+	gerr := g.Reader.Close()
+	if err := g.body.Close(); err != nil {
+		return err
+	}
+	return gerr
+}
+
+// gzipped reports whether the Content-Encoding header contains a gzip or x-gzip coding.
+func gzipped(h http.Header) bool {
+	for _, v := range h.Values("Content-Encoding") {
+		for _, coding := range strings.Split(v, ",") {
+			coding = strings.TrimSpace(coding)
+			if strings.EqualFold(coding, "gzip") || strings.EqualFold(coding, "x-gzip") {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func isAuthenticationError(resp *http.Response) bool {
@@ -343,6 +1014,15 @@ func isAuthenticationError(resp *http.Response) bool {
 	return resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized
 }
 
+// refresh refreshes the authentication.Refreshable, with ctx if it is an authentication.ContextRefreshable.
+// ctx carries the Authorization header of the rejected request, see authentication.FailedAuthorization.
+func refresh(ctx context.Context, r authentication.Refreshable) error {
+	if cr, ok := r.(authentication.ContextRefreshable); ok {
+		return cr.RefreshContext(ctx)
+	}
+	return r.Refresh()
+}
+
 // Do sends an API request and returns the API response. The API response is
 // JSON decoded and stored in the value pointed to by v, or returned as an
 // error if an API error has occurred. If v implements the io.Writer interface,
@@ -357,20 +1037,254 @@ func (c *Client) Do(ctx context.Context, req *http.Request, v interface{}) (*htt
 		return resp, err
 	}
 	defer resp.Body.Close()
+	return resp, c.decode(resp.Body, v)
+}
+
+// DoWithRaw sends an API request like Do and also returns the raw response body, e.g. for logging or re-parsing.
+// The body is buffered once, then decoded into v as with Do. The raw body of an API error response is returned along
+// with the error.
+func (c *Client) DoWithRaw(ctx context.Context, req *http.Request, v interface{}) ([]byte, *http.Response, error) {
+	resp, err := c.BareDo(ctx, req)
+	if resp == nil || resp.Body == nil {
+		return nil, resp, err
+	}
+	defer resp.Body.Close()
+	raw, rerr := io.ReadAll(resp.Body)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	if err != nil {
+		return raw, resp, err
+	}
+	if rerr != nil {
+		return nil, resp, rerr
+	}
+	return raw, resp, c.decode(bytes.NewReader(raw), v)
+}
+
+// decode decodes the response body in r into v for Do. If v implements the io.Writer interface, the body is written
+// to v. If v is nil, the body is ignored.
+func (c *Client) decode(r io.Reader, v interface{}) error {
 	switch iv := v.(type) {
 	case nil:
+		return nil
 	case io.Writer:
-		_, err = io.Copy(iv, resp.Body)
+		_, err := io.Copy(iv, r)
+		return err
 	default:
-		decErr := json.NewDecoder(resp.Body).Decode(v)
-		if decErr == io.EOF {
-			decErr = nil // ignore EOF errors caused by empty response body
+		if c.strictDecoding {
+			return decodeStrict(r, v)
 		}
-		if decErr != nil {
-			err = decErr
+		err := json.NewDecoder(r).Decode(v)
+		if err == io.EOF {
+			err = nil // ignore EOF errors caused by empty response body
 		}
+		return err
 	}
-	return resp, err
+}
+
+// UnknownFieldsError is returned by Client.Do for a Client created WithStrictDecoding when the response has fields
+// which are not part of the decoded value.
+type UnknownFieldsError struct {
+	// Fields are the paths of the unknown fields, e.g. "user.teamRoles[0].extra".
+	Fields []string
+}
+
+// Error implements the error interface for UnknownFieldsError.
+func (e *UnknownFieldsError) Error() string {
+	return fmt.Sprintf("response has unknown fields: %s", strings.Join(e.Fields, ", "))
+}
+
+// decodeStrict decodes the JSON in r into v, returning an *UnknownFieldsError if it has fields which are not part of v.
+// v is decoded even if there are unknown fields.
+func decodeStrict(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil // ignore empty response bodies
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	strictErr := dec.Decode(v)
+	if strictErr == nil {
+		return nil
+	}
+	// The strict decode stops at the first unknown field, so decode again to fill v. If this succeeds, the strict
+	// decode could only have failed on unknown fields.
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	fields := unknownFields(raw, reflect.TypeOf(v), "")
+	if len(fields) == 0 {
+		return strictErr
+	}
+	sort.Strings(fields)
+	return &UnknownFieldsError{Fields: fields}
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownFields returns the paths of the fields of the decoded JSON value raw which are not part of type t.
+// Types implementing json.Unmarshaler decode themselves and are not inspected.
+func unknownFields(raw interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		if t.Implements(jsonUnmarshalerType) {
+			return nil
+		}
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return nil
+	}
+	var unknown []string
+	switch raw := raw.(type) {
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := jsonFields(t)
+			for key, value := range raw {
+				field, ok := lookupJSONField(fields, key)
+				if !ok {
+					unknown = append(unknown, fieldPath(path, key))
+					continue
+				}
+				unknown = append(unknown, unknownFields(value, field.typ, fieldPath(path, key))...)
+			}
+		case reflect.Map:
+			for key, value := range raw {
+				unknown = append(unknown, unknownFields(value, t.Elem(), fieldPath(path, key))...)
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, value := range raw {
+				unknown = append(unknown, unknownFields(value, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return unknown
+}
+
+// fieldPath returns the path of the field key of the value at path.
+func fieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonField is a struct field as seen by encoding/json.
+type jsonField struct {
+	name   string
+	tagged bool
+	index  []int
+	typ    reflect.Type
+}
+
+// jsonFields returns the fields of struct type t which encoding/json decodes into, in field order, resolving the
+// fields of embedded structs with the same precedence rules: shallower fields win, then tagged fields, and fields
+// which remain ambiguous are ignored.
+func jsonFields(t reflect.Type) []jsonField {
+	var all []jsonField
+	collectJSONFields(t, nil, map[reflect.Type]bool{}, &all)
+	byName := make(map[string][]jsonField)
+	for _, f := range all {
+		byName[f.name] = append(byName[f.name], f)
+	}
+	var fields []jsonField
+	for _, f := range all {
+		candidates := byName[f.name]
+		if candidates == nil {
+			continue // already resolved
+		}
+		byName[f.name] = nil
+		if dominant, ok := dominantJSONField(candidates); ok {
+			fields = append(fields, dominant)
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i].index, fields[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return fields
+}
+
+// collectJSONFields appends the fields of struct type t, and of its embedded structs, to fields.
+func collectJSONFields(t reflect.Type, index []int, visited map[reflect.Type]bool, fields *[]jsonField) {
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	defer delete(visited, t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		fieldIndex := append(append([]int(nil), index...), i)
+		ft := field.Type
+		if field.Anonymous {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if field.PkgPath != "" && ft.Kind() != reflect.Struct {
+				continue
+			}
+			if name == "" && ft.Kind() == reflect.Struct {
+				collectJSONFields(ft, fieldIndex, visited, fields)
+				continue
+			}
+		} else if field.PkgPath != "" {
+			continue
+		}
+		f := jsonField{name: name, tagged: name != "", index: fieldIndex, typ: field.Type}
+		if !f.tagged {
+			f.name = field.Name
+		}
+		*fields = append(*fields, f)
+	}
+}
+
+// dominantJSONField returns the field encoding/json uses among fields sharing a name, if any.
+func dominantJSONField(fields []jsonField) (jsonField, bool) {
+	dominant := fields[0]
+	ambiguous := false
+	for _, f := range fields[1:] {
+		switch depth := len(dominant.index); {
+		case len(f.index) < depth || len(f.index) == depth && f.tagged && !dominant.tagged:
+			dominant, ambiguous = f, false
+		case len(f.index) == depth && f.tagged == dominant.tagged:
+			ambiguous = true
+		}
+	}
+	return dominant, !ambiguous
+}
+
+// lookupJSONField finds the field for a JSON key like encoding/json, preferring an exact match, then the first field
+// matching case-insensitively.
+func lookupJSONField(fields []jsonField, key string) (jsonField, bool) {
+	for _, field := range fields {
+		if field.name == key {
+			return field, true
+		}
+	}
+	for _, field := range fields {
+		if strings.EqualFold(field.name, key) {
+			return field, true
+		}
+	}
+	return jsonField{}, false
 }
 
 type prometheusClient struct {
@@ -395,21 +1309,100 @@ func (c *prometheusClient) URL(endpoint string, args map[string]string) *url.URL
 
 // Do implements Do for the Prometheus Client API client.
 // See: https://github.com/prometheus/client_golang/blob/v1.9.0/api/client.go
+// The response is returned alongside API errors so the Prometheus client can fall back from POST to GET for
+// endpoints such as LabelNames and Series when Sysdig responds with 405 Method Not Allowed. API errors are returned
+// as a *v1.Error.
 func (c *prometheusClient) Do(ctx context.Context, request *http.Request) (*http.Response, []byte, error) {
 	resp, err := c.client.BareDo(ctx, request)
-	if err != nil {
+	if resp == nil {
 		return nil, nil, err
 	}
 	defer resp.Body.Close()
-	b, err := io.ReadAll(resp.Body)
-	return resp, b, err
+	b, rerr := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, b, prometheusError(err, b)
+	}
+	return resp, b, rerr
+}
+
+// prometheusError converts an *ErrorResponse from the Sysdig Prometheus API into a *v1.Error, so API errors are
+// reported by the Prometheus client in the same way as errors from a Prometheus server. Both Prometheus style and
+// Sysdig style error bodies are supported. Other errors are returned unchanged.
+func prometheusError(err error, body []byte) error {
+	var errorResponse *ErrorResponse
+	if !errors.As(err, &errorResponse) {
+		return err
+	}
+	var promResponse struct {
+		Status    string       `json:"status"`
+		ErrorType v1.ErrorType `json:"errorType"`
+		Error     string       `json:"error"`
+	}
+	if json.Unmarshal(body, &promResponse) == nil && promResponse.Status == "error" {
+		return &v1.Error{Type: promResponse.ErrorType, Msg: promResponse.Error, Detail: string(body)}
+	}
+	errorType := v1.ErrClient
+	if errorResponse.Response.StatusCode >= http.StatusInternalServerError {
+		errorType = v1.ErrServer
+	}
+	msgs := make([]string, 0, len(errorResponse.Errors)+1)
+	if len(bytes.TrimSpace(body)) > 0 && errorResponse.Message != "" {
+		msgs = append(msgs, errorResponse.Message)
+	}
+	for _, e := range errorResponse.Errors {
+		if e.Reason != "" {
+			msgs = append(msgs, e.Message+": "+e.Reason)
+		} else {
+			msgs = append(msgs, e.Message)
+		}
+	}
+	if len(msgs) == 0 {
+		msgs = append(msgs, http.StatusText(errorResponse.Response.StatusCode))
+	}
+	return &v1.Error{
+		Type:   errorType,
+		Msg:    fmt.Sprintf("%d %s", errorResponse.Response.StatusCode, strings.Join(msgs, "; ")),
+		Detail: string(body),
+	}
+}
+
+// SupportsPrometheus probes the Sysdig Prometheus HTTP API proxy and reports whether it is available for this account.
+// Client.Prometheus is always set, but calls will fail with confusing errors when the proxy is not available, so this
+// can be used to gate Prometheus usage. A missing or non-Prometheus response is reported as false with a nil error.
+func (c *Client) SupportsPrometheus(ctx context.Context) (bool, error) {
+	u := "prometheus/api/v1/query?query=vector(1)"
+	req, err := c.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return false, err
+	}
+	var probe struct {
+		Status string `json:"status"`
+	}
+	_, err = c.Do(ctx, req, &probe)
+	if err != nil {
+		var errorResponse *ErrorResponse
+		if errors.As(err, &errorResponse) {
+			switch errorResponse.Response.StatusCode {
+			case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+				return false, nil
+			}
+			return false, err
+		}
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			return false, nil
+		}
+		return false, err
+	}
+	return probe.Status == "success", nil
 }
 
 // ErrorResponse reports one or more errors caused by an API request.
 type ErrorResponse struct {
-	Response *http.Response
-	Message  string  `json:"message,omitempty"`
-	Errors   []Error `json:"errors,omitempty"`
+	Response   *http.Response
+	StatusCode int     `json:"-"`
+	Message    string  `json:"message,omitempty"`
+	Errors     []Error `json:"errors,omitempty"`
 }
 
 // Error contains a further explanation for the reason of an error..
@@ -425,26 +1418,92 @@ func (r *ErrorResponse) Error() string {
 		r.Response.StatusCode, r.Message, r.Errors)
 }
 
+// IsNotFound reports whether err is an *ErrorResponse for a 404 Not Found response.
+func IsNotFound(err error) bool {
+	return hasStatusCode(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether err is an *ErrorResponse for a 401 Unauthorized response.
+func IsUnauthorized(err error) bool {
+	return hasStatusCode(err, http.StatusUnauthorized)
+}
+
+// IsConflict reports whether err is an *ErrorResponse for a 409 Conflict response.
+func IsConflict(err error) bool {
+	return hasStatusCode(err, http.StatusConflict)
+}
+
+func hasStatusCode(err error, statusCode int) bool {
+	var errorResponse *ErrorResponse
+	return errors.As(err, &errorResponse) && errorResponse.StatusCode == statusCode
+}
+
+// BatchError reports the failed operations of a batch operation, such as NotificationChannelsService.CreateBatch.
+type BatchError struct {
+	// Total is the number of operations attempted in the batch.
+	Total int
+	// Errors are the failed operations, in the order they were attempted.
+	Errors []BatchItemError
+}
+
+// BatchItemError is the error for a single failed operation in a BatchError.
+type BatchItemError struct {
+	// Index is the index of the failed operation in the batch.
+	Index int
+	// Err is the error returned by the operation.
+	Err error
+}
+
+// Error implements the error interface for BatchError.
+func (e *BatchError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, item := range e.Errors {
+		msgs = append(msgs, fmt.Sprintf("[%d]: %v", item.Index, item.Err))
+	}
+	return fmt.Sprintf("%d of %d batch operations failed: %s", len(e.Errors), e.Total, strings.Join(msgs, "; "))
+}
+
+// maxErrorBodyMessageLength is the maximum number of bytes of a non-JSON error response body used as the
+// ErrorResponse Message.
+const maxErrorBodyMessageLength = 512
+
 // CheckResponse checks the API response for errors, and returns them if
 // present. A response is considered an error if it has a status code outside
 // the 200 range.
 // API error responses are expected to have response
 // body, and a JSON response body that maps to ErrorResponse.
+// Non-JSON error bodies, such as HTML error pages from proxies and load balancers, are reported with a truncated copy
+// of the body as the Message.
 func (c *Client) CheckResponse(r *http.Response) error {
 	if c := r.StatusCode; http.StatusOK <= c && c <= 299 {
 		return nil
 	}
-	errorResponse := &ErrorResponse{Response: r}
+	errorResponse := &ErrorResponse{Response: r, StatusCode: r.StatusCode}
 	data, err := io.ReadAll(r.Body)
 	if err == nil && data != nil {
 		if uerr := json.Unmarshal(data, errorResponse); uerr != nil {
-			errorResponse.Message = fmt.Sprintf("error unmarshaling error response: %v", uerr)
+			errorResponse.Message = truncateErrorBody(data)
+			errorResponse.Errors = nil
 		}
 	}
 	r.Body = ioutil.NopCloser(bytes.NewBuffer(data))
 	return errorResponse
 }
 
+// truncateErrorBody returns the body as a string, truncated to maxErrorBodyMessageLength bytes without splitting a
+// UTF-8 encoded rune.
+func truncateErrorBody(body []byte) string {
+	body = bytes.TrimSpace(body)
+	if len(body) <= maxErrorBodyMessageLength {
+		return string(body)
+	}
+	n := maxErrorBodyMessageLength
+	for n > 0 && !utf8.RuneStart(body[n]) {
+		n--
+	}
+	return string(body[:n]) + "..."
+}
+
 // addOptions adds the parameters in opts as URL query parameters to s. opts
 // must be a struct whose fields may contain "url" tags.
 func addOptions(s string, opts interface{}) (string, error) {
@@ -463,3 +1522,82 @@ func addOptions(s string, opts interface{}) (string, error) {
 	u.RawQuery = qs.Encode()
 	return u.String(), nil
 }
+
+// serverManagedTag is the `sysdig` struct tag option marking fields which are managed by the Sysdig API.
+const serverManagedTag = "server-managed"
+
+// environmentTag is the `sysdig` struct tag option marking fields which are specific to the environment a value was
+// retrieved from, such as the Team or User owning it, but may be set by the caller.
+const environmentTag = "environment"
+
+// stripServerManaged zeroes the fields of the struct pointed to by v which are tagged `sysdig:"server-managed"`,
+// so values retrieved from the API can be used in Create requests. Nested structs are not traversed.
+func stripServerManaged(v interface{}) {
+	stripTagged(v, serverManagedTag)
+}
+
+// stripTagged zeroes the fields of the struct pointed to by v which are tagged with any of the `sysdig` struct tag
+// options. Nested structs are not traversed.
+func stripTagged(v interface{}, options ...string) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rv.Field(i)
+		if field.CanSet() && hasSysdigTag(rt.Field(i), options...) {
+			field.Set(reflect.Zero(field.Type()))
+		}
+	}
+}
+
+// taggedJSONFields returns the JSON names of the fields of struct type t which are tagged with any of the `sysdig`
+// struct tag options.
+func taggedJSONFields(t reflect.Type, options ...string) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || !hasSysdigTag(field, options...) {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// hasSysdigTag reports whether field is tagged with any of the `sysdig` struct tag options.
+func hasSysdigTag(field reflect.StructField, options ...string) bool {
+	for _, tagged := range strings.Split(field.Tag.Get("sysdig"), ",") {
+		for _, option := range options {
+			if tagged == option {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Bool is a helper routine that allocates a new bool value
+// to store v and returns a pointer to it.
+func Bool(v bool) *bool { return &v }
+
+// Int is a helper routine that allocates a new int value
+// to store v and returns a pointer to it.
+func Int(v int) *int { return &v }
+
+// Float64 is a helper routine that allocates a new float64 value
+// to store v and returns a pointer to it.
+func Float64(v float64) *float64 { return &v }
+
+// String is a helper routine that allocates a new string value
+// to store v and returns a pointer to it.
+func String(v string) *string { return &v }
@@ -1,19 +1,30 @@
 package sysdig
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
 	"github.com/trinchan/sysdig-go/sysdig/authentication"
 	"github.com/trinchan/sysdig-go/sysdig/authentication/accesstoken"
+	"github.com/trinchan/sysdig-go/sysdig/authentication/ibmiam"
 )
 
 const (
@@ -99,6 +110,17 @@ func testHeader(t *testing.T, r *http.Request, header string, want string) {
 	}
 }
 
+func testBody(t *testing.T, r *http.Request, want string) {
+	t.Helper()
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Errorf("Error reading request body: %v", err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("request Body is %s, want %s", got, want)
+	}
+}
+
 // Test function under NewRequest failure and then s.client.Do failure.
 // Method f should be a regular call that would normally succeed, but
 // should return an error when NewRequest or s.client.Do fails.
@@ -159,16 +181,116 @@ func TestClientOptions(t *testing.T) {
 			option:  WithUserAgent(userAgent),
 			wantErr: false,
 		},
+		{
+			name:    "WithUserAgentSuffix",
+			option:  WithUserAgentSuffix("my-app/1.0"),
+			wantErr: false,
+		},
 		{
 			name:    "WithResponseCompression",
 			option:  WithResponseCompression(true),
 			wantErr: false,
 		},
+		{
+			name:    "WithTimeout",
+			option:  WithTimeout(time.Second),
+			wantErr: false,
+		},
+		{
+			name:    "WithProxy",
+			option:  WithProxy("http://proxy.example.com:3128"),
+			wantErr: false,
+		},
+		{
+			name:    "WithProxy_Invalid",
+			option:  WithProxy("://bad"),
+			wantErr: true,
+		},
+		{
+			name:    "WithRequestIDHeader",
+			option:  WithRequestIDHeader("X-Request-ID", nil),
+			wantErr: false,
+		},
+		{
+			name:    "WithRequestIDHeader_Blank",
+			option:  WithRequestIDHeader("", nil),
+			wantErr: true,
+		},
+		{
+			name:    "WithRequestCompression",
+			option:  WithRequestCompression(true),
+			wantErr: false,
+		},
 		{
 			name:    "WithDebug",
 			option:  WithDebug(false),
 			wantErr: false,
 		},
+		{
+			name:    "WithStrictDecoding",
+			option:  WithStrictDecoding(true),
+			wantErr: false,
+		},
+		{
+			name:    "WithClassifiedRetries",
+			option:  WithClassifiedRetries(5, time.Second),
+			wantErr: false,
+		},
+		{
+			name:    "WithClassifiedRetries_NegativeRetries",
+			option:  WithClassifiedRetries(-1, time.Second),
+			wantErr: true,
+		},
+		{
+			name:    "WithClassifiedRetries_NegativeBackoff",
+			option:  WithClassifiedRetries(1, -time.Second),
+			wantErr: true,
+		},
+		{
+			name:    "WithAuthRetry",
+			option:  WithAuthRetry(false),
+			wantErr: false,
+		},
+		{
+			name:    "WithTransport",
+			option:  WithTransport(http.DefaultTransport),
+			wantErr: false,
+		},
+		{
+			name:    "WithTransport_Nil",
+			option:  WithTransport(nil),
+			wantErr: true,
+		},
+		{
+			name:    "WithIdempotencyKeys",
+			option:  WithIdempotencyKeys(true),
+			wantErr: false,
+		},
+		{
+			name:    "WithDebugBodyLimit",
+			option:  WithDebugBodyLimit(1024),
+			wantErr: false,
+		},
+		{
+			name:    "WithDebugBodyLimit_Negative",
+			option:  WithDebugBodyLimit(-1),
+			wantErr: true,
+		},
+		{
+			name:    "WithScopeValidation",
+			option:  WithScopeValidation(true),
+			wantErr: false,
+		},
+		{
+			name:    "WithDefaultRequestTimeout",
+			option:  WithDefaultRequestTimeout(time.Second),
+			wantErr: false,
+		},
+		{
+			name:    "WithDefaultEventCategories",
+			option:  WithDefaultEventCategories(),
+			wantErr: false,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -180,6 +302,55 @@ func TestClientOptions(t *testing.T) {
 	}
 }
 
+func TestWithUserAgentSuffix(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ClientOption
+		want    string
+	}{
+		{
+			name:    "default",
+			options: []ClientOption{WithUserAgentSuffix("my-app/1.0")},
+			want:    "sysdig-go my-app/1.0",
+		},
+		{
+			name:    "multiple",
+			options: []ClientOption{WithUserAgentSuffix("my-app/1.0"), WithUserAgentSuffix("extra")},
+			want:    "sysdig-go my-app/1.0 extra",
+		},
+		{
+			name:    "after user agent",
+			options: []ClientOption{WithUserAgent("custom"), WithUserAgentSuffix("my-app/1.0")},
+			want:    "custom my-app/1.0",
+		},
+		{
+			name:    "before user agent",
+			options: []ClientOption{WithUserAgentSuffix("my-app/1.0"), WithUserAgent("custom")},
+			want:    "custom",
+		},
+		{
+			name:    "empty suffix",
+			options: []ClientOption{WithUserAgentSuffix("")},
+			want:    "sysdig-go",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient(nil, test.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := client.NewRequest(http.MethodGet, "foo", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Header.Get("User-Agent"); got != test.want {
+				t.Errorf("got User-Agent: %q, want: %q", got, test.want)
+			}
+		})
+	}
+}
+
 func TestClientCopy(t *testing.T) {
 	c, err := NewClient(nil)
 	if err != nil {
@@ -191,94 +362,273 @@ func TestClientCopy(t *testing.T) {
 	}
 }
 
-func TestSetLogger(t *testing.T) {
-	c, err := NewClient(nil)
+func TestNewClient_DoesNotShareDefaultClient(t *testing.T) {
+	c1, err := NewClient(nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	l := log.Default()
-	c.SetLogger(l)
-	if c.logger != l {
-		t.Error("Client returned different logger, but should be same")
+	c2, err := NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1.httpClient == http.DefaultClient || c2.httpClient == http.DefaultClient {
+		t.Fatal("NewClient used http.DefaultClient, but should use a copy")
+	}
+	if c1.httpClient == c2.httpClient {
+		t.Fatal("NewClient returned clients sharing the same http.Client, but should be different")
+	}
+	c1.httpClient.Timeout = time.Second
+	c2.httpClient.Timeout = time.Minute
+	if http.DefaultClient.Timeout != 0 {
+		t.Errorf("http.DefaultClient.Timeout = %v, want 0", http.DefaultClient.Timeout)
 	}
 }
 
-func TestAuthentication(t *testing.T) {
+func TestNewClient_Authenticator(t *testing.T) {
 	a, err := accesstoken.Authenticator("foo")
 	if err != nil {
 		t.Fatal(err)
 	}
-	client, mux, baseURL, teardown := setup(a)
+	client, mux, _, teardown := setup(a)
 	defer teardown()
-	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/user/me", func(w http.ResponseWriter, r *http.Request) {
 		testHeader(t, r, authentication.AuthorizationHeader, authentication.AuthorizationHeaderFor("foo"))
-		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"user":{"id":1}}`)
 	})
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/foo", nil)
+	if _, _, err := client.Users.Me(context.Background()); err != nil {
+		t.Fatalf("Users.Me returned error: %v", err)
+	}
+}
+
+func TestWithTransport(t *testing.T) {
+	httpClient := &http.Client{Timeout: time.Minute}
+	var recorded *http.Request
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		recorded = r
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"user":{"id":1}}`)),
+			Request:    r,
+		}, nil
+	})
+	c, err := NewClient(nil, WithHTTPClient(httpClient), WithTransport(rt))
 	if err != nil {
-		t.Fatalf("failed to create request: %v", err)
+		t.Fatalf("unexpected error: %v", err)
 	}
-	resp, err := client.BareDo(context.Background(), req)
+	if c.httpClient == httpClient || httpClient.Transport != nil {
+		t.Fatal("WithTransport modified the provided http.Client, but should use a copy")
+	}
+	if c.httpClient.Timeout != time.Minute {
+		t.Errorf("Timeout = %v, want %v", c.httpClient.Timeout, time.Minute)
+	}
+
+	me, _, err := c.Users.Me(context.Background())
 	if err != nil {
-		t.Fatalf("failed to baredo: %v", err)
+		t.Fatalf("Users.Me returned error: %v", err)
+	}
+	if me.User.ID != 1 {
+		t.Errorf("Users.Me returned %+v, want user 1", me)
+	}
+	if recorded == nil {
+		t.Fatal("WithTransport RoundTripper did not receive the request")
+	}
+	if got, want := recorded.URL.String(), defaultBaseURL+"api/user/me"; got != want {
+		t.Errorf("recorded request URL = %q, want %q", got, want)
+	}
+
+	if _, err := NewClient(nil, WithTransport(nil)); err == nil {
+		t.Error("WithTransport(nil) did not return an expected error")
 	}
-	defer resp.Body.Close()
 }
 
-func TestClientResponses(t *testing.T) {
-	client, mux, baseURL, teardown := setup(nil)
-	defer teardown()
-	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	})
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/foo", nil)
+func TestWithProxy(t *testing.T) {
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	c, err := NewClient(nil, WithHTTPClient(httpClient), WithProxy("http://proxy.example.com:3128"), WithTimeout(time.Minute))
 	if err != nil {
-		t.Fatalf("failed to create request: %v", err)
+		t.Fatalf("unexpected error: %v", err)
 	}
-	resp, err := client.BareDo(context.Background(), req)
+	if c.httpClient == httpClient {
+		t.Fatal("WithProxy modified the provided http.Client, but should use a copy")
+	}
+	if c.httpClient.Timeout != time.Minute {
+		t.Errorf("Timeout = %v, want %v", c.httpClient.Timeout, time.Minute)
+	}
+	transport, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Transport is %T, want *http.Transport", c.httpClient.Transport)
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://app.sysdigcloud.com/api/user/me", nil)
 	if err != nil {
-		t.Fatalf("failed to baredo: %v", err)
+		t.Fatal(err)
+	}
+	proxy, err := transport.Proxy(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := proxy.String(), "http://proxy.example.com:3128"; got != want {
+		t.Errorf("proxy = %q, want %q", got, want)
+	}
+	if httpClient.Transport.(*http.Transport).Proxy != nil {
+		t.Error("WithProxy modified the provided http.Transport, but should use a copy")
 	}
-	defer resp.Body.Close()
-}
 
-func TestPrometheusClient(t *testing.T) {
-	client, mux, _, teardown := setup(nil)
-	defer teardown()
-	mux.HandleFunc("/prometheus/api/v1/", func(w http.ResponseWriter, r *http.Request) {
-		_, err := w.Write([]byte(`{"status":"success","data":{"alerts":[]}}`))
-		if err != nil {
-			t.Errorf("could not write response: %v", err)
+	for _, proxyURL := range []string{"://bad", "proxy.example.com"} {
+		if _, err := NewClient(nil, WithProxy(proxyURL)); err == nil {
+			t.Errorf("WithProxy(%q) did not return an expected error", proxyURL)
 		}
-	})
-	_, err := client.Prometheus.Alerts(context.TODO())
+	}
+	roundTripper := &http.Client{Transport: roundTripperFunc(http.DefaultTransport.RoundTrip)}
+	if _, err := NewClient(nil, WithHTTPClient(roundTripper), WithProxy("http://proxy.example.com")); err == nil {
+		t.Error("WithProxy with a custom RoundTripper did not return an expected error")
+	}
+}
+
+func TestWithInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	var buf bytes.Buffer
+	c, err := NewClient(nil,
+		WithHTTPClient(httpClient),
+		WithInsecureSkipVerify(true),
+		WithLogger(log.New(&buf, "", 0)),
+		WithBaseURL(server.URL+"/"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	transport, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Transport is %T, want *http.Transport", c.httpClient.Transport)
+	}
+	if !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("InsecureSkipVerify = false, want true")
+	}
+	if transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+		t.Error("WithInsecureSkipVerify did not keep the existing TLS config")
+	}
+	if tlsConfig.InsecureSkipVerify {
+		t.Error("WithInsecureSkipVerify modified the provided tls.Config, but should use a copy")
+	}
+	if !strings.Contains(buf.String(), "WARNING") {
+		t.Errorf("expected a warning to be logged, got: %q", buf.String())
+	}
+	req, err := c.NewRequest(http.MethodGet, "foo", nil)
 	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Do(context.Background(), req, nil); err != nil {
+		t.Errorf("request to server with self-signed certificate failed: %v", err)
+	}
+
+	buf.Reset()
+	if _, err := NewClient(nil, WithInsecureSkipVerify(false), WithLogger(log.New(&buf, "", 0))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected warning logged: %q", buf.String())
+	}
+
+	// Without WithInsecureSkipVerify, an insecure transport provided by the caller is not warned about.
+	insecureClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}} //nolint:gosec
+	if _, err := NewClient(nil, WithHTTPClient(insecureClient), WithLogger(log.New(&buf, "", 0))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected warning logged: %q", buf.String())
+	}
+
+	// Without WithLogger, the warning goes to the standard logger.
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+	if _, err := NewClient(nil, WithInsecureSkipVerify(true)); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if !strings.Contains(buf.String(), "WARNING") {
+		t.Errorf("expected a warning to be logged to the standard logger, got: %q", buf.String())
+	}
+
+	roundTripper := &http.Client{Transport: roundTripperFunc(http.DefaultTransport.RoundTrip)}
+	if _, err := NewClient(nil, WithHTTPClient(roundTripper), WithInsecureSkipVerify(true)); err == nil {
+		t.Error("WithInsecureSkipVerify with a custom RoundTripper did not return an expected error")
+	}
 }
 
-func TestBareDo_Zipped(t *testing.T) {
-	client, mux, baseURL, teardown := setup(nil)
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestBareDo_RequestIDHeader(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
 	defer teardown()
+	var buf bytes.Buffer
+	client.SetLogger(log.New(&buf, "", 0))
+	if err := WithRequestIDHeader("X-Request-ID", nil)(client); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
 	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Encoding", "text/plain; gzip; charset=utf8")
-		w.WriteHeader(http.StatusOK)
-		respW := gzip.NewWriter(w)
-		if _, err := respW.Write([]byte("ok")); err != nil {
-			t.Errorf("error writing response: %v", err)
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+	})
+	for i := 0; i < 2; i++ {
+		req, err := client.NewRequest(http.MethodGet, "foo", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Do(context.Background(), req, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(ids) != 2 {
+		t.Fatalf("got %d requests, want 2", len(ids))
+	}
+	for _, id := range ids {
+		if len(id) != 36 {
+			t.Errorf("request ID %q is not a UUID", id)
+		}
+		if !strings.Contains(buf.String(), "[X-Request-ID: "+id+"]") {
+			t.Errorf("request ID %q was not logged with the request", id)
 		}
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("request IDs are not unique: %q", ids[0])
+	}
+
+	if err := WithRequestIDHeader("X-Correlation-ID", func() string { return "fixed" })(client); err != nil {
+		t.Fatal(err)
+	}
+	mux.HandleFunc("/bar", func(w http.ResponseWriter, r *http.Request) {
+		testHeader(t, r, "X-Correlation-ID", "fixed")
 	})
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/foo", nil)
+	req, err := client.NewRequest(http.MethodGet, "bar", nil)
 	if err != nil {
-		t.Fatalf("failed to create request: %v", err)
+		t.Fatal(err)
 	}
-	_, err = client.BareDo(context.Background(), req)
+	if _, err := client.Do(context.Background(), req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	c, err := NewClient(nil)
 	if err != nil {
-		t.Fatalf("failed to baredo: %v", err)
+		t.Fatal(err)
+	}
+	l := log.Default()
+	c.SetLogger(l)
+	if c.logger != l {
+		t.Error("Client returned different logger, but should be same")
 	}
 }
 
-func TestBareDo_AuthenticationError(t *testing.T) {
+func TestAuthentication(t *testing.T) {
 	a, err := accesstoken.Authenticator("foo")
 	if err != nil {
 		t.Fatal(err)
@@ -286,61 +636,456 @@ func TestBareDo_AuthenticationError(t *testing.T) {
 	client, mux, baseURL, teardown := setup(a)
 	defer teardown()
 	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusForbidden)
+		testHeader(t, r, authentication.AuthorizationHeader, authentication.AuthorizationHeaderFor("foo"))
+		w.WriteHeader(http.StatusOK)
 	})
 	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/foo", nil)
 	if err != nil {
 		t.Fatalf("failed to create request: %v", err)
 	}
-	_, err = client.BareDo(context.Background(), req)
-	if err == nil {
-		t.Errorf("did not get expected err")
+	resp, err := client.BareDo(context.Background(), req)
+	if err != nil {
+		t.Fatalf("failed to baredo: %v", err)
 	}
+	defer resp.Body.Close()
 }
 
-type refreshableAuthenticationWrapper struct {
-	authentication.Authenticator
-	Refresher func() error
-}
-
-func (r *refreshableAuthenticationWrapper) Refresh() error {
-	return r.Refresher()
-}
-
-func TestBareDo_AuthenticationRefreshable(t *testing.T) {
-	a, err := accesstoken.Authenticator("foo")
+func TestWithTeamIDContext(t *testing.T) {
+	a, err := accesstoken.Authenticator("foo", accesstoken.WithSysdigTeamID("1"))
 	if err != nil {
 		t.Fatal(err)
 	}
-	hit := 0
-	client, mux, baseURL, teardown := setup(&refreshableAuthenticationWrapper{
-		Authenticator: a,
-		Refresher:     func() error { hit++; return nil },
-	})
+	client, mux, _, teardown := setup(a)
 	defer teardown()
-	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
-		if hit == 0 {
-			w.WriteHeader(http.StatusForbidden)
-		} else {
-			w.WriteHeader(http.StatusOK)
-		}
+	var got []string
+	mux.HandleFunc("/api/token", func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(authentication.SysdigTeamIDHeader))
+		fmt.Fprint(w, `{"token":{"key":"foo"}}`)
+	})
+
+	for _, ctx := range []context.Context{
+		context.Background(),
+		WithTeamIDContext(context.Background(), "2"),
+		WithTeamIDContext(context.Background(), "3"),
+	} {
+		if _, _, err := client.Users.Token(ctx); err != nil {
+			t.Fatalf("Users.Token returned error: %v", err)
+		}
+	}
+	if want := []string{"1", "2", "3"}; !cmp.Equal(got, want) {
+		t.Errorf("got team ID headers %v, want %v", got, want)
+	}
+}
+
+func TestNewRequest_BaseURLPathPrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		path    string
+		want    string
+	}{
+		{
+			name:    "multi-segment prefix",
+			baseURL: "https://sysdig.example.com/monitoring/sysdig/",
+			path:    "api/v3/dashboards",
+			want:    "https://sysdig.example.com/monitoring/sysdig/api/v3/dashboards",
+		},
+		{
+			name:    "multi-segment prefix without trailing slash",
+			baseURL: "https://sysdig.example.com/monitoring/sysdig",
+			path:    "api/v3/dashboards",
+			want:    "https://sysdig.example.com/monitoring/sysdig/api/v3/dashboards",
+		},
+		{
+			name:    "query",
+			baseURL: "https://sysdig.example.com/monitoring/sysdig/",
+			path:    "api/v3/dashboards?teamId=1",
+			want:    "https://sysdig.example.com/monitoring/sysdig/api/v3/dashboards?teamId=1",
+		},
+		{
+			name:    "no prefix",
+			baseURL: "https://sysdig.example.com",
+			path:    "api/v3/dashboards",
+			want:    "https://sysdig.example.com/api/v3/dashboards",
+		},
+		{
+			name:    "leading slash",
+			baseURL: "https://sysdig.example.com/monitoring/sysdig/",
+			path:    "/api/v3/dashboards",
+			want:    "https://sysdig.example.com/monitoring/sysdig/api/v3/dashboards",
+		},
+		{
+			name:    "leading slash without prefix",
+			baseURL: "https://sysdig.example.com/",
+			path:    "/api/v3/dashboards",
+			want:    "https://sysdig.example.com/api/v3/dashboards",
+		},
+		{
+			name:    "leading double slash",
+			baseURL: "https://sysdig.example.com/monitoring/sysdig/",
+			path:    "//api/v3/dashboards",
+			want:    "https://sysdig.example.com/monitoring/sysdig/api/v3/dashboards",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewClient(nil, WithBaseURL(test.baseURL))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			req, err := c.NewRequest(http.MethodGet, test.path, nil)
+			if err != nil {
+				t.Fatalf("NewRequest returned error: %v", err)
+			}
+			if got := req.URL.String(); got != test.want {
+				t.Errorf("NewRequest URL = %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	c, err := NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.BaseURL, _ = url.Parse("https://sysdig.example.com/monitoring/sysdig")
+	if _, err := c.NewRequest(http.MethodGet, "api/v3/dashboards", nil); err == nil || !strings.Contains(err.Error(), "trailing slash") {
+		t.Errorf("NewRequest with a BaseURL without a trailing slash returned error %v, want a trailing slash error", err)
+	}
+}
+
+func TestBareDo_BaseURLPathPrefix(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/monitoring/sysdig/api/v3/dashboards", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"dashboards":[{"id":1}]}`)
+	})
+	mux.HandleFunc("/monitoring/sysdig/prometheus/api/v1/alerts", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"success","data":{"alerts":[]}}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c, err := NewClient(nil, WithBaseURL(server.URL+"/monitoring/sysdig"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dashboards, _, err := c.Dashboards.List(context.Background())
+	if err != nil {
+		t.Fatalf("Dashboards.List returned error: %v", err)
+	}
+	if want := []Dashboard{{ID: 1}}; !cmp.Equal(dashboards.Dashboards, want) {
+		t.Errorf("Dashboards.List returned %+v, want %+v", dashboards.Dashboards, want)
+	}
+	if _, err := c.Prometheus.Alerts(context.Background()); err != nil {
+		t.Errorf("Prometheus.Alerts returned error: %v", err)
+	}
+}
+
+func TestClientResponses(t *testing.T) {
+	client, mux, baseURL, teardown := setup(nil)
+	defer teardown()
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
 	})
 	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/foo", nil)
 	if err != nil {
 		t.Fatalf("failed to create request: %v", err)
 	}
-	_, err = client.BareDo(context.Background(), req)
+	resp, err := client.BareDo(context.Background(), req)
 	if err != nil {
-		t.Errorf("got unexpected error: %v", err)
+		t.Fatalf("failed to baredo: %v", err)
 	}
-	if hit == 0 {
-		t.Errorf("did not run refresher")
+	defer resp.Body.Close()
+}
+
+func TestPrometheusClient(t *testing.T) {
+	a, err := accesstoken.Authenticator("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, mux, _, teardown := setup(a)
+	defer teardown()
+	if client.Prometheus == nil {
+		t.Fatal("NewClient did not set Prometheus")
+	}
+	// Prometheus requests are sent through the Sysdig client, so they are authenticated like any other request.
+	mux.HandleFunc("/prometheus/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		testHeader(t, r, authentication.AuthorizationHeader, authentication.AuthorizationHeaderFor("foo"))
+		_, err := w.Write([]byte(`{"status":"success","data":{"alerts":[]}}`))
+		if err != nil {
+			t.Errorf("could not write response: %v", err)
+		}
+	})
+	_, err = client.Prometheus.Alerts(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
 }
 
-func TestBareDo_DoError(t *testing.T) {
+func TestPrometheusClient_LabelsAndSeries(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	// Sysdig only accepts GET, so the Prometheus client must fall back from POST.
+	handle := func(path, response string) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				fmt.Fprint(w, `{"message":"Method Not Allowed"}`)
+				return
+			}
+			testMethod(t, r, http.MethodGet)
+			fmt.Fprint(w, response)
+		})
+	}
+	handle("/prometheus/api/v1/labels", `{"status":"success","data":["__name__","kube_namespace_name"]}`)
+	handle("/prometheus/api/v1/label/kube_namespace_name/values", `{"status":"success","data":["default","kube-system"]}`)
+	handle("/prometheus/api/v1/series",
+		`{"status":"success","data":[{"__name__":"up","kube_namespace_name":"default"}]}`)
+
+	ctx := context.Background()
+	start, end := time.Unix(0, 0), time.Unix(60, 0)
+	names, _, err := client.Prometheus.LabelNames(ctx, nil, start, end)
+	if err != nil {
+		t.Fatalf("LabelNames returned error: %v", err)
+	}
+	if want := []string{"__name__", "kube_namespace_name"}; !cmp.Equal(names, want) {
+		t.Errorf("LabelNames returned %v, want %v", names, want)
+	}
+
+	values, _, err := client.Prometheus.LabelValues(ctx, "kube_namespace_name", nil, start, end)
+	if err != nil {
+		t.Fatalf("LabelValues returned error: %v", err)
+	}
+	if want := (model.LabelValues{"default", "kube-system"}); !cmp.Equal(values, want) {
+		t.Errorf("LabelValues returned %v, want %v", values, want)
+	}
+
+	series, _, err := client.Prometheus.Series(ctx, []string{"up"}, start, end)
+	if err != nil {
+		t.Fatalf("Series returned error: %v", err)
+	}
+	if want := []model.LabelSet{{"__name__": "up", "kube_namespace_name": "default"}}; !cmp.Equal(series, want) {
+		t.Errorf("Series returned %v, want %v", series, want)
+	}
+}
+
+func TestPrometheusClient_Metadata(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	mux.HandleFunc("/prometheus/api/v1/metadata", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		testFormValues(t, r, values{"metric": "sysdig_container_cpu_used_percent", "limit": "1"})
+		fmt.Fprint(w, `{"status":"success","data":{"sysdig_container_cpu_used_percent":[`+
+			`{"type":"gauge","help":"The CPU usage of the container.","unit":"percent"}]}}`)
+	})
+
+	metadata, err := client.Prometheus.Metadata(context.Background(), "sysdig_container_cpu_used_percent", "1")
+	if err != nil {
+		t.Fatalf("Metadata returned error: %v", err)
+	}
+	want := map[string][]v1.Metadata{
+		"sysdig_container_cpu_used_percent": {
+			{Type: v1.MetricTypeGauge, Help: "The CPU usage of the container.", Unit: "percent"},
+		},
+	}
+	if !cmp.Equal(metadata, want) {
+		t.Errorf("Metadata returned %v, want %v", metadata, want)
+	}
+}
+
+func TestPrometheusClient_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		wantType v1.ErrorType
+		wantMsg  string
+	}{
+		{
+			name:     "sysdig error",
+			status:   http.StatusBadRequest,
+			response: `{"message":"Bad Request","errors":[{"message":"invalid query","reason":"unexpected end of input"}]}`,
+			wantType: v1.ErrClient,
+			wantMsg:  "400 Bad Request; invalid query: unexpected end of input",
+		},
+		{
+			name:     "prometheus error",
+			status:   http.StatusBadRequest,
+			response: `{"status":"error","errorType":"bad_data","error":"parse error at char 4"}`,
+			wantType: v1.ErrBadData,
+			wantMsg:  "parse error at char 4",
+		},
+		{
+			name:     "server error without body",
+			status:   http.StatusInternalServerError,
+			wantType: v1.ErrServer,
+			wantMsg:  "500 Internal Server Error",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, mux, _, teardown := setup(nil)
+			defer teardown()
+			mux.HandleFunc("/prometheus/api/v1/query", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, test.response)
+			})
+			_, _, err := client.Prometheus.Query(context.Background(), "up{", time.Unix(0, 0))
+			var promErr *v1.Error
+			if !errors.As(err, &promErr) {
+				t.Fatalf("Query returned error %v (%T), want *v1.Error", err, err)
+			}
+			if promErr.Type != test.wantType {
+				t.Errorf("error type = %q, want %q", promErr.Type, test.wantType)
+			}
+			if promErr.Msg != test.wantMsg {
+				t.Errorf("error message = %q, want %q", promErr.Msg, test.wantMsg)
+			}
+			if promErr.Detail != test.response {
+				t.Errorf("error detail = %q, want %q", promErr.Detail, test.response)
+			}
+		})
+	}
+}
+
+func TestBareDo_Zipped(t *testing.T) {
+	client, mux, baseURL, teardown := setup(nil)
+	defer teardown()
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusOK)
+		respW := gzip.NewWriter(w)
+		if _, err := respW.Write([]byte("ok")); err != nil {
+			t.Errorf("error writing response: %v", err)
+		}
+		if err := respW.Close(); err != nil {
+			t.Errorf("error closing gzip writer: %v", err)
+		}
+	})
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/foo", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err := client.BareDo(context.Background(), req)
+	if err != nil {
+		t.Fatalf("failed to baredo: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if string(data) != "ok" {
+		t.Errorf("body = %q, want %q", data, "ok")
+	}
+}
+
+func TestGzipped(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentEncoding []string
+		want            bool
+	}{
+		{name: "none", want: false},
+		{name: "gzip", contentEncoding: []string{"gzip"}, want: true},
+		{name: "x-gzip", contentEncoding: []string{"x-gzip"}, want: true},
+		{name: "case insensitive", contentEncoding: []string{"GZip"}, want: true},
+		{name: "multiple codings", contentEncoding: []string{"deflate, gzip"}, want: true},
+		{name: "multiple headers", contentEncoding: []string{"deflate", "gzip"}, want: true},
+		{name: "substring", contentEncoding: []string{"text/plain; gzip; charset=utf8"}, want: false},
+		{name: "other coding", contentEncoding: []string{"gzipped"}, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range test.contentEncoding {
+				h.Add("Content-Encoding", v)
+			}
+			if got := gzipped(h); got != test.want {
+				t.Errorf("gzipped(%v) = %t, want %t", test.contentEncoding, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBareDo_ContentEncodingSubstring(t *testing.T) {
 	client, mux, baseURL, teardown := setup(nil)
 	defer teardown()
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "text/plain; gzip; charset=utf8")
+		fmt.Fprint(w, "ok")
+	})
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/foo", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err := client.BareDo(context.Background(), req)
+	if err != nil {
+		t.Fatalf("failed to baredo: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if string(data) != "ok" {
+		t.Errorf("body = %q, want %q", data, "ok")
+	}
+}
+
+func TestNewRequest_Compression(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	if err := WithRequestCompression(true)(client); err != nil {
+		t.Fatal(err)
+	}
+	dashboard := NewDashboard("large")
+	dashboard.Description = strings.Repeat("a large dashboard description ", 100)
+	mux.HandleFunc("/api/v3/dashboards", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		if got := r.Header.Get("Content-Encoding"); got != "gzip" {
+			t.Errorf("Content-Encoding = %q, want %q", got, "gzip")
+		}
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatalf("failed to create gzip reader: %v", err)
+		}
+		defer gr.Close()
+		var got DashboardResponse
+		if err := json.NewDecoder(gr).Decode(&got); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if got.Dashboard.Description != dashboard.Description {
+			t.Errorf("decoded description = %q, want %q", got.Dashboard.Description, dashboard.Description)
+		}
+		fmt.Fprint(w, `{"dashboard":{"id":1}}`)
+	})
+	if _, _, err := client.Dashboards.Create(context.Background(), *dashboard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	small, err := client.NewRequest(http.MethodPost, "api/foo", map[string]string{"foo": "bar"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := small.Header.Get("Content-Encoding"); got != "" {
+		t.Errorf("small body Content-Encoding = %q, want none", got)
+	}
+	empty, err := client.NewRequest(http.MethodGet, "api/foo", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := empty.Header.Get("Content-Encoding"); got != "" {
+		t.Errorf("empty body Content-Encoding = %q, want none", got)
+	}
+}
+
+func TestBareDo_AuthenticationError(t *testing.T) {
+	a, err := accesstoken.Authenticator("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, mux, baseURL, teardown := setup(a)
+	defer teardown()
 	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusForbidden)
 	})
@@ -348,9 +1093,1223 @@ func TestBareDo_DoError(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create request: %v", err)
 	}
-	req.URL = nil
 	_, err = client.BareDo(context.Background(), req)
 	if err == nil {
 		t.Errorf("did not get expected err")
 	}
 }
+
+type refreshableAuthenticationWrapper struct {
+	authentication.Authenticator
+	Refresher func() error
+}
+
+func (r *refreshableAuthenticationWrapper) Refresh() error {
+	return r.Refresher()
+}
+
+func TestBareDo_AuthenticationRefreshable(t *testing.T) {
+	a, err := accesstoken.Authenticator("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hit := 0
+	client, mux, baseURL, teardown := setup(&refreshableAuthenticationWrapper{
+		Authenticator: a,
+		Refresher:     func() error { hit++; return nil },
+	})
+	defer teardown()
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		if hit == 0 {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/foo", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	_, err = client.BareDo(context.Background(), req)
+	if err != nil {
+		t.Errorf("got unexpected error: %v", err)
+	}
+	if hit == 0 {
+		t.Errorf("did not run refresher")
+	}
+}
+
+type contextRefreshableAuthenticationWrapper struct {
+	refreshableAuthenticationWrapper
+	ContextRefresher func(ctx context.Context) error
+}
+
+func (r *contextRefreshableAuthenticationWrapper) RefreshContext(ctx context.Context) error {
+	return r.ContextRefresher(ctx)
+}
+
+func TestBareDo_AuthenticationContextRefreshable(t *testing.T) {
+	a, err := accesstoken.Authenticator("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type ctxKey struct{}
+	var gotValue interface{}
+	var gotFailed string
+	client, mux, baseURL, teardown := setup(&contextRefreshableAuthenticationWrapper{
+		refreshableAuthenticationWrapper: refreshableAuthenticationWrapper{
+			Authenticator: a,
+			Refresher:     func() error { t.Error("Refresh called instead of RefreshContext"); return nil },
+		},
+		ContextRefresher: func(ctx context.Context) error {
+			gotValue = ctx.Value(ctxKey{})
+			gotFailed, _ = authentication.FailedAuthorization(ctx)
+			return nil
+		},
+	})
+	defer teardown()
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		if gotValue == nil {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/foo", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	_, err = client.BareDo(context.WithValue(context.Background(), ctxKey{}, "bar"), req)
+	if err != nil {
+		t.Errorf("got unexpected error: %v", err)
+	}
+	if gotValue != "bar" {
+		t.Errorf("RefreshContext got context value %v, want %v", gotValue, "bar")
+	}
+	if want := authentication.AuthorizationHeaderFor("foo"); gotFailed != want {
+		t.Errorf("RefreshContext got failed authorization %q, want %q", gotFailed, want)
+	}
+}
+
+func TestDo_AuthenticationRefreshUsesContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ClientOption
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{
+			name:    "canceled",
+			ctx:     func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			wantErr: context.Canceled,
+		},
+		{
+			name:    "default request timeout",
+			options: []ClientOption{WithDefaultRequestTimeout(50 * time.Millisecond)},
+			ctx:     func() (context.Context, context.CancelFunc) { return context.Background(), func() {} },
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			started := make(chan struct{}, 1)
+			done := make(chan struct{})
+			iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				started <- struct{}{}
+				select {
+				case <-r.Context().Done():
+				case <-done:
+				}
+			}))
+			defer iam.Close()
+			defer close(done)
+			// A new authenticator has no token, so the first request refreshes it.
+			a, err := ibmiam.Authenticator("foo",
+				ibmiam.WithIAMEndpoint(iam.URL),
+				ibmiam.WithHTTPClient(iam.Client()),
+				ibmiam.WithRefreshTimeout(0),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, mux, _, teardown := setup(a)
+			defer teardown()
+			for _, option := range test.options {
+				if err := option(client); err != nil {
+					t.Fatal(err)
+				}
+			}
+			mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+				t.Error("request sent without authenticating")
+			})
+			req, err := client.NewRequest(http.MethodGet, "foo", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := test.ctx()
+			defer cancel()
+			errc := make(chan error, 1)
+			go func() {
+				_, err := client.Do(ctx, req, nil)
+				errc <- err
+			}()
+			<-started
+			cancel()
+			select {
+			case err := <-errc:
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("got error: %v, want: %v", err, test.wantErr)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("token refresh was not aborted with the request")
+			}
+		})
+	}
+}
+
+func TestWithAuthRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		authRetry     bool
+		status        int
+		wantErr       bool
+		wantRefreshes int
+		wantRequests  int
+	}{
+		{
+			name:          "enabled",
+			authRetry:     true,
+			status:        http.StatusOK,
+			wantRefreshes: 1,
+			wantRequests:  2,
+		},
+		{
+			name:          "enabled retries once",
+			authRetry:     true,
+			status:        http.StatusForbidden,
+			wantErr:       true,
+			wantRefreshes: 1,
+			wantRequests:  2,
+		},
+		{
+			name:         "disabled",
+			authRetry:    false,
+			status:       http.StatusOK,
+			wantErr:      true,
+			wantRequests: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := accesstoken.Authenticator("foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			refreshes, requests := 0, 0
+			client, mux, _, teardown := setup(&refreshableAuthenticationWrapper{
+				Authenticator: a,
+				Refresher:     func() error { refreshes++; return nil },
+			})
+			defer teardown()
+			if err := WithAuthRetry(test.authRetry)(client); err != nil {
+				t.Fatal(err)
+			}
+			mux.HandleFunc("/api/token", func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if refreshes == 0 {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, `{"token":{"key":"foo"}}`)
+			})
+
+			_, resp, err := client.Users.Token(context.Background())
+			if test.wantErr != (err != nil) {
+				t.Fatalf("Users.Token returned error: %v, want error: %v", err, test.wantErr)
+			}
+			if test.wantErr && !isAuthenticationError(resp) {
+				t.Errorf("Users.Token returned response %v, want the authentication error response", resp)
+			}
+			if refreshes != test.wantRefreshes {
+				t.Errorf("got %d refreshes, want %d", refreshes, test.wantRefreshes)
+			}
+			if requests != test.wantRequests {
+				t.Errorf("got %d requests, want %d", requests, test.wantRequests)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeys_Retry(t *testing.T) {
+	a, err := accesstoken.Authenticator("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed := false
+	client, mux, _, teardown := setup(&refreshableAuthenticationWrapper{
+		Authenticator: a,
+		Refresher:     func() error { refreshed = true; return nil },
+	})
+	defer teardown()
+	if err := WithIdempotencyKeys(true)(client); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		testBody(t, r, `{"event":{"name":"deploy"}}`+"\n")
+		if !refreshed {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"event":{"id":"1"}}`)
+	})
+
+	if _, _, err := client.Events.Create(context.Background(), EventOptions{Name: "deploy"}); err != nil {
+		t.Fatalf("Events.Create returned error: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("got %d requests, want 2", len(keys))
+	}
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("got idempotency keys %q, want the same non-empty key for the retried create", keys)
+	}
+
+	// A new create gets a new key.
+	if _, _, err := client.Events.Create(context.Background(), EventOptions{Name: "deploy"}); err != nil {
+		t.Fatalf("Events.Create returned error: %v", err)
+	}
+	if keys[2] == keys[0] {
+		t.Errorf("got the same idempotency key %q for different creates", keys[2])
+	}
+}
+
+func TestCreateWithKey(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	const key = "my-key"
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, http.MethodPost)
+			testHeader(t, r, IdempotencyKeyHeader, key)
+			fmt.Fprint(w, body)
+		}
+	}
+	mux.HandleFunc("/api/v2/events", handler(`{"event":{"id":"1"}}`))
+	mux.HandleFunc("/api/v3/dashboards", handler(`{"dashboard":{"id":1}}`))
+	mux.HandleFunc("/api/notificationChannels", handler(`{"notificationChannel":{"id":"1"}}`))
+	mux.HandleFunc("/api/sysdig/captures", handler(`{"capture":{"id":1}}`))
+
+	tests := []struct {
+		name   string
+		create func(key string) error
+	}{
+		{
+			name: "Events",
+			create: func(key string) error {
+				_, _, err := client.Events.CreateWithKey(context.Background(), EventOptions{Name: "deploy"}, key)
+				return err
+			},
+		},
+		{
+			name: "Dashboards",
+			create: func(key string) error {
+				_, _, err := client.Dashboards.CreateWithKey(context.Background(), *NewDashboard("test"), key)
+				return err
+			},
+		},
+		{
+			name: "NotificationChannels",
+			create: func(key string) error {
+				_, _, err := client.NotificationChannels.CreateWithKey(
+					context.Background(), NotificationChannelTypeEmail, "test", NotificationChannelOptions{}, key)
+				return err
+			},
+		},
+		{
+			name: "Captures",
+			create: func(key string) error {
+				_, _, err := client.Captures.CreateWithKey(context.Background(), CaptureOptions{}, key)
+				return err
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.create(key); err != nil {
+				t.Errorf("%s.CreateWithKey returned error: %v", test.name, err)
+			}
+			if err := test.create(""); err == nil {
+				t.Errorf("%s.CreateWithKey with a blank key did not return an expected error", test.name)
+			}
+		})
+	}
+}
+
+func TestBareDo_DoError(t *testing.T) {
+	client, mux, baseURL, teardown := setup(nil)
+	defer teardown()
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/foo", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.URL = nil
+	_, err = client.BareDo(context.Background(), req)
+	if err == nil {
+		t.Errorf("did not get expected err")
+	}
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantErr  bool
+		wantAuth bool
+	}{
+		{name: "success", status: http.StatusOK},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantErr: true, wantAuth: true},
+		{name: "forbidden", status: http.StatusForbidden, wantErr: true, wantAuth: true},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, mux, _, teardown := setup(nil)
+			defer teardown()
+			mux.HandleFunc("/api/token", func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, `{"token":{"key":"foo"}}`)
+			})
+			err := client.Ping(context.Background())
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Ping returned error: %v, want error: %v", err, test.wantErr)
+			}
+			if got := errors.Is(err, ErrAuthentication); got != test.wantAuth {
+				t.Errorf("errors.Is(%v, ErrAuthentication) = %v, want %v", err, got, test.wantAuth)
+			}
+			if test.wantErr && !hasStatusCode(err, test.status) {
+				t.Errorf("Ping returned error: %v, want an *ErrorResponse with status %d", err, test.status)
+			}
+		})
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	body := `{"infrastructure":{"onPremOverview":{"latestVersion":"5.1.0","customerVersion":"4.2.1"}}}`
+	mux.HandleFunc("/api/team/infrastructure", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, body)
+	})
+	got, err := client.ServerVersion(context.Background())
+	if err != nil {
+		t.Fatalf("ServerVersion returned error: %v", err)
+	}
+	if want := "4.2.1"; got != want {
+		t.Errorf("ServerVersion returned %q, want %q", got, want)
+	}
+
+	body = `{"infrastructure":{"onPremOverview":{}}}`
+	if _, err := client.ServerVersion(context.Background()); !errors.Is(err, ErrServerVersionUnknown) {
+		t.Errorf("ServerVersion without a version returned error: %v, want: %v", err, ErrServerVersionUnknown)
+	}
+
+	client.BaseURL.Path = ""
+	if _, err := client.ServerVersion(context.Background()); err == nil {
+		t.Error("ServerVersion with bad BaseURL returned nil error")
+	}
+}
+
+func TestSupportsPrometheus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "supported",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+			},
+			want: true,
+		},
+		{
+			name: "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			want: false,
+		},
+		{
+			name: "not prometheus",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `<html></html>`)
+			},
+			want: false,
+		},
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			want:    false,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, mux, _, teardown := setup(nil)
+			defer teardown()
+			mux.HandleFunc("/prometheus/api/v1/query", test.handler)
+			got, err := client.SupportsPrometheus(context.Background())
+			if (err != nil) != test.wantErr {
+				t.Errorf("SupportsPrometheus returned err: %v, want err: %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("SupportsPrometheus returned %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBareDo_DebugRedaction(t *testing.T) {
+	const secret = "supersecret"
+	a, err := accesstoken.Authenticator(secret, accesstoken.WithIBMInstanceID(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, mux, _, teardown := setup(a)
+	defer teardown()
+	var buf bytes.Buffer
+	client.SetLogger(log.New(&buf, "", 0))
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"user":{"accessKey":%q,"name":"bar"}}`, secret)
+	})
+	req, err := client.NewRequest(http.MethodPost, "foo", map[string]string{"apiKey": secret, "serviceKey": secret})
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if _, err := client.Do(context.Background(), req, nil); err != nil {
+		t.Fatalf("failed to do: %v", err)
+	}
+	got := buf.String()
+	if strings.Contains(got, secret) {
+		t.Errorf("debug log contains secret:\n%s", got)
+	}
+	for _, want := range []string{"Authorization: ***", "Ibminstanceid: ***", `"apiKey":"***"`, `"accessKey":"***"`, `"name":"bar"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("debug log missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestBareDo_DebugBodyLimit(t *testing.T) {
+	const limit = 64
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	if err := WithDebugBodyLimit(limit)(client); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	client.SetLogger(log.New(&buf, "", 0))
+	items := strings.Repeat(`"x",`, 1<<16)
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"items":[%s"last"]}`, items)
+	})
+	req, err := client.NewRequest(http.MethodGet, "foo", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	var got struct {
+		Items []string `json:"items"`
+	}
+	if _, err := client.Do(context.Background(), req, &got); err != nil {
+		t.Fatalf("failed to do: %v", err)
+	}
+	if want := 1<<16 + 1; len(got.Items) != want || got.Items[want-1] != "last" {
+		t.Errorf("got %d decoded items, want %d", len(got.Items), want)
+	}
+	logged := buf.String()
+	if want := fmt.Sprintf("<body truncated after %d bytes>", limit); !strings.Contains(logged, want) {
+		t.Errorf("debug log missing %q:\n%s", want, logged)
+	}
+	if strings.Contains(logged, "last") || len(logged) > 1024 {
+		t.Errorf("debug log is not truncated:\n%s", logged)
+	}
+}
+
+func TestDebugBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int64
+		body  string
+		want  string
+	}{
+		{name: "under limit", limit: 32, body: `{"name":"bar"}`, want: `{"name":"bar"}`},
+		{name: "no limit", body: `{"name":"bar"}`, want: `{"name":"bar"}`},
+		{
+			name:  "truncated",
+			limit: 8,
+			body:  `{"name":"bar"}`,
+			want:  "{\"name\":\n<body truncated after 8 bytes>",
+		},
+		{
+			name:  "truncated secret",
+			limit: 16,
+			body:  `{"token":"supersecret"}`,
+			want:  "{\"token\":\"***\n<body truncated after 16 bytes>",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Client{debugBodyLimit: test.limit}
+			if got := c.debugBody([]byte(test.body)); got != test.want {
+				t.Errorf("debugBody returned %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStripServerManaged(t *testing.T) {
+	type managed struct {
+		ID         int        `json:"id" sysdig:"server-managed"`
+		Version    int        `json:"version" sysdig:"server-managed"`
+		CreatedOn  *MilliTime `json:"createdOn" sysdig:"other,server-managed"`
+		Name       string     `json:"name"`
+		Tags       []string   `json:"tags" sysdig:"other"`
+		unexported int        `sysdig:"server-managed"`
+	}
+	now := NewMilliTime(time.Now())
+	v := managed{ID: 1, Version: 2, CreatedOn: &now, Name: "test", Tags: []string{"a"}, unexported: 3}
+	stripServerManaged(&v)
+	want := managed{Name: "test", Tags: []string{"a"}, unexported: 3}
+	if !cmp.Equal(v, want, cmp.AllowUnexported(managed{})) {
+		t.Errorf("stripServerManaged returned %+v, want %+v", v, want)
+	}
+
+	// Non-pointers and non-structs are ignored.
+	stripServerManaged(v)
+	stripServerManaged(nil)
+	var nilDashboard *Dashboard
+	stripServerManaged(nilDashboard)
+	i := 1
+	stripServerManaged(&i)
+
+	dashboard := Dashboard{ID: 1, Version: 2, CreatedOn: now, ModifiedOn: now, Name: "test", TeamID: 3}
+	stripServerManaged(&dashboard)
+	if want := (Dashboard{Name: "test", TeamID: 3}); !cmp.Equal(dashboard, want) {
+		t.Errorf("stripServerManaged returned %+v, want %+v", dashboard, want)
+	}
+
+	dashboard = Dashboard{ID: 1, Version: 2, Name: "test", TeamID: 3, UserID: 4, PublicToken: "token", Public: true}
+	stripTagged(&dashboard, serverManagedTag, environmentTag)
+	if want := (Dashboard{Name: "test", Public: true}); !cmp.Equal(dashboard, want) {
+		t.Errorf("stripTagged returned %+v, want %+v", dashboard, want)
+	}
+	wantFields := []string{"id", "teamId", "userId", "version", "createdOn", "modifiedOn", "publicToken"}
+	if got := taggedJSONFields(reflect.TypeOf(Dashboard{}), serverManagedTag, environmentTag); !cmp.Equal(got, wantFields) {
+		t.Errorf("taggedJSONFields returned %v, want %v", got, wantFields)
+	}
+}
+
+func TestBareDo_DefaultRequestTimeout(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	if err := WithDefaultRequestTimeout(20 * time.Millisecond)(client); err != nil {
+		t.Fatal(err)
+	}
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok":true}`)
+	})
+
+	req, err := client.NewRequest(http.MethodGet, "slow", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	start := time.Now()
+	_, err = client.Do(context.Background(), req, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do returned error: %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do took %v, want it cut off after the default request timeout", elapsed)
+	}
+
+	// A deadline on the incoming context takes precedence over the default timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err = client.NewRequest(http.MethodGet, "fast", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	var v struct {
+		OK bool `json:"ok"`
+	}
+	if _, err := client.Do(ctx, req, &v); err != nil {
+		t.Errorf("Do returned error: %v", err)
+	}
+
+	// The response body remains readable after BareDo returns and until it is closed.
+	req, err = client.NewRequest(http.MethodGet, "fast", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err := client.BareDo(context.Background(), req)
+	if err != nil {
+		t.Fatalf("BareDo returned error: %v", err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Errorf("failed to read body: %v", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("failed to close body: %v", err)
+	}
+	if want := `{"ok":true}`; string(b) != want {
+		t.Errorf("got body: %s, want: %s", b, want)
+	}
+}
+
+func TestPointerHelpers(t *testing.T) {
+	if got := *Bool(true); got != true {
+		t.Errorf("Bool(true) = %v, want true", got)
+	}
+	if got := *Int(42); got != 42 {
+		t.Errorf("Int(42) = %v, want 42", got)
+	}
+	if got := *Float64(4.2); got != 4.2 {
+		t.Errorf("Float64(4.2) = %v, want 4.2", got)
+	}
+	if got := *String("foo"); got != "foo" {
+		t.Errorf("String(\"foo\") = %v, want foo", got)
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	longBody := strings.Repeat("é", maxErrorBodyMessageLength)
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        *ErrorResponse
+	}{
+		{
+			name:        "json error",
+			status:      http.StatusBadRequest,
+			contentType: "application/json",
+			body:        `{"message":"bad request","errors":[{"message":"invalid field","reason":"name"}]}`,
+			want: &ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Message:    "bad request",
+				Errors:     []Error{{Message: "invalid field", Reason: "name"}},
+			},
+		},
+		{
+			name:        "html error",
+			status:      http.StatusBadGateway,
+			contentType: "text/html",
+			body:        "<html><body><h1>502 Bad Gateway</h1></body></html>\n",
+			want:        &ErrorResponse{StatusCode: http.StatusBadGateway, Message: "<html><body><h1>502 Bad Gateway</h1></body></html>"},
+		},
+		{
+			name:        "truncated body",
+			status:      http.StatusServiceUnavailable,
+			contentType: "text/plain",
+			body:        longBody,
+			want: &ErrorResponse{
+				StatusCode: http.StatusServiceUnavailable,
+				Message:    longBody[:maxErrorBodyMessageLength] + "...",
+			},
+		},
+		{
+			name:   "empty body",
+			status: http.StatusNotFound,
+			want:   &ErrorResponse{StatusCode: http.StatusNotFound},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, _ := NewClient(nil)
+			resp := &http.Response{
+				StatusCode: test.status,
+				Header:     http.Header{"Content-Type": []string{test.contentType}},
+				Body:       io.NopCloser(strings.NewReader(test.body)),
+			}
+			err := client.CheckResponse(resp)
+			errorResponse, ok := err.(*ErrorResponse)
+			if !ok {
+				t.Fatalf("got error: %v, want *ErrorResponse", err)
+			}
+			if errorResponse.Response != resp {
+				t.Errorf("got response: %v, want: %v", errorResponse.Response, resp)
+			}
+			errorResponse.Response = nil
+			if diff := cmp.Diff(test.want, errorResponse); diff != "" {
+				t.Errorf("CheckResponse mismatch (-want +got):\n%s", diff)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != test.body {
+				t.Errorf("got response body: %q, want: %q", body, test.body)
+			}
+		})
+	}
+}
+
+func TestErrorPredicates(t *testing.T) {
+	errorFor := func(statusCode int) error {
+		return fmt.Errorf("wrapped: %w", &ErrorResponse{StatusCode: statusCode})
+	}
+	tests := []struct {
+		name      string
+		predicate func(error) bool
+		match     int
+	}{
+		{name: "IsNotFound", predicate: IsNotFound, match: http.StatusNotFound},
+		{name: "IsUnauthorized", predicate: IsUnauthorized, match: http.StatusUnauthorized},
+		{name: "IsConflict", predicate: IsConflict, match: http.StatusConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !test.predicate(errorFor(test.match)) {
+				t.Errorf("%s(%d) = false, want true", test.name, test.match)
+			}
+			if test.predicate(errorFor(http.StatusInternalServerError)) {
+				t.Errorf("%s(%d) = true, want false", test.name, http.StatusInternalServerError)
+			}
+			if test.predicate(errors.New("not an error response")) {
+				t.Errorf("%s(non-ErrorResponse) = true, want false", test.name)
+			}
+			if test.predicate(nil) {
+				t.Errorf("%s(nil) = true, want false", test.name)
+			}
+		})
+	}
+}
+
+func TestRaw(t *testing.T) {
+	methodName := "Raw"
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+
+	type policy struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	mux.HandleFunc("/api/policies", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			testFormValues(t, r, values{"limit": "1"})
+			fmt.Fprint(w, `[{"id":1,"name":"existing"}]`)
+		case http.MethodPost:
+			testBody(t, r, `{"id":0,"name":"new"}`+"\n")
+			fmt.Fprint(w, `{"id":2,"name":"new"}`)
+		default:
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+	})
+
+	var policies []policy
+	if _, err := client.Raw(context.Background(), http.MethodGet, "api/policies?limit=1", nil, &policies); err != nil {
+		t.Fatalf("Raw GET returned error: %v", err)
+	}
+	if want := []policy{{ID: 1, Name: "existing"}}; !cmp.Equal(policies, want) {
+		t.Errorf("Raw GET decoded %+v, want %+v", policies, want)
+	}
+
+	var created policy
+	if _, err := client.Raw(context.Background(), http.MethodPost, "api/policies", policy{Name: "new"}, &created); err != nil {
+		t.Fatalf("Raw POST returned error: %v", err)
+	}
+	if want := (policy{ID: 2, Name: "new"}); created != want {
+		t.Errorf("Raw POST decoded %+v, want %+v", created, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		return client.Raw(context.Background(), http.MethodGet, "api/policies", nil, nil)
+	})
+}
+
+func TestWithStrictDecoding(t *testing.T) {
+	body := `{"user":{"id":1,"extra":true,"teamRoles":[{"teamId":2,"unknownRole":"x"}],"lastUpdated":1}}`
+	tests := []struct {
+		name       string
+		strict     bool
+		body       string
+		wantFields []string
+	}{
+		{
+			name: "lenient",
+			body: body,
+		},
+		{
+			name:       "strict",
+			strict:     true,
+			body:       body,
+			wantFields: []string{"user.extra", "user.teamRoles[0].unknownRole"},
+		},
+		{
+			name:   "strict known fields",
+			strict: true,
+			body:   `{"user":{"id":1,"teamRoles":[{"teamId":2}]}}`,
+		},
+		{
+			name:   "strict case-insensitive match",
+			strict: true,
+			body:   `{"User":{"ID":1}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, mux, _, teardown := setup(nil)
+			defer teardown()
+			if err := WithStrictDecoding(test.strict)(client); err != nil {
+				t.Fatal(err)
+			}
+			mux.HandleFunc("/api/user/me", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, test.body)
+			})
+
+			me, _, err := client.Users.Me(context.Background())
+			if test.wantFields == nil {
+				if err != nil {
+					t.Fatalf("Users.Me returned error: %v", err)
+				}
+			} else {
+				var unknown *UnknownFieldsError
+				if !errors.As(err, &unknown) {
+					t.Fatalf("Users.Me returned error %v, want an *UnknownFieldsError", err)
+				}
+				if !cmp.Equal(unknown.Fields, test.wantFields) {
+					t.Errorf("got unknown fields %v, want %v", unknown.Fields, test.wantFields)
+				}
+			}
+			if me.User.ID != 1 {
+				t.Errorf("Users.Me decoded %+v, want user 1", me.User)
+			}
+		})
+	}
+}
+
+// strictSelfDecoding decodes any JSON value itself.
+type strictSelfDecoding struct{}
+
+func (*strictSelfDecoding) UnmarshalJSON([]byte) error { return nil }
+
+type strictEmbeddedID struct {
+	ID int
+}
+
+type strictEmbeddedOtherID struct {
+	ID int
+}
+
+type strictEmbeddedMeta struct {
+	Meta struct {
+		A int `json:"a"`
+	} `json:"meta"`
+	Name string `json:"name"`
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name       string
+		v          interface{}
+		body       string
+		wantFields []string
+	}{
+		{
+			name: "embedded struct",
+			v: &struct {
+				strictEmbeddedMeta
+			}{},
+			body:       `{"name":"x","meta":{"a":1,"b":2}}`,
+			wantFields: []string{"meta.b"},
+		},
+		{
+			name: "outer field shadows embedded field",
+			v: &struct {
+				strictEmbeddedMeta
+				Meta map[string]int `json:"meta"`
+			}{},
+			body: `{"name":"x","meta":{"b":2}}`,
+		},
+		{
+			name: "ambiguous embedded fields are ignored",
+			v: &struct {
+				strictEmbeddedID
+				strictEmbeddedOtherID
+			}{},
+			body:       `{"id":1}`,
+			wantFields: []string{"id"},
+		},
+		{
+			name: "exact match preferred over case-insensitive match",
+			v: &struct {
+				A int `json:"key"`
+				B struct {
+					X int `json:"x"`
+				} `json:"KEY"`
+			}{},
+			body:       `{"KEY":{"x":1,"y":2}}`,
+			wantFields: []string{"KEY.y"},
+		},
+		{
+			name: "first case-insensitive match",
+			v: &struct {
+				A struct {
+					X int `json:"x"`
+				} `json:"key"`
+				B map[string]int `json:"KEY"`
+			}{},
+			body:       `{"Key":{"x":1,"y":2}}`,
+			wantFields: []string{"Key.y"},
+		},
+		{
+			name: "unmarshaler fields",
+			v: &struct {
+				Raw    json.RawMessage     `json:"raw"`
+				Custom strictSelfDecoding  `json:"custom"`
+				Ptr    *strictSelfDecoding `json:"ptr"`
+			}{},
+			body: `{"raw":{"x":1},"custom":{"x":1},"ptr":{"x":1}}`,
+		},
+		{
+			name:       "map values",
+			v:          &map[string]strictEmbeddedID{},
+			body:       `{"a":{"id":1},"b":{"id":2,"extra":true}}`,
+			wantFields: []string{"b.extra"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := decodeStrict(strings.NewReader(test.body), test.v)
+			// Strict decoding must fail exactly when encoding/json reports an unknown field.
+			dec := json.NewDecoder(strings.NewReader(test.body))
+			dec.DisallowUnknownFields()
+			if jsonErr := dec.Decode(reflect.New(reflect.TypeOf(test.v).Elem()).Interface()); (jsonErr != nil) != (test.wantFields != nil) {
+				t.Fatalf("encoding/json strict decoding returned %v, want unknown fields %v", jsonErr, test.wantFields)
+			}
+			if test.wantFields == nil {
+				if err != nil {
+					t.Fatalf("decodeStrict returned error: %v", err)
+				}
+				return
+			}
+			var unknown *UnknownFieldsError
+			if !errors.As(err, &unknown) {
+				t.Fatalf("decodeStrict returned error %v, want an *UnknownFieldsError", err)
+			}
+			if !cmp.Equal(unknown.Fields, test.wantFields) {
+				t.Errorf("got unknown fields %v, want %v", unknown.Fields, test.wantFields)
+			}
+		})
+	}
+}
+
+func TestWithRetryClassifier(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+	classifier := func(resp *http.Response, err error) bool {
+		if err != nil || resp == nil {
+			return false
+		}
+		data, rerr := io.ReadAll(resp.Body)
+		if rerr != nil {
+			t.Errorf("failed to read response body in classifier: %v", rerr)
+			return false
+		}
+		return strings.Contains(string(data), "temporarily unavailable")
+	}
+	if err := WithRetryClassifier(classifier)(client); err != nil {
+		t.Fatal(err)
+	}
+	if err := WithClassifiedRetries(DefaultClassifiedRetries, time.Millisecond)(client); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int
+	var bodies []string
+	mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+		}
+		bodies = append(bodies, string(data))
+		requests++
+		if requests < 3 {
+			fmt.Fprint(w, `{"errors":[{"reason":"temporarily unavailable"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"event":{"id":"1","name":"test"}}`)
+	})
+
+	got, _, err := client.Events.Create(context.Background(), EventOptions{Name: "test"})
+	if err != nil {
+		t.Fatalf("Events.Create returned error: %v", err)
+	}
+	if got.Event.ID != "1" {
+		t.Errorf("Events.Create returned %+v, want event 1", got)
+	}
+	if requests != 3 {
+		t.Errorf("got %d requests, want 3", requests)
+	}
+	for i, body := range bodies {
+		if body != bodies[0] || body == "" {
+			t.Errorf("request %d body = %q, want %q", i, body, bodies[0])
+		}
+	}
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		requests = 0
+		mux.HandleFunc("/api/user/me", func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			fmt.Fprint(w, `{"errors":[{"reason":"temporarily unavailable"}]}`)
+		})
+		req, err := client.NewRequest(http.MethodGet, "api/user/me", nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		resp, err := client.BareDo(context.Background(), req)
+		if err != nil {
+			t.Fatalf("BareDo returned error: %v", err)
+		}
+		defer resp.Body.Close()
+		if requests != DefaultClassifiedRetries+1 {
+			t.Errorf("got %d requests, want %d", requests, DefaultClassifiedRetries+1)
+		}
+		data, err := io.ReadAll(resp.Body)
+		if err != nil || !strings.Contains(string(data), "temporarily unavailable") {
+			t.Errorf("got body %q, err %v, want the last response body", data, err)
+		}
+	})
+
+	t.Run("configured retries", func(t *testing.T) {
+		if err := WithClassifiedRetries(1, time.Millisecond)(client); err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = WithClassifiedRetries(DefaultClassifiedRetries, time.Millisecond)(client) }()
+		requests = 0
+		req, err := client.NewRequest(http.MethodGet, "api/user/me", nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		resp, err := client.BareDo(context.Background(), req)
+		if err != nil {
+			t.Fatalf("BareDo returned error: %v", err)
+		}
+		resp.Body.Close()
+		if requests != 2 {
+			t.Errorf("got %d requests, want 2", requests)
+		}
+	})
+
+	t.Run("body beyond limit", func(t *testing.T) {
+		body := `{"errors":[{"reason":"temporarily unavailable"}]}` + strings.Repeat(" ", RetryClassifierBodyLimit)
+		var classified int
+		sizeClassifier := func(resp *http.Response, err error) bool {
+			data, rerr := io.ReadAll(resp.Body)
+			if rerr != nil {
+				t.Errorf("failed to read response body in classifier: %v", rerr)
+			}
+			classified = len(data)
+			return false
+		}
+		if err := WithRetryClassifier(sizeClassifier)(client); err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = WithRetryClassifier(classifier)(client) }()
+		mux.HandleFunc("/api/large", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, body)
+		})
+		req, err := client.NewRequest(http.MethodGet, "api/large", nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		resp, err := client.BareDo(context.Background(), req)
+		if err != nil {
+			t.Fatalf("BareDo returned error: %v", err)
+		}
+		defer resp.Body.Close()
+		if classified != RetryClassifierBodyLimit {
+			t.Errorf("classifier read %d bytes, want %d", classified, RetryClassifierBodyLimit)
+		}
+		if data, err := io.ReadAll(resp.Body); err != nil || string(data) != body {
+			t.Errorf("got body of %d bytes, err %v, want the full body of %d bytes", len(data), err, len(body))
+		}
+	})
+
+	if err := WithRetryClassifier(nil)(client); err == nil {
+		t.Error("WithRetryClassifier(nil) expected error")
+	}
+}
+
+func TestDoWithRaw(t *testing.T) {
+	client, mux, _, teardown := setup(nil)
+	defer teardown()
+
+	body := `{"user":{"id":1,"username":"user@example.com","extra":true}}`
+	mux.HandleFunc("/api/user/me", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, body)
+	})
+	mux.HandleFunc("/api/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+	})
+
+	req, err := client.NewRequest(http.MethodGet, "api/user/me", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	var got struct {
+		User struct {
+			ID       int    `json:"id"`
+			Username string `json:"username"`
+		} `json:"user"`
+	}
+	raw, resp, err := client.DoWithRaw(context.Background(), req, &got)
+	if err != nil {
+		t.Fatalf("DoWithRaw returned error: %v", err)
+	}
+	if string(raw) != body {
+		t.Errorf("DoWithRaw returned raw body %s, want %s", raw, body)
+	}
+	if got.User.ID != 1 || got.User.Username != "user@example.com" {
+		t.Errorf("DoWithRaw decoded %+v, want user 1", got)
+	}
+	if data, err := io.ReadAll(resp.Body); err != nil || string(data) != body {
+		t.Errorf("response body = %q, %v, want %q", data, err, body)
+	}
+
+	req, err = client.NewRequest(http.MethodGet, "api/missing", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	raw, _, err = client.DoWithRaw(context.Background(), req, &got)
+	var errorResponse *ErrorResponse
+	if !errors.As(err, &errorResponse) || errorResponse.Message != "not found" {
+		t.Errorf("DoWithRaw returned error: %v, want not found *ErrorResponse", err)
+	}
+	if want := `{"message":"not found"}` + "\n"; string(raw) != want {
+		t.Errorf("DoWithRaw returned raw error body %q, want %q", raw, want)
+	}
+}
@@ -0,0 +1,118 @@
+// Package sysdigtest provides a fake Sysdig API server for testing code which uses the sysdig Client.
+//
+// Register handlers on the returned ServeMux for the API paths used by the code under test, either custom handlers or
+// the canned handlers of this package:
+//
+//	client, mux, teardown := sysdigtest.NewServer(t)
+//	defer teardown()
+//	mux.Handle(sysdigtest.MePath, sysdigtest.Me(t, sysdig.User{Username: "user@example.com"}))
+//	me, _, err := client.Users.Me(context.Background())
+package sysdigtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/trinchan/sysdig-go/sysdig"
+	"github.com/trinchan/sysdig-go/sysdig/authentication/accesstoken"
+)
+
+const (
+	// Token is the access token the Client returned by NewServer authenticates with.
+	Token = "sysdigtest"
+
+	// MePath is the API path of UsersService.Me.
+	MePath = "/api/user/me"
+	// EventsPath is the API path of EventsService.List and EventsService.Create.
+	EventsPath = "/api/v2/events"
+	// DashboardsPath is the API path of DashboardService.List and DashboardService.Create.
+	DashboardsPath = "/api/v3/dashboards"
+	// AlertsPath is the API path of AlertService.List and AlertService.Create.
+	AlertsPath = "/api/alerts"
+)
+
+// NewServer starts a fake Sysdig API server and returns a sysdig.Client configured to use it, the http.ServeMux on
+// which to register handlers for API paths such as MePath, and a func which shuts down the server. The server is also
+// shut down when the test completes. The Client authenticates with Token and is created with the given options.
+// Requests for paths without a registered handler receive a 404 Not Found.
+func NewServer(t testing.TB, options ...sysdig.ClientOption) (*sysdig.Client, *http.ServeMux, func()) {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	authenticator, err := accesstoken.Authenticator(Token)
+	if err != nil {
+		server.Close()
+		t.Fatalf("sysdigtest: failed to create authenticator: %v", err)
+	}
+	options = append(options, sysdig.WithBaseURL(server.URL+"/"))
+	client, err := sysdig.NewClient(authenticator, options...)
+	if err != nil {
+		server.Close()
+		t.Fatalf("sysdigtest: failed to create client: %v", err)
+	}
+	return client, mux, server.Close
+}
+
+// JSON returns a handler which responds to GET requests with the given status code and v encoded as JSON.
+// Requests with other methods receive a 405 Method Not Allowed.
+func JSON(t testing.TB, status int, v interface{}) http.HandlerFunc {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("sysdigtest: failed to encode response: %v", err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			Error(http.StatusMethodNotAllowed, "method not allowed")(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if _, err := w.Write(b); err != nil {
+			t.Errorf("sysdigtest: failed to write response: %v", err)
+		}
+	}
+}
+
+// Error returns a handler which responds to every request with the given status code and a Sysdig error body with
+// the message, which the Client returns as a *sysdig.ErrorResponse.
+func Error(status int, message string) http.HandlerFunc {
+	b, _ := json.Marshal(struct {
+		Message string         `json:"message"`
+		Errors  []sysdig.Error `json:"errors"`
+	}{Message: message, Errors: []sysdig.Error{{Message: message}}})
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(b)
+	}
+}
+
+// Me returns a handler for MePath which responds with the given User.
+func Me(t testing.TB, user sysdig.User) http.HandlerFunc {
+	return JSON(t, http.StatusOK, sysdig.MeResponse{User: user})
+}
+
+// Events returns a handler for EventsPath which lists the given Events. The options of the list request are not
+// applied, so the Events are returned as given.
+func Events(t testing.TB, events ...sysdig.Event) http.HandlerFunc {
+	return JSON(t, http.StatusOK, sysdig.ListEventsResponse{
+		Total:   len(events),
+		Matched: len(events),
+		Events:  append([]sysdig.Event{}, events...),
+	})
+}
+
+// Dashboards returns a handler for DashboardsPath which lists the given Dashboards.
+func Dashboards(t testing.TB, dashboards ...sysdig.Dashboard) http.HandlerFunc {
+	return JSON(t, http.StatusOK, sysdig.ListDashboardsResponse{Dashboards: append([]sysdig.Dashboard{}, dashboards...)})
+}
+
+// Alerts returns a handler for AlertsPath which lists the given Alerts.
+func Alerts(t testing.TB, alerts ...sysdig.Alert) http.HandlerFunc {
+	return JSON(t, http.StatusOK, sysdig.ListAlertConfigurationsResponse{Alerts: append([]sysdig.Alert{}, alerts...)})
+}
@@ -0,0 +1,84 @@
+package sysdigtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/trinchan/sysdig-go/sysdig"
+	"github.com/trinchan/sysdig-go/sysdig/authentication"
+)
+
+func TestNewServer_Me(t *testing.T) {
+	client, mux, teardown := NewServer(t)
+	defer teardown()
+	user := sysdig.User{ID: 1, Username: "user@example.com", FirstName: "Test"}
+	me := Me(t, user)
+	mux.HandleFunc(MePath, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get(authentication.AuthorizationHeader), authentication.AuthorizationHeaderFor(Token); got != want {
+			t.Errorf("got authorization header: %q, want: %q", got, want)
+		}
+		me(w, r)
+	})
+
+	got, _, err := client.Users.Me(context.Background())
+	if err != nil {
+		t.Fatalf("Users.Me returned error: %v", err)
+	}
+	if got.User.ID != user.ID || got.User.Username != user.Username || got.User.FirstName != user.FirstName {
+		t.Errorf("Users.Me returned user %d %q %q, want %d %q %q",
+			got.User.ID, got.User.Username, got.User.FirstName, user.ID, user.Username, user.FirstName)
+	}
+}
+
+func TestNewServer_List(t *testing.T) {
+	client, mux, teardown := NewServer(t)
+	defer teardown()
+	mux.Handle(DashboardsPath, Dashboards(t, sysdig.Dashboard{ID: 1, Name: "test"}))
+	mux.Handle(AlertsPath, Alerts(t))
+	mux.Handle(EventsPath, Events(t, sysdig.Event{ID: "1"}, sysdig.Event{ID: "2"}))
+
+	dashboards, _, err := client.Dashboards.List(context.Background())
+	if err != nil {
+		t.Fatalf("Dashboards.List returned error: %v", err)
+	}
+	if want := []sysdig.Dashboard{{ID: 1, Name: "test"}}; !cmp.Equal(dashboards.Dashboards, want) {
+		t.Errorf("Dashboards.List returned %+v, want %+v", dashboards.Dashboards, want)
+	}
+	alerts, _, err := client.Alerts.List(context.Background())
+	if err != nil {
+		t.Fatalf("Alerts.List returned error: %v", err)
+	}
+	if len(alerts.Alerts) != 0 {
+		t.Errorf("Alerts.List returned %+v, want no alerts", alerts.Alerts)
+	}
+	events, _, err := client.Events.List(context.Background(), sysdig.ListEventOptions{})
+	if err != nil {
+		t.Fatalf("Events.List returned error: %v", err)
+	}
+	if events.Total != 2 || len(events.Events) != 2 {
+		t.Errorf("Events.List returned %+v, want 2 events", events)
+	}
+
+	if _, _, err := client.Dashboards.Create(context.Background(), *sysdig.NewDashboard("test")); err == nil {
+		t.Error("Dashboards.Create against a list handler expected error")
+	}
+}
+
+func TestError(t *testing.T) {
+	client, mux, teardown := NewServer(t)
+	defer teardown()
+	mux.Handle(MePath, Error(http.StatusUnauthorized, "bad token"))
+
+	_, _, err := client.Users.Me(context.Background())
+	var errorResponse *sysdig.ErrorResponse
+	if !errors.As(err, &errorResponse) {
+		t.Fatalf("Users.Me returned error: %v, want *sysdig.ErrorResponse", err)
+	}
+	if errorResponse.StatusCode != http.StatusUnauthorized || errorResponse.Message != "bad token" {
+		t.Errorf("got error response: %d %q, want: %d %q",
+			errorResponse.StatusCode, errorResponse.Message, http.StatusUnauthorized, "bad token")
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 )
 
 // TeamsService is the Service for communicating with the Sysdig Monitor Team related API.
+// The Sysdig API does not expose per-Team default notification channels for alerts; NotificationChannels are
+// managed with the NotificationChannelsService and are visible to the Team of the authenticated user.
 type TeamsService service
 
 // ProductType defines the Sysdig product types. Valid options are `SDC` for Sysdig Monitor and
@@ -22,7 +25,27 @@ const (
 	ProductTypeAny ProductType = ""
 )
 
+// Known TeamEntryPoint Modules, the section of the Sysdig UI a Team lands on. The constants are untyped so they can be
+// assigned to TeamEntryPoint.Module directly.
+const (
+	// TeamModuleExplore lands on Explore in Sysdig Monitor.
+	TeamModuleExplore = "Explore"
+	// TeamModuleDashboards lands on Dashboards in Sysdig Monitor.
+	TeamModuleDashboards = "Dashboards"
+	// TeamModuleEvents lands on Events in Sysdig Monitor.
+	TeamModuleEvents = "Events"
+	// TeamModuleAlerts lands on Alerts in Sysdig Monitor.
+	TeamModuleAlerts = "Alerts"
+	// TeamModuleSettings lands on Settings.
+	TeamModuleSettings = "Settings"
+	// TeamModuleAdvisor lands on Advisor in Sysdig Monitor.
+	TeamModuleAdvisor = "Advisor"
+	// TeamModuleOverview lands on Overview.
+	TeamModuleOverview = "Overview"
+)
+
 // Team is the structure for a Sysdig Team.
+// Theme is the color of the Team in the Sysdig UI, as a free-form hex color code, e.g. "#7BB0B2".
 // See: https://docs.sysdig.com/en/docs/administration/administration-settings/user-and-team-administration/manage-teams-and-roles/
 type Team struct {
 	Version     int       `json:"version"`
@@ -55,6 +78,7 @@ type Team struct {
 
 // TeamEntryPoint is the entrypoint for this Team.
 type TeamEntryPoint struct {
+	// Module is the section of the Sysdig UI the Team lands on, e.g. TeamModuleExplore.
 	Module string `json:"module"`
 }
 
@@ -75,6 +99,15 @@ func (s *TeamsService) Get(ctx context.Context, teamID int) (*TeamResponse, *htt
 	return c, resp, err
 }
 
+// Current gets the active Team of the current User, as given by User.CurrentTeam in the UsersService.Me response.
+func (s *TeamsService) Current(ctx context.Context) (*TeamResponse, *http.Response, error) {
+	me, resp, err := s.client.Users.Me(ctx)
+	if err != nil {
+		return nil, resp, err
+	}
+	return s.Get(ctx, me.User.CurrentTeam)
+}
+
 // ListTeamsResponse is a container of Teams for the TeamsService.List API.
 type ListTeamsResponse struct {
 	Teams []Team `json:"teams"`
@@ -108,8 +141,20 @@ type ListUsersResponse struct {
 
 // ListUsers returns the list of Users for the given Team.
 func (s *TeamsService) ListUsers(ctx context.Context, teamID int) (*ListUsersResponse, *http.Response, error) {
+	return s.listUsers(ctx, teamID, 0)
+}
+
+// listUsers returns the list of Users for the given Team, starting at the given offset.
+func (s *TeamsService) listUsers(ctx context.Context, teamID, offset int) (*ListUsersResponse, *http.Response, error) {
 	u := fmt.Sprintf("api/team/%d/users", teamID)
-	req, err := s.client.NewRequest(http.MethodGet, u, nil)
+	type listOptions struct {
+		Offset int `url:"offset,omitempty"`
+	}
+	uWithOpts, err := addOptions(u, listOptions{Offset: offset})
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := s.client.NewRequest(http.MethodGet, uWithOpts, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -181,3 +226,20 @@ func (s *TeamsService) Infrastructure(ctx context.Context) (*InfrastructureRespo
 	resp, err := s.client.Do(ctx, req, c)
 	return c, resp, err
 }
+
+// InfrastructureForTeam returns metrics about the infrastructure monitored by Sysdig for the Team with the given ID,
+// without switching the current Team. The Team is selected with WithTeamIDContext, overriding the Team ID of an
+// authentication.Authenticator.
+func (s *TeamsService) InfrastructureForTeam(
+	ctx context.Context,
+	teamID int) (*InfrastructureResponse, *http.Response, error) {
+	u := "api/team/infrastructure"
+	req, err := s.client.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx = WithTeamIDContext(ctx, strconv.Itoa(teamID))
+	c := new(InfrastructureResponse)
+	resp, err := s.client.Do(ctx, req, c)
+	return c, resp, err
+}
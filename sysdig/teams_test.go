@@ -2,11 +2,14 @@ package sysdig
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/trinchan/sysdig-go/sysdig/authentication"
 )
 
 func TestTeamsService_Get(t *testing.T) {
@@ -53,6 +56,34 @@ func TestTeamsService_Get(t *testing.T) {
 	})
 }
 
+func TestTeamsService_Current(t *testing.T) {
+	methodName := "Current"
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/user/me", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"user":{"id":1,"currentTeam":42}}`)
+	})
+	mux.HandleFunc("/api/team/42", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"team":{"id":42,"name":"ops"}}`)
+	})
+	defer teardown()
+
+	team, _, err := client.Teams.Current(context.Background())
+	if err != nil {
+		t.Errorf("Teams.Current returned error: %v", err)
+	}
+	want := &TeamResponse{Team: Team{ID: 42, Name: "ops"}}
+	if !cmp.Equal(team, want) {
+		t.Errorf("Teams.Current returned %+v, want %+v", team, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Teams.Current(context.Background())
+		return resp, err
+	})
+}
+
 func TestTeamsService_List(t *testing.T) {
 	methodName := "Get"
 	client, mux, _, teardown := setup(nil)
@@ -251,3 +282,72 @@ func TestTeamsService_Infrastructure(t *testing.T) {
 		return resp, err
 	})
 }
+
+func TestTeamsService_InfrastructureForTeam(t *testing.T) {
+	methodName := "InfrastructureForTeam"
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/team/infrastructure", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		testHeader(t, r, authentication.SysdigTeamIDHeader, "42")
+		fmt.Fprint(w, `{"infrastructure":{"metricCount":{"total":1}}}`)
+	})
+	defer teardown()
+
+	got, _, err := client.Teams.InfrastructureForTeam(context.Background(), 42)
+	if err != nil {
+		t.Errorf("Teams.InfrastructureForTeam returned error: %v", err)
+	}
+	want := &InfrastructureResponse{Infrastructure: Infrastructure{MetricCount: InfrastructureMetricCount{Total: 1}}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Teams.InfrastructureForTeam returned %+v, want %+v", got, want)
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Teams.InfrastructureForTeam(context.Background(), 42)
+		return resp, err
+	})
+}
+
+func TestTeamConstants(t *testing.T) {
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{TeamModuleExplore, "Explore"},
+		{TeamModuleDashboards, "Dashboards"},
+		{TeamModuleEvents, "Events"},
+		{TeamModuleAlerts, "Alerts"},
+		{TeamModuleSettings, "Settings"},
+		{TeamModuleAdvisor, "Advisor"},
+		{TeamModuleOverview, "Overview"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("got: %q, want: %q", test.got, test.want)
+		}
+	}
+	team := Team{Theme: "#7BB0B2", EntryPoint: TeamEntryPoint{Module: TeamModuleExplore}}
+	b, err := json.Marshal(team)
+	if err != nil {
+		t.Fatalf("unexpected json marshal error: %v", err)
+	}
+	if want := `"theme":"#7BB0B2","entryPoint":{"module":"Explore"}`; !strings.Contains(string(b), want) {
+		t.Errorf("marshaled Team %s, want it to contain %s", b, want)
+	}
+}
+
+func TestProductType(t *testing.T) {
+	tests := []struct {
+		productType ProductType
+		want        string
+	}{
+		{ProductTypeMonitor, "SDC"},
+		{ProductTypeSecure, "SDS"},
+		{ProductTypeAny, ""},
+	}
+	for _, test := range tests {
+		if got := string(test.productType); got != test.want {
+			t.Errorf("got product type: %q, want: %q", got, test.want)
+		}
+	}
+}
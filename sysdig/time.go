@@ -2,6 +2,7 @@ package sysdig
 
 import (
 	"encoding/json"
+	"net/url"
 	"strconv"
 	"time"
 )
@@ -17,6 +18,26 @@ func NewMilliTime(t time.Time) MilliTime {
 	return MilliTime{t}
 }
 
+// String implements fmt.Stringer for MilliTime, formatting the time as RFC3339 in UTC.
+func (t MilliTime) String() string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// LastDuration returns the window covering the last d up to now, e.g. for ListEventOptions.From and To.
+// See Client.LastDuration for a window relative to the Client clock.
+func LastDuration(d time.Duration) (from, to MilliTime) {
+	return lastDuration(time.Now(), d)
+}
+
+// LastDuration returns the window covering the last d up to the current time of the Client clock set WithClock.
+func (c *Client) LastDuration(d time.Duration) (from, to MilliTime) {
+	return lastDuration(c.now(), d)
+}
+
+func lastDuration(now time.Time, d time.Duration) (from, to MilliTime) {
+	return NewMilliTime(now.Add(-d)), NewMilliTime(now)
+}
+
 // MarshalJSON implements the json.Marshaler interface for MilliTime.
 func (t MilliTime) MarshalJSON() ([]byte, error) {
 	return json.Marshal(t.UnixMilli())
@@ -31,3 +52,10 @@ func (t *MilliTime) UnmarshalJSON(b []byte) error {
 	*t = UnixMilli(int64(u))
 	return nil
 }
+
+// EncodeValues implements the query.Encoder interface for MilliTime, encoding it as Unix milliseconds.
+// Without it, MilliTime query parameters such as ListEventOptions.From and To were left out of requests.
+func (t MilliTime) EncodeValues(key string, v *url.Values) error {
+	v.Set(key, strconv.FormatInt(t.UnixMilli(), 10))
+	return nil
+}
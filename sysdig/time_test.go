@@ -0,0 +1,112 @@
+package sysdig
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-querystring/query"
+)
+
+func TestMilliTime_String(t *testing.T) {
+	instant := time.Date(2022, time.March, 4, 5, 6, 7, 8e6, time.FixedZone("JST", 9*60*60))
+	tests := []struct {
+		name string
+		in   MilliTime
+		want string
+	}{
+		{
+			name: "known instant",
+			in:   NewMilliTime(instant),
+			want: "2022-03-03T20:06:07Z",
+		},
+		{
+			name: "unix epoch",
+			in:   UnixMilli(0),
+			want: "1970-01-01T00:00:00Z",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.in.String(); got != test.want {
+				t.Errorf("got: %s, want: %s", got, test.want)
+			}
+			if got := fmt.Sprint(test.in); got != test.want {
+				t.Errorf("got formatted: %s, want: %s", got, test.want)
+			}
+		})
+	}
+
+	// String does not affect JSON marshaling.
+	b, err := json.Marshal(NewMilliTime(instant))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "1646337967008"; got != want {
+		t.Errorf("got JSON: %s, want: %s", got, want)
+	}
+}
+
+func TestLastDuration(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	from, to := LastDuration(time.Hour)
+	after := time.Now()
+	if to.Before(before) || to.After(after) {
+		t.Errorf("got to: %v, want between %v and %v", to, before, after)
+	}
+	if got := to.Sub(from.Time); got != time.Hour {
+		t.Errorf("got window: %v, want: %v", got, time.Hour)
+	}
+}
+
+func TestClient_LastDuration(t *testing.T) {
+	now := time.Date(2022, time.March, 4, 5, 6, 7, 0, time.UTC)
+	client, err := NewClient(nil, WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, to := client.LastDuration(time.Hour)
+	if want := NewMilliTime(now.Add(-time.Hour)); !from.Equal(want.Time) {
+		t.Errorf("got from: %v, want: %v", from, want)
+	}
+	if want := NewMilliTime(now); !to.Equal(want.Time) {
+		t.Errorf("got to: %v, want: %v", to, want)
+	}
+}
+
+func TestMilliTime_EncodeValues(t *testing.T) {
+	instant := time.Date(2022, time.March, 4, 5, 6, 7, 8e6, time.UTC)
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{
+			name: "unix milliseconds",
+			in: struct {
+				From MilliTime `url:"from"`
+			}{NewMilliTime(instant)},
+			want: "from=1646370367008",
+		},
+		{
+			name: "zero omitted",
+			in: struct {
+				From MilliTime `url:"from,omitempty"`
+				To   MilliTime `url:"to,omitempty"`
+			}{To: UnixMilli(1)},
+			want: "to=1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := query.Values(test.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := v.Encode(); got != test.want {
+				t.Errorf("got query: %s, want: %s", got, test.want)
+			}
+		})
+	}
+}
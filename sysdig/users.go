@@ -2,7 +2,11 @@ package sysdig
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/mail"
 )
 
 // UsersService is the Service for communicating with the Sysdig Monitor User related API.
@@ -19,7 +23,7 @@ type User struct {
 	AgentInstallParams   AgentInstallParams `json:"agentInstallParams"`
 	Properties           UserProperties     `json:"properties"`
 	ResetPassword        bool               `json:"resetPassword"`
-	AdditionalRoles      []interface{}      `json:"additionalRoles"` // TODO What is the format of this...
+	AdditionalRoles      []AdditionalRole   `json:"additionalRoles"`
 	TeamRoles            []TeamRole         `json:"teamRoles"`
 	LastUpdated          MilliTime          `json:"lastUpdated"`
 	AccessKey            string             `json:"accessKey"`
@@ -79,18 +83,79 @@ type TeamRole struct {
 	Admin     bool   `json:"admin"`
 }
 
+// AdditionalRole is a role granted to a User in a Team in addition to its TeamRoles.
+type AdditionalRole struct {
+	TeamID int    `json:"teamId"`
+	Role   string `json:"role"`
+}
+
 // CustomerSettings are the customer related settings for a user.
 type CustomerSettings struct {
-	Sysdig      UserSysdigSettings `json:"sysdig"`
-	Plan        Plan               `json:"plan"`
-	Environment interface{}        `json:"environment"` // TODO What is the format of this...
+	Sysdig      UserSysdigSettings  `json:"sysdig"`
+	Plan        Plan                `json:"plan"`
+	Environment CustomerEnvironment `json:"environment"`
+}
+
+// CustomerEnvironment describes the environment the customer is hosted in. The payload is undocumented, so fields
+// which are not modeled here are available in Raw.
+type CustomerEnvironment struct {
+	Name     string `json:"name"`
+	Region   string `json:"region"`
+	OnPrem   bool   `json:"onPrem"`
+	Provider string `json:"provider"`
+
+	// Raw is the environment as returned by the API.
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for CustomerEnvironment. Fields with an unexpected type
+// are left empty rather than failing to decode the whole User.
+func (e *CustomerEnvironment) UnmarshalJSON(data []byte) error {
+	type alias CustomerEnvironment
+	return unmarshalTolerant(data, (*alias)(e), &e.Raw)
 }
 
 // UserSysdigSettings are the Sysdig settings for a user.
 type UserSysdigSettings struct {
-	Enabled    bool          `json:"enabled"`
-	EnabledSSE bool          `json:"enabledSSE"`
-	Buckets    []interface{} `json:"buckets"` // TODO What is the format of this...
+	Enabled    bool            `json:"enabled"`
+	EnabledSSE bool            `json:"enabledSSE"`
+	Buckets    []StorageBucket `json:"buckets"`
+}
+
+// StorageBucket is a storage bucket configured for the customer, e.g. an S3 bucket for capture storage. The payload
+// is undocumented, so fields which are not modeled here are available in Raw.
+type StorageBucket struct {
+	Name            string `json:"name"`
+	Folder          string `json:"folder"`
+	Description     string `json:"description"`
+	ProviderKeyID   int    `json:"providerKeyId"`
+	Endpoint        string `json:"endpoint"`
+	Region          string `json:"region"`
+	PathStyleAccess bool   `json:"pathStyleAccess"`
+
+	// Raw is the bucket as returned by the API.
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for StorageBucket. Fields with an unexpected type are left
+// empty rather than failing to decode the whole User.
+func (b *StorageBucket) UnmarshalJSON(data []byte) error {
+	type alias StorageBucket
+	return unmarshalTolerant(data, (*alias)(b), &b.Raw)
+}
+
+// unmarshalTolerant unmarshals data into v, keeping a copy of data in raw. Type mismatches are ignored since
+// encoding/json still decodes the remaining fields.
+func unmarshalTolerant(data []byte, v interface{}, raw *json.RawMessage) error {
+	if string(data) == "null" {
+		return nil
+	}
+	*raw = append(json.RawMessage(nil), data...)
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal(data, v); err != nil && !errors.As(err, &typeErr) {
+		return err
+	}
+	return nil
 }
 
 // Plan is the plan for a User.
@@ -201,6 +266,21 @@ func (s *UsersService) Me(ctx context.Context) (*MeResponse, *http.Response, err
 	return c, resp, err
 }
 
+// AgentInstallParams returns the agent installation parameters of the current User, e.g. for automation which
+// provisions agents. The Sysdig API has no dedicated endpoint, so they are read from Me. If the parameters have no
+// AccessKey, the AccessKey of the User is used.
+func (s *UsersService) AgentInstallParams(ctx context.Context) (*AgentInstallParams, *http.Response, error) {
+	me, resp, err := s.Me(ctx)
+	if err != nil {
+		return nil, resp, err
+	}
+	params := me.User.AgentInstallParams
+	if params.AccessKey == "" {
+		params.AccessKey = me.User.AccessKey
+	}
+	return &params, resp, nil
+}
+
 // TokenResponse describes the response for UsersService.Token.
 type TokenResponse struct {
 	Token Token `json:"token"`
@@ -245,3 +325,40 @@ func (s *UsersService) ConnectedAgents(ctx context.Context) (*ConnectedAgentsRes
 	resp, err := s.client.Do(ctx, req, c)
 	return c, resp, err
 }
+
+// UserResponse describes the response for a single User.
+type UserResponse struct {
+	User User `json:"user"`
+}
+
+// Invite invites a new User by email with the given TeamRoles. The User must accept the invite sent to the email
+// address before logging in.
+func (s *UsersService) Invite(ctx context.Context, email string, teamRoles []TeamRole) (*UserResponse, *http.Response, error) {
+	if err := validateEmail(email); err != nil {
+		return nil, nil, err
+	}
+	type inviteRequest struct {
+		Username  string     `json:"username"`
+		TeamRoles []TeamRole `json:"teamRoles,omitempty"`
+	}
+	u := "api/users"
+	req, err := s.client.NewRequest(http.MethodPost, u, inviteRequest{Username: email, TeamRoles: teamRoles})
+	if err != nil {
+		return nil, nil, err
+	}
+	c := new(UserResponse)
+	resp, err := s.client.Do(ctx, req, c)
+	return c, resp, err
+}
+
+// validateEmail returns an error if email is not a bare email address, e.g. user@example.com.
+func validateEmail(email string) error {
+	if email == "" {
+		return fmt.Errorf("email cannot be blank")
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return fmt.Errorf("invalid email %q", email)
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package sysdig
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -21,6 +23,7 @@ func TestUsersService_Me(t *testing.T) {
 	tests := []struct {
 		name    string
 		handler http.HandlerFunc
+		want    *MeResponse
 	}{
 		{
 			name: "test",
@@ -28,6 +31,39 @@ func TestUsersService_Me(t *testing.T) {
 				testMethod(t, r, http.MethodGet)
 				fmt.Fprint(w, `{"user":{"id":1}}`)
 			},
+			want: &MeResponse{User: User{ID: 1}},
+		},
+		{
+			name: "additional roles",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				fmt.Fprint(w, `{
+  "user": {
+    "id": 1,
+    "username": "user@example.com",
+    "currentTeam": 2,
+    "additionalRoles": [
+      {"teamId": 2, "role": "ROLE_TEAM_EDIT"},
+      {"teamId": 3, "role": "ROLE_TEAM_READ"}
+    ],
+    "teamRoles": [
+      {"teamId": 2, "teamName": "Monitor Operations", "userId": 1, "userName": "user@example.com", "role": "ROLE_TEAM_MANAGER"}
+    ]
+  }
+}`)
+			},
+			want: &MeResponse{User: User{
+				ID:          1,
+				Username:    "user@example.com",
+				CurrentTeam: 2,
+				AdditionalRoles: []AdditionalRole{
+					{TeamID: 2, Role: "ROLE_TEAM_EDIT"},
+					{TeamID: 3, Role: "ROLE_TEAM_READ"},
+				},
+				TeamRoles: []TeamRole{
+					{TeamID: 2, TeamName: "Monitor Operations", UserID: 1, UserName: "user@example.com", Role: "ROLE_TEAM_MANAGER"},
+				},
+			}},
 		},
 	}
 	for _, test := range tests {
@@ -38,9 +74,8 @@ func TestUsersService_Me(t *testing.T) {
 			if err != nil {
 				t.Errorf("Users.Me returned error: %v", err)
 			}
-			want := &MeResponse{User: User{ID: 1}}
-			if !cmp.Equal(user, want) {
-				t.Errorf("Users.Me returned %+v, want %+v", user, want)
+			if !cmp.Equal(user, test.want) {
+				t.Errorf("Users.Me returned %+v, want %+v", user, test.want)
 			}
 		})
 	}
@@ -51,6 +86,72 @@ func TestUsersService_Me(t *testing.T) {
 	})
 }
 
+func TestUsersService_AgentInstallParams(t *testing.T) {
+	methodName := "AgentInstallParams"
+	client, mux, _, teardown := setup(nil)
+	var h http.HandlerFunc
+	mux.HandleFunc("/api/user/me", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		h(w, r)
+	})
+	defer teardown()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    *AgentInstallParams
+	}{
+		{
+			name: "install params",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"user":{"id":1,"accessKey":"user-key","agentInstallParams":{
+					"accessKey":"agent-key",
+					"collectorAddress":"collector.sysdigcloud.com",
+					"collectorPort":6443,
+					"checkCertificate":true,
+					"sslEnabled":true}}}`)
+			},
+			want: &AgentInstallParams{
+				AccessKey:        "agent-key",
+				CollectorAddress: "collector.sysdigcloud.com",
+				CollectorPort:    6443,
+				CheckCertificate: true,
+				SSLEnabled:       true,
+			},
+		},
+		{
+			name: "user access key",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"user":{"id":1,"accessKey":"user-key","agentInstallParams":{
+					"collectorAddress":"collector.sysdigcloud.com",
+					"collectorPort":6443}}}`)
+			},
+			want: &AgentInstallParams{
+				AccessKey:        "user-key",
+				CollectorAddress: "collector.sysdigcloud.com",
+				CollectorPort:    6443,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h = test.handler
+			got, _, err := client.Users.AgentInstallParams(context.Background())
+			if err != nil {
+				t.Fatalf("Users.AgentInstallParams returned error: %v", err)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("Users.AgentInstallParams returned %+v, want %+v", got, test.want)
+			}
+		})
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Users.AgentInstallParams(context.Background())
+		return resp, err
+	})
+}
+
 func TestUsersService_Token(t *testing.T) {
 	methodName := "Token"
 	client, mux, _, teardown := setup(nil)
@@ -134,3 +235,83 @@ func TestUsersService_ConnectedAgents(t *testing.T) {
 		return resp, err
 	})
 }
+
+func TestUsersService_Invite(t *testing.T) {
+	methodName := "Invite"
+	client, mux, _, teardown := setup(nil)
+	mux.HandleFunc("/api/users", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		testBody(t, r, `{"username":"user@example.com","teamRoles":[`+
+			`{"teamId":2,"teamName":"","teamTheme":"","userId":0,"userName":"","role":"ROLE_TEAM_EDIT","admin":false}]}`+"\n")
+		fmt.Fprint(w, `{"user":{"id":1,"username":"user@example.com"}}`)
+	})
+	defer teardown()
+
+	teamRoles := []TeamRole{{TeamID: 2, Role: "ROLE_TEAM_EDIT"}}
+	got, _, err := client.Users.Invite(context.Background(), "user@example.com", teamRoles)
+	if err != nil {
+		t.Fatalf("Users.Invite returned error: %v", err)
+	}
+	want := &UserResponse{User: User{ID: 1, Username: "user@example.com"}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Users.Invite returned %+v, want %+v", got, want)
+	}
+
+	for _, email := range []string{"", "user", "User <user@example.com>", " user@example.com"} {
+		if _, _, err := client.Users.Invite(context.Background(), email, teamRoles); err == nil {
+			t.Errorf("Users.Invite(%q) expected error", email)
+		}
+	}
+
+	testNewRequestAndDoFailure(t, methodName, client, func() (*http.Response, error) {
+		_, resp, err := client.Users.Invite(context.Background(), "user@example.com", teamRoles)
+		return resp, err
+	})
+}
+
+func TestCustomerSettings_UnmarshalJSON(t *testing.T) {
+	data := `{
+  "user": {
+    "id": 1,
+    "customerSettings": {
+      "sysdig": {
+        "enabled": true,
+        "enabledSSE": false,
+        "buckets": [
+          {"name": "captures", "folder": "sysdig", "providerKeyId": 3, "region": "us-east-1", "pathStyleAccess": false},
+          {"name": "legacy", "providerKeyId": "unexpected", "retention": 30},
+          "unexpected"
+        ]
+      },
+      "environment": {"name": "prod", "region": "us-east-1", "onPrem": false, "provider": "aws", "tier": "enterprise"}
+    }
+  }
+}`
+	var got MeResponse
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	settings := got.User.CustomerSettings
+	wantBuckets := []StorageBucket{
+		{Name: "captures", Folder: "sysdig", ProviderKeyID: 3, Region: "us-east-1"},
+		{Name: "legacy"},
+		{},
+	}
+	if got, want := string(settings.Sysdig.Buckets[1].Raw), `{"name": "legacy", "providerKeyId": "unexpected", "retention": 30}`; got != want {
+		t.Errorf("got bucket Raw: %s, want: %s", got, want)
+	}
+	for i := range settings.Sysdig.Buckets {
+		settings.Sysdig.Buckets[i].Raw = nil
+	}
+	if diff := cmp.Diff(wantBuckets, settings.Sysdig.Buckets); diff != "" {
+		t.Errorf("Buckets mismatch (-want +got):\n%s", diff)
+	}
+	if !strings.Contains(string(settings.Environment.Raw), `"tier": "enterprise"`) {
+		t.Errorf("Environment Raw missing unmodeled field: %s", settings.Environment.Raw)
+	}
+	settings.Environment.Raw = nil
+	wantEnv := CustomerEnvironment{Name: "prod", Region: "us-east-1", Provider: "aws"}
+	if diff := cmp.Diff(wantEnv, settings.Environment); diff != "" {
+		t.Errorf("Environment mismatch (-want +got):\n%s", diff)
+	}
+}